@@ -0,0 +1,109 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The phases of feature requirements.
+const (
+	// PhaseSatisfied is the phase of requirements met by the installed features.
+	PhaseSatisfied = "satisfied"
+	// PhaseUnsatisfied is the phase of requirements with at least one required feature missing.
+	PhaseUnsatisfied = "unsatisfied"
+)
+
+// RequiredFeature is a feature or capability an application needs. Either the name or the kind has to be given.
+type RequiredFeature struct {
+	// Namespace is the namespace of the feature. Empty implies the namespace of the requirement. It is ignored for
+	// capabilities.
+	Namespace string `json:"namespace,omitempty"`
+	// Name is the name of the required feature.
+	Name string `json:"name,omitempty"`
+	// Kind requires a capability: any feature of this kind in any namespace satisfies it, e.g. "dns".
+	Kind string `json:"kind,omitempty"`
+	// Version is an optional version range the feature has to satisfy, e.g. ">=1.5.0,<2.0.0".
+	Version string `json:"version,omitempty"`
+}
+
+func (r RequiredFeature) String() string {
+	result := fmt.Sprintf("%s%c%s", r.Namespace, Separator, r.Name)
+	if r.Name == "" {
+		result = fmt.Sprintf("kind %s", r.Kind)
+	}
+	if r.Version != "" {
+		result = fmt.Sprintf("%s@%s", result, r.Version)
+	}
+
+	return result
+}
+
+// FeatureRequirementSpec defines the desired state of FeatureRequirement
+type FeatureRequirementSpec struct {
+	// Requires lists the features and capabilities the application needs.
+	Requires []RequiredFeature `json:"requires"`
+}
+
+// UnsatisfiedFeature is a required feature that is not installed in a matching version.
+type UnsatisfiedFeature struct {
+	RequiredFeature `json:",inline"`
+	// Reason explains why the requirement is not satisfied.
+	Reason string `json:"reason"`
+}
+
+// FeatureRequirementStatus defines the observed state of FeatureRequirement
+type FeatureRequirementStatus struct {
+	// +kubebuilder:validation:Enum={"satisfied","unsatisfied"}
+	// Phase is the state of the requirement. May be satisfied or unsatisfied
+	Phase string `json:"phase"`
+	// Message is a human readable message for this state
+	Message string `json:"message,omitempty"`
+	// Unsatisfied lists the required features that are missing with the reasons.
+	Unsatisfied []UnsatisfiedFeature `json:"unsatisfied,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName="iftreq"
+// +kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// FeatureRequirement is the Schema for the featurerequirements API
+type FeatureRequirement struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FeatureRequirementSpec   `json:"spec,omitempty"`
+	Status FeatureRequirementStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// FeatureRequirementList contains a list of FeatureRequirement
+type FeatureRequirementList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []FeatureRequirement `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&FeatureRequirement{}, &FeatureRequirementList{})
+}
@@ -26,18 +26,173 @@ const (
 	Separator = '/'
 )
 
+// The phases of features and feature groups.
+const (
+	// PhasePending is the phase of features with missing dependencies.
+	PhasePending = "pending"
+	// PhaseInitializing is the phase of features not resolved yet.
+	PhaseInitializing = "initializing"
+	// PhaseFailed is the phase of features that can not work on this cluster, e.g. due to conflicting features.
+	PhaseFailed = "failed"
+	// PhaseProvisioned is the phase of features with all dependencies met.
+	PhaseProvisioned = "provisioned"
+	// PhaseDegraded is the phase of features whose workloads are not available.
+	PhaseDegraded = "degraded"
+)
+
+// The labels and annotations of discovered features.
+const (
+	// LabelDiscoveredBy marks the features managed by a discovery source. The value names the source, e.g. "crd".
+	// Features without this label are authored manually and are never changed by a discovery source.
+	LabelDiscoveredBy = "features.kaiserpfalz-edv.de/discovered-by"
+	// AnnotationDiscoveredFrom lists the objects a discovered feature has been generated from.
+	AnnotationDiscoveredFrom = "features.kaiserpfalz-edv.de/discovered-from"
+	// AnnotationSourcePhase is the phase the source of a discovered feature reports, e.g. the phase of an operator
+	// installation. A failed or initializing source phase is taken over by the status of the feature.
+	AnnotationSourcePhase = "features.kaiserpfalz-edv.de/source-phase"
+	// AnnotationSourceMessage is the message of the source of a discovered feature explaining its phase.
+	AnnotationSourceMessage = "features.kaiserpfalz-edv.de/source-message"
+)
+
+// The annotations and labels of the admission gate of workloads.
+const (
+	// AnnotationRequires lists the features a Pod or Deployment requires, separated by semicolons or newlines, e.g.
+	// "platform/postgres-operator@>=1.5.0; cert-manager; kind:dns".
+	AnnotationRequires = "features.kaiserpfalz-edv.de/requires"
+	// LabelRequiresPolicy is the label of namespaces selecting how unsatisfied requirements of their workloads are
+	// handled: enforce, warn or off.
+	LabelRequiresPolicy = "features.kaiserpfalz-edv.de/requires-policy"
+)
+
 // InstaledFeatureGroupListedFeature defines subfeatures by namespace and name
 type InstalledFeatureRef struct {
 	// Namespace is the namespace of the feature listed
 	Namespace string `json:"namespace,omitempty"`
 	// Name is the name of the feature listed
 	Name string `json:"name"`
+	// Version is an optional version range the referenced feature has to satisfy, e.g. ">=1.5.0,<2.0.0". It is
+	// checked for dependencies and conflicts, a conflict only applies to the versions in the range.
+	Version string `json:"version,omitempty"`
 }
 
 func (n InstalledFeatureRef) String() string {
 	return fmt.Sprintf("%s%c%s", n.Namespace, Separator, n.Name)
 }
 
+// ProvidedAPI is a kind in an API version, provided or consumed by a feature.
+type ProvidedAPI struct {
+	// Group is the API group, empty for the core group.
+	Group string `json:"group,omitempty"`
+	// Version is the API version, e.g. "v1".
+	Version string `json:"version"`
+	// Kind is the kind of the resource, e.g. "Certificate".
+	Kind string `json:"kind"`
+}
+
+func (a ProvidedAPI) String() string {
+	if a.Group == "" {
+		return fmt.Sprintf("%s %s", a.Version, a.Kind)
+	}
+
+	return fmt.Sprintf("%s%c%s %s", a.Group, Separator, a.Version, a.Kind)
+}
+
+// MissingAPI is a provided API the cluster does not serve.
+type MissingAPI struct {
+	ProvidedAPI `json:",inline"`
+	// Reason explains why the API is missing, e.g. that its CRD is not established.
+	Reason string `json:"reason,omitempty"`
+}
+
+// WorkloadSelector selects the Deployments, StatefulSets and DaemonSets running a feature.
+type WorkloadSelector struct {
+	// Namespace of the workloads. Empty implies the namespace of the feature.
+	Namespace string `json:"namespace,omitempty"`
+	// Selector is the label selector of the workloads.
+	Selector metav1.LabelSelector `json:"selector"`
+	// Images lists the container images representing the feature without tag or digest, e.g.
+	// "quay.io/jetstack/cert-manager-controller". Their tags (or digests, if untagged) are compared with the version of
+	// the feature.
+	Images []string `json:"images,omitempty"`
+	// ObserveVersion writes the version running in the workloads to the status of the feature.
+	ObserveVersion bool `json:"observe-version,omitempty"`
+}
+
+// WorkloadStatus is the availability of the workloads of a feature.
+type WorkloadStatus struct {
+	// Workloads is the number of selected workloads.
+	Workloads int32 `json:"workloads"`
+	// ReadyReplicas is the number of ready pods of all selected workloads.
+	ReadyReplicas int32 `json:"ready-replicas"`
+	// DesiredReplicas is the number of pods all selected workloads should have.
+	DesiredReplicas int32 `json:"desired-replicas"`
+	// Versions contains the sorted tags of the images of the feature found in the workloads. Images without tag
+	// contribute their digest.
+	Versions []string `json:"versions,omitempty"`
+}
+
+// Available checks if workloads have been selected and all their pods are ready.
+func (w WorkloadStatus) Available() bool {
+	return w.Workloads > 0 && w.ReadyReplicas >= w.DesiredReplicas
+}
+
+// The condition types of features.
+const (
+	// ConditionKubernetesCompatible tells if the Kubernetes version of the cluster is within the bounds of the feature.
+	ConditionKubernetesCompatible = "KubernetesCompatible"
+	// ConditionUpgradeReady tells if the feature works with the target Kubernetes version of the next upgrade: the
+	// version is within its bounds and no consumed API is removed.
+	ConditionUpgradeReady = "UpgradeReady"
+)
+
+// FeatureCondition is an observation of a feature by the operator.
+type FeatureCondition struct {
+	// Type of the condition, e.g. "KubernetesCompatible".
+	Type string `json:"type"`
+	// Status of the condition, one of True, False or Unknown.
+	Status metav1.ConditionStatus `json:"status"`
+	// Reason is a machine readable reason for the last transition.
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable message for the last transition.
+	Message string `json:"message,omitempty"`
+	// LastTransitionTime is the last time the status of the condition changed.
+	LastTransitionTime metav1.Time `json:"last-transition-time,omitempty"`
+}
+
+// FindCondition returns the condition of the given type or nil.
+func FindCondition(conditions []FeatureCondition, conditionType string) *FeatureCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+
+	return nil
+}
+
+// SetCondition returns the conditions with the given one added or replaced. The transition time is kept as long as
+// the status does not change.
+func SetCondition(conditions []FeatureCondition, condition FeatureCondition) []FeatureCondition {
+	result := RemoveCondition(conditions, condition.Type)
+	if existing := FindCondition(conditions, condition.Type); existing != nil && existing.Status == condition.Status {
+		condition.LastTransitionTime = existing.LastTransitionTime
+	}
+
+	return append(result, condition)
+}
+
+// RemoveCondition returns the conditions without the condition of the given type.
+func RemoveCondition(conditions []FeatureCondition, conditionType string) []FeatureCondition {
+	var result []FeatureCondition
+	for _, condition := range conditions {
+		if condition.Type != conditionType {
+			result = append(result, condition)
+		}
+	}
+
+	return result
+}
+
 // InstalledFeatureSpec defines the desired state of InstalledFeature
 type InstalledFeatureSpec struct {
 	// Group is the preferred group of the resource.  Empty implies the group of the containing resource list.
@@ -58,12 +213,23 @@ type InstalledFeatureSpec struct {
 	DependsOn []InstalledFeatureRef `json:"depends,omitempty"`
 	// Conflicts lists all features that make a cluster incompatible with this feature
 	Conflicts []InstalledFeatureRef `json:"conflicts,omitempty"`
+	// ProvidedAPIs lists the APIs this feature provides. The feature fails when one of them is not served.
+	ProvidedAPIs []ProvidedAPI `json:"provided-apis,omitempty"`
+	// ConsumedAPIs lists the APIs this feature uses. They are checked against the APIs removed by Kubernetes upgrades.
+	ConsumedAPIs []ProvidedAPI `json:"consumed-apis,omitempty"`
+	// Workloads selects the workloads running this feature. The feature is degraded when they are not available.
+	Workloads *WorkloadSelector `json:"workloads,omitempty"`
+	// MinKubernetesVersion is the lowest Kubernetes version the feature works with, e.g. "1.19".
+	MinKubernetesVersion string `json:"min-kubernetes-version,omitempty"`
+	// MaxKubernetesVersion is the highest Kubernetes version the feature works with. Omitted components match any
+	// version, e.g. "1.21" includes "1.21.5".
+	MaxKubernetesVersion string `json:"max-kubernetes-version,omitempty"`
 }
 
 // InstalledFeatureStatus defines the observed state of InstalledFeature
 type InstalledFeatureStatus struct {
-	// +kubebuilder:validation:Enum={"pending","initializing","failed","provisioned"}
-	// Phase is the state of this message. May be pending, initializing, failed, provisioned
+	// +kubebuilder:validation:Enum={"pending","initializing","failed","provisioned","degraded"}
+	// Phase is the state of this message. May be pending, initializing, failed, provisioned, degraded
 	Phase string `json:"phase"`
 	// Message is a human readable message for this state.
 	Message string `json:"message,omitempty"`
@@ -73,6 +239,16 @@ type InstalledFeatureStatus struct {
 	ConflictingFeatures []InstalledFeatureRef `json:"conflicting-features,omitempty"`
 	// DependingFeatures contains all features, that depend on this feature
 	DependingFeatures []InstalledFeatureRef `json:"depending-features,omitempty"`
+	// MissingAPIs contains the provided APIs not served by the cluster.
+	MissingAPIs []MissingAPI `json:"missing-apis,omitempty"`
+	// Workloads contains the availability of the selected workloads.
+	Workloads *WorkloadStatus `json:"workloads,omitempty"`
+	// VersionDrift is set when the images of the feature run in another version than the declared one.
+	VersionDrift bool `json:"version-drift,omitempty"`
+	// ObservedVersion is the version the images of the feature run in. It is only written when observed.
+	ObservedVersion string `json:"observed-version,omitempty"`
+	// Conditions contains the observations of the feature, e.g. its compatibility with the Kubernetes version.
+	Conditions []FeatureCondition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
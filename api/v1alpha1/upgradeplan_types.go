@@ -0,0 +1,114 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The phases of upgrade plans.
+const (
+	// PhasePlanned is the phase of upgrade plans with all targets in the computed steps.
+	PhasePlanned = "planned"
+	// PhaseBlocked is the phase of upgrade plans with targets that can not be upgraded.
+	PhaseBlocked = "blocked"
+)
+
+// UpgradeTarget is the version a feature should be upgraded to.
+type UpgradeTarget struct {
+	// Namespace is the namespace of the feature. Empty implies the namespace of the plan.
+	Namespace string `json:"namespace,omitempty"`
+	// Name is the name of the feature.
+	Name string `json:"name"`
+	// Version is the version the feature is upgraded to.
+	Version string `json:"version"`
+	// DependsOn lists the dependencies of the target version with their version ranges. They replace the
+	// dependencies of the installed version on the same features, all other dependencies are kept.
+	DependsOn []InstalledFeatureRef `json:"depends,omitempty"`
+}
+
+// UpgradePlanSpec defines the desired state of UpgradePlan
+type UpgradePlanSpec struct {
+	// Targets lists the features to upgrade with their target versions.
+	Targets []UpgradeTarget `json:"targets"`
+}
+
+// UpgradeStep is a single upgrade of the plan.
+type UpgradeStep struct {
+	// Namespace is the namespace of the upgraded feature.
+	Namespace string `json:"namespace"`
+	// Name is the name of the upgraded feature.
+	Name string `json:"name"`
+	// From is the installed version of the feature.
+	From string `json:"from"`
+	// To is the target version of the feature.
+	To string `json:"to"`
+}
+
+// BlockedUpgrade is a target that can not be upgraded.
+type BlockedUpgrade struct {
+	// Namespace is the namespace of the feature.
+	Namespace string `json:"namespace"`
+	// Name is the name of the feature.
+	Name string `json:"name"`
+	// Version is the target version of the feature.
+	Version string `json:"version"`
+	// Reasons explain why the upgrade is blocked.
+	Reasons []string `json:"reasons"`
+}
+
+// UpgradePlanStatus defines the observed state of UpgradePlan
+type UpgradePlanStatus struct {
+	// +kubebuilder:validation:Enum={"planned","blocked"}
+	// Phase is the state of the plan. May be planned or blocked
+	Phase string `json:"phase"`
+	// Message is a human readable message for this state
+	Message string `json:"message,omitempty"`
+	// Steps are the upgrades in the order they have to be done. Every step leaves all dependencies satisfied.
+	Steps []UpgradeStep `json:"steps,omitempty"`
+	// Blocked lists the targets that can not be upgraded.
+	Blocked []BlockedUpgrade `json:"blocked,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName="iftup"
+// +kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// UpgradePlan is the Schema for the upgradeplans API
+type UpgradePlan struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   UpgradePlanSpec   `json:"spec,omitempty"`
+	Status UpgradePlanStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// UpgradePlanList contains a list of UpgradePlan
+type UpgradePlanList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []UpgradePlan `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&UpgradePlan{}, &UpgradePlanList{})
+}
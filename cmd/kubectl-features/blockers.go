@@ -0,0 +1,86 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/catalogue"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/compatibility"
+)
+
+var blockersRemovals string
+
+var blockersCommand = command{
+	usage:       "blockers <kubernetes-version>",
+	description: "Lists the features blocking an upgrade to the Kubernetes version and the deprecated APIs they use.",
+	run:         runBlockers,
+	flags: func(fs *flag.FlagSet) {
+		fs.StringVar(&blockersRemovals, "api-removals", "",
+			"A YAML file with API removals added to the built-in table.")
+	},
+}
+
+func runBlockers(ctx context.Context, opts *options, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("exactly one kubernetes version expected, got %d", len(args))
+	}
+	target := args[0]
+
+	removals, err := compatibility.LoadRemovals(blockersRemovals)
+	if err != nil {
+		return err
+	}
+
+	cat, err := opts.catalogue(ctx)
+	if err != nil {
+		return err
+	}
+
+	blocked := false
+	for _, feature := range cat.Features() {
+		if feature.DeletionTimestamp != nil {
+			continue
+		}
+
+		reasons, err := compatibility.Upgrade(feature, target, removals)
+		if err != nil {
+			return err
+		}
+		for _, reason := range reasons {
+			blocked = true
+			fmt.Printf("BLOCKS %s: %s\n", catalogue.RefOf(feature), reason)
+		}
+
+		_, deprecated, err := removals.ConsumedAPIs(feature, target)
+		if err != nil {
+			return err
+		}
+		for _, reason := range deprecated {
+			fmt.Printf("WARN   %s: %s\n", catalogue.RefOf(feature), reason)
+		}
+	}
+
+	if blocked {
+		return errUnsatisfied
+	}
+
+	fmt.Printf("no feature blocks an upgrade to Kubernetes %s\n", target)
+	return nil
+}
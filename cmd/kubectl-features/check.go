@@ -0,0 +1,116 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/resolver"
+)
+
+var checkFile string
+
+var checkCommand = command{
+	usage:       "check <feature>[@<range>]|kind:<kind>[@<range>]...",
+	description: "Checks that the required features are provisioned in the given version ranges.",
+	run:         runCheck,
+	flags: func(fs *flag.FlagSet) {
+		fs.StringVar(&checkFile, "filename", "", "File with one requirement per line. Lines starting with # are ignored.")
+		fs.StringVar(&checkFile, "f", "", "Shorthand for --filename.")
+	},
+}
+
+func runCheck(ctx context.Context, opts *options, args []string) error {
+	if checkFile != "" {
+		lines, err := readRequirements(checkFile)
+		if err != nil {
+			return err
+		}
+
+		args = append(args, lines...)
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("no requirements given")
+	}
+
+	requirements := make([]featuresv1alpha1.RequiredFeature, len(args))
+	for i, arg := range args {
+		r, err := resolver.ParseRequire(arg)
+		if err != nil {
+			return err
+		}
+		if r.Name != "" && r.Namespace == "" {
+			r.Namespace = opts.defaultNamespace()
+		}
+
+		requirements[i] = r
+	}
+
+	cat, err := opts.catalogue(ctx)
+	if err != nil {
+		return err
+	}
+
+	features := make([]featuresv1alpha1.InstalledFeature, 0)
+	for _, feature := range cat.Features() {
+		features = append(features, *feature)
+	}
+
+	satisfied := true
+	for _, r := range requirements {
+		unsatisfied := resolver.UnsatisfiedRequires(features, opts.defaultNamespace(), []featuresv1alpha1.RequiredFeature{r}, true)
+		if len(unsatisfied) > 0 {
+			satisfied = false
+			fmt.Printf("FAIL %s: %s\n", unsatisfied[0].RequiredFeature, unsatisfied[0].Reason)
+		} else {
+			fmt.Printf("OK   %s\n", r)
+		}
+	}
+
+	if !satisfied {
+		return errUnsatisfied
+	}
+	return nil
+}
+
+func readRequirements(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	result := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		result = append(result, line)
+	}
+
+	return result, scanner.Err()
+}
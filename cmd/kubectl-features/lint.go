@@ -0,0 +1,60 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/lint"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var lintCommand = command{
+	usage:       "lint <file or directory>...",
+	description: "Checks feature manifests without a cluster for dangling dependencies, conflicts, cycles and versions.",
+	run:         runLint,
+}
+
+func runLint(_ context.Context, opts *options, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no manifests given")
+	}
+
+	namespace := opts.namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	manifests, err := lint.LoadManifests(namespace, args...)
+	if err != nil {
+		return err
+	}
+
+	report, err := lint.Lint(manifests, logf.NullLogger{})
+	if err != nil {
+		return err
+	}
+
+	report.Print(os.Stdout)
+
+	if report.HasErrors() {
+		return errUnsatisfied
+	}
+	return nil
+}
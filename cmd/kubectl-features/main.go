@@ -0,0 +1,225 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// kubectl-features is a kubectl plugin to inspect the feature catalogue of a cluster.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/catalogue"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// exitUnsatisfied is returned when the cluster does not satisfy the checked requirements.
+	exitUnsatisfied = 1
+	// exitError is returned on invalid usage or when the cluster could not be read.
+	exitError = 2
+)
+
+// errUnsatisfied is returned by commands whose checks failed. The reasons have already been printed.
+var errUnsatisfied = errors.New("requirements are not satisfied")
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(featuresv1alpha1.AddToScheme(scheme))
+}
+
+// command is a single subcommand of the plugin.
+type command struct {
+	usage       string
+	description string
+	run         func(ctx context.Context, opts *options, args []string) error
+	flags       func(fs *flag.FlagSet)
+}
+
+var commands = map[string]command{
+	"tree":     treeCommand,
+	"why":      whyCommand,
+	"rdeps":    rdepsCommand,
+	"check":    checkCommand,
+	"lint":     lintCommand,
+	"resync":   resyncCommand,
+	"blockers": blockersCommand,
+	"plan":     planCommand,
+	"order":    orderCommand,
+}
+
+// options are the flags shared by all commands.
+type options struct {
+	kubeconfig string
+	context    string
+	namespace  string
+}
+
+func (o *options) bind(fs *flag.FlagSet) {
+	fs.StringVar(&o.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to use.")
+	fs.StringVar(&o.context, "context", "", "The name of the kubeconfig context to use.")
+	fs.StringVar(&o.namespace, "namespace", "", "The namespace of features given without namespace.")
+	fs.StringVar(&o.namespace, "n", "", "Shorthand for --namespace.")
+}
+
+func (o *options) clientConfig() clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = o.kubeconfig
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: o.context})
+}
+
+// defaultNamespace returns the namespace given by flag or the namespace of the current kubeconfig context.
+func (o *options) defaultNamespace() string {
+	if o.namespace != "" {
+		return o.namespace
+	}
+
+	namespace, _, err := o.clientConfig().Namespace()
+	if err != nil || namespace == "" {
+		return "default"
+	}
+
+	return namespace
+}
+
+// ref parses a feature given as "namespace/name" or "name".
+func (o *options) ref(arg string) featuresv1alpha1.InstalledFeatureRef {
+	if i := strings.IndexRune(arg, featuresv1alpha1.Separator); i >= 0 {
+		return featuresv1alpha1.InstalledFeatureRef{Namespace: arg[:i], Name: arg[i+1:]}
+	}
+
+	return featuresv1alpha1.InstalledFeatureRef{Namespace: o.defaultNamespace(), Name: arg}
+}
+
+// client creates a client for the cluster of the kubeconfig.
+func (o *options) client() (client.Client, error) {
+	cfg, err := o.clientConfig().ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.New(cfg, client.Options{Scheme: scheme})
+}
+
+// catalogue loads all features and groups of the cluster.
+func (o *options) catalogue(ctx context.Context) (*catalogue.Catalogue, error) {
+	c, err := o.client()
+	if err != nil {
+		return nil, err
+	}
+
+	return catalogue.Load(ctx, c)
+}
+
+// parse parses the flags of a command. In contrast to flag.Parse flags may be given after positional arguments, as
+// kubectl users are used to.
+func parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := make([]string, 0)
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "Usage: kubectl features <command> [flags]\n\nCommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-40s %s\n", commands[name].usage, commands[name].description)
+	}
+	fmt.Fprintf(os.Stderr, "\nUse \"kubectl features <command> --help\" for the flags of a command.\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitError)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(exitError)
+	}
+
+	opts := &options{}
+	fs := flag.NewFlagSet(os.Args[1], flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: kubectl features %s\n\n%s\n\nFlags:\n", cmd.usage, cmd.description)
+		fs.PrintDefaults()
+	}
+	opts.bind(fs)
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+
+	args, err := parse(fs, os.Args[2:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(exitError)
+	}
+
+	err = cmd.run(context.Background(), opts, args)
+	if err == errUnsatisfied {
+		os.Exit(exitUnsatisfied)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitError)
+	}
+}
+
+// describe returns a short description of the state of a feature.
+func describe(feature *featuresv1alpha1.InstalledFeature) string {
+	if feature == nil {
+		return "not installed"
+	}
+
+	phase := feature.Status.Phase
+	if phase == "" {
+		phase = "unknown"
+	}
+	if feature.DeletionTimestamp != nil {
+		phase = "deleting"
+	}
+
+	return fmt.Sprintf("%s, %s", feature.Spec.Version, phase)
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/catalogue"
+)
+
+var (
+	orderTeardown bool
+	orderOutput   string
+)
+
+var orderCommand = command{
+	usage:       "order",
+	description: "Lists the waves to install the features in, dependencies first, and the dependency cycles.",
+	run:         runOrder,
+	flags: func(fs *flag.FlagSet) {
+		fs.BoolVar(&orderTeardown, "teardown", false, "Lists the waves to remove the features in, dependents first.")
+		fs.StringVar(&orderOutput, "output", catalogue.FormatText, "The output format: text, json or yaml.")
+		fs.StringVar(&orderOutput, "o", catalogue.FormatText, "Shorthand for --output.")
+	},
+}
+
+func runOrder(ctx context.Context, opts *options, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("no arguments expected, got %d", len(args))
+	}
+
+	cat, err := opts.catalogue(ctx)
+	if err != nil {
+		return err
+	}
+
+	order := cat.InstallOrder()
+	if orderTeardown {
+		order = cat.TeardownOrder()
+	}
+
+	if err := order.Write(os.Stdout, orderOutput); err != nil {
+		return err
+	}
+
+	if len(order.Cycles) > 0 {
+		return errUnsatisfied
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/planner"
+	"sigs.k8s.io/yaml"
+)
+
+var planFile string
+
+var planCommand = command{
+	usage:       "plan -f <file>",
+	description: "Computes a safe order to upgrade the features to the target versions of an UpgradePlan.",
+	run:         runPlan,
+	flags: func(fs *flag.FlagSet) {
+		fs.StringVar(&planFile, "filename", "", "An UpgradePlan manifest or a YAML file with the targets of one.")
+		fs.StringVar(&planFile, "f", "", "Shorthand for --filename.")
+	},
+}
+
+func runPlan(ctx context.Context, opts *options, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("no arguments expected, got %d", len(args))
+	}
+	if planFile == "" {
+		return fmt.Errorf("no plan given")
+	}
+
+	plan, err := readPlan(planFile)
+	if err != nil {
+		return err
+	}
+	if plan.Namespace == "" {
+		plan.Namespace = opts.defaultNamespace()
+	}
+
+	cat, err := opts.catalogue(ctx)
+	if err != nil {
+		return err
+	}
+
+	features := make([]featuresv1alpha1.InstalledFeature, 0)
+	for _, feature := range cat.Features() {
+		features = append(features, *feature)
+	}
+
+	status := planner.Plan(features, plan.Namespace, plan.Spec.Targets)
+	for i, step := range status.Steps {
+		fmt.Printf("%3d. %s/%s %s -> %s\n", i+1, step.Namespace, step.Name, step.From, step.To)
+	}
+	for _, blocked := range status.Blocked {
+		for _, reason := range blocked.Reasons {
+			fmt.Printf("BLOCKED %s/%s@%s: %s\n", blocked.Namespace, blocked.Name, blocked.Version, reason)
+		}
+	}
+
+	if status.Phase == featuresv1alpha1.PhaseBlocked {
+		return errUnsatisfied
+	}
+
+	fmt.Println(status.Message)
+	return nil
+}
+
+// readPlan reads an UpgradePlan manifest. Files without kind contain the spec of the plan only.
+func readPlan(filename string) (*featuresv1alpha1.UpgradePlan, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &featuresv1alpha1.UpgradePlan{}
+	if err := yaml.Unmarshal(data, plan); err != nil {
+		return nil, err
+	}
+	if plan.Kind == "" {
+		if err := yaml.UnmarshalStrict(data, &plan.Spec); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
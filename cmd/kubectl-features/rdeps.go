@@ -0,0 +1,81 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/catalogue"
+)
+
+var rdepsRecursive bool
+
+var rdepsCommand = command{
+	usage:       "rdeps <feature>",
+	description: "Lists the features depending on a feature.",
+	run:         runRdeps,
+	flags: func(fs *flag.FlagSet) {
+		fs.BoolVar(&rdepsRecursive, "recursive", false, "Lists the transitive dependents, too.")
+		fs.BoolVar(&rdepsRecursive, "r", false, "Shorthand for --recursive.")
+	},
+}
+
+func runRdeps(ctx context.Context, opts *options, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("exactly one feature expected, got %d", len(args))
+	}
+
+	cat, err := opts.catalogue(ctx)
+	if err != nil {
+		return err
+	}
+
+	ref := opts.ref(args[0])
+	seen := map[string]bool{ref.String(): true}
+	result := make([]featuresv1alpha1.InstalledFeatureRef, 0)
+
+	queue := []featuresv1alpha1.InstalledFeatureRef{ref}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, dependent := range cat.Dependents(current) {
+			if seen[dependent.String()] {
+				continue
+			}
+			seen[dependent.String()] = true
+
+			result = append(result, dependent)
+			if rdepsRecursive {
+				queue = append(queue, dependent)
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return catalogue.Less(result[i], result[j])
+	})
+
+	for _, dependent := range result {
+		fmt.Printf("%s (%s)\n", dependent, describe(cat.Feature(dependent)))
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/consistency"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var resyncRepair bool
+
+var resyncCommand = command{
+	usage:       "resync",
+	description: "Recomputes the status relationships of all features and groups and reports the drifts found.",
+	run:         runResync,
+	flags: func(fs *flag.FlagSet) {
+		fs.BoolVar(&resyncRepair, "repair", false, "Patch the status of every drifted object.")
+	},
+}
+
+func runResync(ctx context.Context, opts *options, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("resync takes no arguments")
+	}
+
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+
+	checker := &consistency.Checker{
+		Client: &controllers.OcpClientProd{Client: c},
+		Log:    logf.NullLogger{},
+	}
+	report, err := checker.Run(ctx, resyncRepair)
+	if err != nil {
+		return err
+	}
+
+	for _, drift := range report.Drifts {
+		fmt.Fprintln(os.Stdout, drift)
+	}
+	fmt.Fprintf(os.Stdout, "%d features, %d groups: %d drifts, %d objects repaired\n",
+		report.Features, report.Groups, len(report.Drifts), report.Repaired)
+
+	if len(report.Drifts) > 0 && !resyncRepair {
+		return errUnsatisfied
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/catalogue"
+)
+
+var treeCommand = command{
+	usage:       "tree <feature>",
+	description: "Prints the dependency tree of a feature.",
+	run:         runTree,
+}
+
+func runTree(ctx context.Context, opts *options, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("exactly one feature expected, got %d", len(args))
+	}
+
+	cat, err := opts.catalogue(ctx)
+	if err != nil {
+		return err
+	}
+
+	printTree(os.Stdout, cat.Tree(opts.ref(args[0])), "", "")
+	return nil
+}
+
+func printTree(out io.Writer, node *catalogue.Node, prefix string, childPrefix string) {
+	suffix := ""
+	if node.Cycle {
+		suffix = " [cycle]"
+	}
+	fmt.Fprintf(out, "%s%s (%s)%s\n", prefix, node.Ref, describe(node.Feature), suffix)
+
+	for i, child := range node.Children {
+		if i == len(node.Children)-1 {
+			printTree(out, child, childPrefix+"└── ", childPrefix+"    ")
+		} else {
+			printTree(out, child, childPrefix+"├── ", childPrefix+"│   ")
+		}
+	}
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/catalogue"
+)
+
+var whyCommand = command{
+	usage:       "why <feature>",
+	description: "Explains why a feature is pending or failed.",
+	run:         runWhy,
+}
+
+func runWhy(ctx context.Context, opts *options, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("exactly one feature expected, got %d", len(args))
+	}
+
+	cat, err := opts.catalogue(ctx)
+	if err != nil {
+		return err
+	}
+
+	explain(os.Stdout, cat, opts.ref(args[0]), "", 0, make(map[string]bool))
+	return nil
+}
+
+// explain prints the state of the feature and walks its missing dependencies and conflicting features.
+func explain(out io.Writer, cat *catalogue.Catalogue, ref featuresv1alpha1.InstalledFeatureRef, reason string, depth int, visited map[string]bool) {
+	indent := strings.Repeat("  ", depth)
+	feature := cat.Feature(ref)
+
+	if feature == nil {
+		fmt.Fprintf(out, "%s%s%s is not installed\n", indent, reason, ref)
+		return
+	}
+
+	state := feature.Status.Phase
+	if state == "" {
+		state = "not reconciled yet"
+	}
+	if feature.Status.Message != "" {
+		state = fmt.Sprintf("%s: %s", state, feature.Status.Message)
+	}
+	if feature.Status.Phase == featuresv1alpha1.PhaseProvisioned && depth == 0 {
+		state = "provisioned - nothing to explain"
+	}
+
+	if visited[ref.String()] {
+		fmt.Fprintf(out, "%s%s%s is %s (see above)\n", indent, reason, ref, state)
+		return
+	}
+	visited[ref.String()] = true
+
+	fmt.Fprintf(out, "%s%s%s is %s\n", indent, reason, ref, state)
+
+	for _, dependency := range feature.Status.MissingDependencies {
+		explain(out, cat, dependency, "missing dependency ", depth+1, visited)
+	}
+
+	for _, conflict := range feature.Status.ConflictingFeatures {
+		explain(out, cat, conflict, "conflicting feature ", depth+1, visited)
+	}
+}
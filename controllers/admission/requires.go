@@ -0,0 +1,179 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package admission contains the admission gate of workloads: Pods and Deployments annotated with the features they
+// require are rejected or warned about when the features are missing, not provisioned or out of the version range.
+package admission
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/resolver"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Path is the path the requires webhook is served at.
+const Path = "/validate-requires"
+
+// The policies of namespaces, set with the label features.kaiserpfalz-edv.de/requires-policy.
+const (
+	// PolicyEnforce rejects workloads with unsatisfied requirements.
+	PolicyEnforce = "enforce"
+	// PolicyWarn admits workloads with unsatisfied requirements, but logs them and adds them as audit annotation.
+	PolicyWarn = "warn"
+	// PolicyOff does not check the requirements.
+	PolicyOff = "off"
+)
+
+// AuditAnnotationUnsatisfied is the audit annotation listing the unsatisfied requirements of admitted workloads.
+const AuditAnnotationUnsatisfied = "unsatisfied-requirements"
+
+// +kubebuilder:webhook:path=/validate-requires,mutating=false,failurePolicy=ignore,groups="";apps,resources=pods;deployments,verbs=create;update,versions=v1,name=requires.features.kaiserpfalz-edv.de
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=features.kaiserpfalz-edv.de,resources=installedfeatures,verbs=get;list;watch
+
+// RequiresValidator checks the features required by the annotation features.kaiserpfalz-edv.de/requires of Pods and
+// Deployments. The features have to be installed, provisioned and match the version range.
+type RequiresValidator struct {
+	Client client.Reader
+	Log    logr.Logger
+
+	// DefaultPolicy is the policy of namespaces without policy label.
+	DefaultPolicy string
+
+	decoder *admission.Decoder
+}
+
+// InjectDecoder injects the decoder of the admission requests.
+func (v *RequiresValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle checks the requirements of the workload according to the policy of its namespace.
+func (v *RequiresValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	annotations, err := v.annotations(req)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	value, found := annotations[featuresv1alpha1.AnnotationRequires]
+	if !found {
+		return admission.Allowed("")
+	}
+
+	policy, err := v.policy(ctx, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if policy == PolicyOff {
+		return admission.Allowed("requirements are not checked in this namespace")
+	}
+
+	reasons, err := v.check(ctx, req.Namespace, value)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if len(reasons) == 0 {
+		return admission.Allowed("all required features are provisioned")
+	}
+
+	message := fmt.Sprintf("required features are not satisfied: %s", strings.Join(reasons, "; "))
+	reqLogger := v.Log.WithValues("kind", req.Kind.Kind, "workload", types.NamespacedName{Namespace: req.Namespace, Name: req.Name})
+	if policy == PolicyEnforce {
+		reqLogger.Info("rejecting workload", "reasons", reasons)
+
+		return admission.Denied(message)
+	}
+
+	reqLogger.Info("admitting workload with unsatisfied requirements", "reasons", reasons)
+
+	response := admission.Allowed(message)
+	response.AuditAnnotations = map[string]string{AuditAnnotationUnsatisfied: strings.Join(reasons, "; ")}
+	return response
+}
+
+// annotations returns the annotations of the workload. The annotations of the pod template of Deployments are
+// used when the Deployment itself is not annotated.
+func (v *RequiresValidator) annotations(req admission.Request) (map[string]string, error) {
+	switch req.Kind.Kind {
+	case "Pod":
+		pod := &corev1.Pod{}
+		if err := v.decoder.Decode(req, pod); err != nil {
+			return nil, err
+		}
+
+		return pod.Annotations, nil
+	case "Deployment":
+		deployment := &appsv1.Deployment{}
+		if err := v.decoder.Decode(req, deployment); err != nil {
+			return nil, err
+		}
+
+		if _, found := deployment.Annotations[featuresv1alpha1.AnnotationRequires]; found {
+			return deployment.Annotations, nil
+		}
+		return deployment.Spec.Template.Annotations, nil
+	default:
+		return nil, nil
+	}
+}
+
+// policy returns the policy of the namespace.
+func (v *RequiresValidator) policy(ctx context.Context, name string) (string, error) {
+	namespace := &corev1.Namespace{}
+	if err := v.Client.Get(ctx, types.NamespacedName{Name: name}, namespace); err != nil {
+		return "", err
+	}
+
+	switch policy := namespace.Labels[featuresv1alpha1.LabelRequiresPolicy]; policy {
+	case PolicyEnforce, PolicyWarn, PolicyOff:
+		return policy, nil
+	case "":
+		return v.DefaultPolicy, nil
+	default:
+		v.Log.Info("unknown requires policy, using the default", "namespace", name, "policy", policy)
+		return v.DefaultPolicy, nil
+	}
+}
+
+// check returns the reasons why the requirements are not satisfied. An invalid annotation is a reason, too.
+func (v *RequiresValidator) check(ctx context.Context, namespace string, value string) ([]string, error) {
+	requires, err := resolver.ParseRequires(value)
+	if err != nil {
+		return []string{fmt.Sprintf("invalid annotation %s: %v", featuresv1alpha1.AnnotationRequires, err)}, nil
+	}
+
+	features := &featuresv1alpha1.InstalledFeatureList{}
+	if err := v.Client.List(ctx, features); err != nil {
+		return nil, err
+	}
+
+	var reasons []string
+	for _, unsatisfied := range resolver.UnsatisfiedRequires(features.Items, namespace, requires, true) {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", unsatisfied.RequiredFeature, unsatisfied.Reason))
+	}
+
+	return reasons, nil
+}
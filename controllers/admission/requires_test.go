@@ -0,0 +1,167 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admission_test
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/controllers/admission"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("Requires admission gate", func() {
+	const namespace = "apps"
+
+	var (
+		ctx = context.Background()
+
+		sut *RequiresValidator
+	)
+
+	setup := func(policy string, objs ...runtime.Object) {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).Should(Succeed())
+		Expect(AddToScheme(scheme)).Should(Succeed())
+
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		if policy != "" {
+			ns.Labels = map[string]string{LabelRequiresPolicy: policy}
+		}
+
+		decoder, err := admission.NewDecoder(scheme)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		sut = &RequiresValidator{
+			Client:        fake.NewFakeClientWithScheme(scheme, append(objs, ns)...),
+			Log:           logf.Log,
+			DefaultPolicy: PolicyWarn,
+		}
+		Expect(sut.InjectDecoder(decoder)).Should(Succeed())
+	}
+
+	feature := func(name, version, phase string) *InstalledFeature {
+		return &InstalledFeature{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: name},
+			Spec:       InstalledFeatureSpec{Kind: name, Version: version},
+			Status:     InstalledFeatureStatus{Phase: phase},
+		}
+	}
+
+	request := func(obj runtime.Object, kind string) admission.Request {
+		raw, err := json.Marshal(obj)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		return admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: kind},
+			Namespace: namespace,
+			Name:      "my-app",
+			Operation: admissionv1beta1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		}}
+	}
+
+	pod := func(requires string) admission.Request {
+		obj := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "my-app"}}
+		if requires != "" {
+			obj.Annotations = map[string]string{AnnotationRequires: requires}
+		}
+
+		return request(obj, "Pod")
+	}
+
+	It("should admit workloads without requirements", func() {
+		setup(PolicyEnforce)
+
+		Expect(sut.Handle(ctx, pod("")).Allowed).Should(BeTrue())
+	})
+
+	It("should admit workloads whose required features are provisioned", func() {
+		setup(PolicyEnforce, feature("postgres-operator", "1.6.0", PhaseProvisioned))
+
+		response := sut.Handle(ctx, pod("platform/postgres-operator@>=1.5.0"))
+
+		Expect(response.Allowed).Should(BeTrue())
+	})
+
+	It("should reject missing, unprovisioned and mismatching features when enforced", func() {
+		setup(PolicyEnforce,
+			feature("postgres-operator", "1.4.2", PhaseProvisioned),
+			feature("cert-manager", "1.0.0", PhasePending),
+		)
+
+		response := sut.Handle(ctx, pod("platform/postgres-operator@>=1.5.0; platform/cert-manager\nmonitoring"))
+
+		Expect(response.Allowed).Should(BeFalse())
+		Expect(string(response.Result.Reason)).Should(Equal("required features are not satisfied: " +
+			"platform/postgres-operator@>=1.5.0: version '1.4.2' is not in range '>=1.5.0'; " +
+			"platform/cert-manager: not provisioned, phase is 'pending'; " +
+			"apps/monitoring: not installed"))
+	})
+
+	It("should admit unsatisfied workloads with an audit annotation when warning", func() {
+		setup(PolicyWarn)
+
+		response := sut.Handle(ctx, pod("kind:dns"))
+
+		Expect(response.Allowed).Should(BeTrue())
+		Expect(response.AuditAnnotations).Should(Equal(map[string]string{
+			AuditAnnotationUnsatisfied: "kind dns: no feature of this kind installed",
+		}))
+	})
+
+	It("should use the default policy for namespaces without label", func() {
+		setup("")
+		sut.DefaultPolicy = PolicyEnforce
+
+		Expect(sut.Handle(ctx, pod("monitoring")).Allowed).Should(BeFalse())
+	})
+
+	It("should not check namespaces with the policy off", func() {
+		setup(PolicyOff)
+
+		Expect(sut.Handle(ctx, pod("monitoring")).Allowed).Should(BeTrue())
+	})
+
+	It("should check the pod template of deployments", func() {
+		setup(PolicyEnforce)
+		deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "my-app"}}
+		deployment.Spec.Template.Annotations = map[string]string{AnnotationRequires: "monitoring"}
+
+		Expect(sut.Handle(ctx, request(deployment, "Deployment")).Allowed).Should(BeFalse())
+	})
+
+	It("should reject invalid annotations when enforced", func() {
+		setup(PolicyEnforce)
+
+		response := sut.Handle(ctx, pod("monitoring@>=x.y"))
+
+		Expect(response.Allowed).Should(BeFalse())
+		Expect(string(response.Result.Reason)).Should(ContainSubstring("invalid annotation"))
+	})
+})
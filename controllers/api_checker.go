@@ -0,0 +1,116 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// APIChecker checks the APIs provided by features against the cluster.
+type APIChecker interface {
+	// MissingAPIs returns the given APIs not served by the cluster.
+	MissingAPIs(ctx context.Context, apis []v1alpha1.ProvidedAPI) ([]v1alpha1.MissingAPI, error)
+}
+
+var _ APIChecker = &APICheckerProd{}
+
+// APICheckerProd checks APIs provided by CRDs against the CRDs and all other APIs against the discovery API.
+type APICheckerProd struct {
+	Client    client.Reader
+	Discovery discovery.ServerResourcesInterface
+}
+
+func (a APICheckerProd) MissingAPIs(ctx context.Context, apis []v1alpha1.ProvidedAPI) ([]v1alpha1.MissingAPI, error) {
+	if len(apis) == 0 {
+		return nil, nil
+	}
+
+	crds := &apiextensionsv1.CustomResourceDefinitionList{}
+	if err := a.Client.List(ctx, crds); err != nil {
+		return nil, err
+	}
+
+	var result []v1alpha1.MissingAPI
+	for _, api := range apis {
+		reason, err := a.check(crds.Items, api)
+		if err != nil {
+			return nil, err
+		}
+
+		if reason != "" {
+			result = append(result, v1alpha1.MissingAPI{ProvidedAPI: api, Reason: reason})
+		}
+	}
+
+	return result, nil
+}
+
+// check returns why the API is missing or "" if it is served.
+func (a APICheckerProd) check(crds []apiextensionsv1.CustomResourceDefinition, api v1alpha1.ProvidedAPI) (string, error) {
+	for i := range crds {
+		crd := &crds[i]
+		if crd.Spec.Group != api.Group || crd.Spec.Names.Kind != api.Kind {
+			continue
+		}
+
+		if !isEstablished(crd) {
+			return fmt.Sprintf("CRD %s is not established", crd.Name), nil
+		}
+		for _, version := range crd.Spec.Versions {
+			if version.Name == api.Version && version.Served {
+				return "", nil
+			}
+		}
+
+		return fmt.Sprintf("CRD %s does not serve version %s", crd.Name, api.Version), nil
+	}
+
+	resources, err := a.Discovery.ServerResourcesForGroupVersion(schema.GroupVersion{Group: api.Group, Version: api.Version}.String())
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "not served", nil
+		}
+
+		return "", err
+	}
+
+	for _, resource := range resources.APIResources {
+		if resource.Kind == api.Kind {
+			return "", nil
+		}
+	}
+
+	return "not served", nil
+}
+
+func isEstablished(crd *apiextensionsv1.CustomResourceDefinition) bool {
+	for _, condition := range crd.Status.Conditions {
+		if condition.Type == apiextensionsv1.Established {
+			return condition.Status == apiextensionsv1.ConditionTrue
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// notFoundDiscovery answers unknown group versions with NotFound like the API server.
+type notFoundDiscovery struct {
+	*fakediscovery.FakeDiscovery
+}
+
+func (d notFoundDiscovery) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	for _, resources := range d.Resources {
+		if resources.GroupVersion == groupVersion {
+			return resources, nil
+		}
+	}
+
+	return nil, errors.NewNotFound(schema.GroupResource{}, groupVersion)
+}
+
+var _ = Describe("API checker", func() {
+	var sut controllers.APICheckerProd
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(apiextensionsv1.AddToScheme(scheme)).Should(Succeed())
+
+		sut = controllers.APICheckerProd{
+			Client: fake.NewFakeClientWithScheme(scheme,
+				crd("certificates.cert-manager.io", "cert-manager.io", "Certificate", true, "v1alpha2", "v1"),
+				crd("issuers.cert-manager.io", "cert-manager.io", "Issuer", false, "v1"),
+			),
+			Discovery: notFoundDiscovery{&fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+				{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods", Kind: "Pod"}}},
+				{GroupVersion: "metrics.k8s.io/v1beta1", APIResources: []metav1.APIResource{{Name: "pods", Kind: "PodMetrics"}}},
+			}}}},
+		}
+	})
+
+	missing := func(apis ...ProvidedAPI) []MissingAPI {
+		result, err := sut.MissingAPIs(context.Background(), apis)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		return result
+	}
+
+	It("should accept served APIs of established CRDs and of the discovery API", func() {
+		Expect(missing(
+			ProvidedAPI{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"},
+			ProvidedAPI{Version: "v1", Kind: "Pod"},
+			ProvidedAPI{Group: "metrics.k8s.io", Version: "v1beta1", Kind: "PodMetrics"},
+		)).Should(BeEmpty())
+	})
+
+	It("should report CRDs not established or not serving the version", func() {
+		Expect(missing(
+			ProvidedAPI{Group: "cert-manager.io", Version: "v1", Kind: "Issuer"},
+			ProvidedAPI{Group: "cert-manager.io", Version: "v1beta1", Kind: "Certificate"},
+		)).Should(Equal([]MissingAPI{
+			{
+				ProvidedAPI: ProvidedAPI{Group: "cert-manager.io", Version: "v1", Kind: "Issuer"},
+				Reason:      "CRD issuers.cert-manager.io is not established",
+			},
+			{
+				ProvidedAPI: ProvidedAPI{Group: "cert-manager.io", Version: "v1beta1", Kind: "Certificate"},
+				Reason:      "CRD certificates.cert-manager.io does not serve version v1beta1",
+			},
+		}))
+	})
+
+	It("should report APIs unknown to the discovery API", func() {
+		Expect(missing(
+			ProvidedAPI{Group: "example.com", Version: "v1", Kind: "Widget"},
+			ProvidedAPI{Version: "v1", Kind: "Widget"},
+		)).Should(Equal([]MissingAPI{
+			{ProvidedAPI: ProvidedAPI{Group: "example.com", Version: "v1", Kind: "Widget"}, Reason: "not served"},
+			{ProvidedAPI: ProvidedAPI{Version: "v1", Kind: "Widget"}, Reason: "not served"},
+		}))
+	})
+
+	It("should format the APIs", func() {
+		Expect(ProvidedAPI{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}.String()).Should(Equal("cert-manager.io/v1 Certificate"))
+		Expect(ProvidedAPI{Version: "v1", Kind: "Pod"}.String()).Should(Equal("v1 Pod"))
+	})
+})
+
+func crd(name string, group string, kind string, established bool, versions ...string) *apiextensionsv1.CustomResourceDefinition {
+	result := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: group,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{Kind: kind},
+		},
+	}
+	for _, version := range versions {
+		result.Spec.Versions = append(result.Spec.Versions, apiextensionsv1.CustomResourceDefinitionVersion{Name: version, Served: true})
+	}
+
+	status := apiextensionsv1.ConditionFalse
+	if established {
+		status = apiextensionsv1.ConditionTrue
+	}
+	result.Status.Conditions = []apiextensionsv1.CustomResourceDefinitionCondition{
+		{Type: apiextensionsv1.Established, Status: status},
+	}
+
+	return result
+}
@@ -0,0 +1,282 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package apiserverdiscovery is the discovery source of the kinds served by the API server and of its enabled feature
+// gates. Every served resource kind in every served version is published as a feature, e.g.
+// "poddisruptionbudget.v1.policy" for "API policy/v1 PodDisruptionBudget". The served API versions themselves are
+// published by the platform source, the names of the kinds extend their names. The enabled feature gates are read from the
+// kubernetes_feature_enabled metric of the API server when a metrics URL is configured.
+package apiserverdiscovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/platformdiscovery"
+	"github.com/prometheus/common/expfmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sdiscovery "k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// Source is the name of the discovery source and the value of the discovered-by label of its features.
+	Source = "apiserver"
+
+	// KindAPIResource is the kind of the features of the served resources.
+	KindAPIResource = "APIResource"
+	// KindFeatureGate is the kind of the features of the enabled feature gates.
+	KindFeatureGate = "FeatureGate"
+
+	// MetricFeatureEnabled is the metric of the API server reporting the state of every feature gate.
+	MetricFeatureEnabled = "kubernetes_feature_enabled"
+
+	// MetricsTimeout is the default time to wait for the metrics of the API server.
+	MetricsTimeout = 10 * time.Second
+)
+
+var _ discovery.Discoverer = &Discoverer{}
+
+// Discoverer emits a feature for every served resource and every enabled feature gate.
+type Discoverer struct {
+	Discovery k8sdiscovery.ServerResourcesInterface
+	// MetricsURL is the URL of the metrics of the API server. Empty disables the feature gates.
+	MetricsURL string
+	// HTTPClient reads the metrics.
+	HTTPClient *http.Client
+	// Timeout limits reading the metrics. Zero waits as long as the context of the discovery.
+	Timeout time.Duration
+	Log     logr.Logger
+
+	// served are the features of the kinds of every API version of the last discovery. They are published again
+	// while the API server fails to discover their group, so the features do not vanish with an unavailable
+	// aggregated API. Discover is only called by the loop of the engine, so they are not guarded.
+	served map[schema.GroupVersion][]featuresv1alpha1.InstalledFeature
+}
+
+// New creates the source reading the feature gates from metricsURL. A URL consisting only of a path (e.g.
+// "/metrics") is read from the API server with the credentials of the operator, other URLs without authentication.
+func New(mgr ctrl.Manager, metricsURL string) (discovery.Discoverer, error) {
+	config := mgr.GetConfig()
+
+	discoveryClient, err := k8sdiscovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Discoverer{
+		Discovery:  discoveryClient,
+		MetricsURL: metricsURL,
+		HTTPClient: http.DefaultClient,
+		Timeout:    MetricsTimeout,
+		Log:        ctrl.Log.WithName("discovery").WithName(Source),
+	}
+
+	if strings.HasPrefix(metricsURL, "/") {
+		transport, err := rest.TransportFor(config)
+		if err != nil {
+			return nil, err
+		}
+
+		result.MetricsURL = strings.TrimSuffix(config.Host, "/") + metricsURL
+		result.HTTPClient = &http.Client{Transport: transport}
+	}
+
+	return result, nil
+}
+
+// +kubebuilder:rbac:urls=/metrics,verbs=get
+
+func (d *Discoverer) Name() string {
+	return Source
+}
+
+// Discover returns a feature for every served resource and every enabled feature gate. API groups the API server
+// fails to discover (e.g. an aggregated API whose service is down) keep the features of their last discovery.
+func (d *Discoverer) Discover(ctx context.Context) ([]featuresv1alpha1.InstalledFeature, error) {
+	_, lists, err := d.Discovery.ServerGroupsAndResources()
+	var failed map[schema.GroupVersion]error
+	if err != nil {
+		groupErr, ok := err.(*k8sdiscovery.ErrGroupDiscoveryFailed)
+		if !ok {
+			return nil, err
+		}
+
+		d.Log.Info("some API groups are not available, keeping their last features", "error", err.Error())
+		failed = groupErr.Groups
+	}
+
+	served := make(map[schema.GroupVersion][]featuresv1alpha1.InstalledFeature, len(lists)+len(failed))
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, resource := range list.APIResources {
+			// subresources like "pods/status" are part of their resource
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+
+			served[gv] = append(served[gv], *ResourceFeature(gv.WithKind(resource.Kind)))
+		}
+	}
+	for gv := range failed {
+		if _, ok := served[gv]; !ok {
+			served[gv] = d.served[gv]
+		}
+	}
+	d.served = served
+
+	result := make([]featuresv1alpha1.InstalledFeature, 0)
+	for _, features := range served {
+		result = append(result, features...)
+	}
+
+	if d.MetricsURL != "" {
+		gates, err := d.featureGates(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, gates...)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// ResourceName is the name of the feature of a served kind, "<kind>.<version>.<group>" in lower case, e.g.
+// "cronjob.v1beta1.batch". It is the name of the feature of the API version published by the platform source
+// prefixed with the kind, so the core group is called "core".
+func ResourceName(gvk schema.GroupVersionKind) string {
+	return strings.ToLower(gvk.Kind + "." + platformdiscovery.APIFeature(gvk.Group, gvk.Version).Name)
+}
+
+// ResourceFeature returns the feature of a served kind. Its version is the API version.
+func ResourceFeature(gvk schema.GroupVersionKind) *featuresv1alpha1.InstalledFeature {
+	return &featuresv1alpha1.InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: ResourceName(gvk),
+			Annotations: map[string]string{
+				featuresv1alpha1.AnnotationDiscoveredFrom: gvk.GroupVersion().String() + "/" + gvk.Kind,
+			},
+		},
+		Spec: featuresv1alpha1.InstalledFeatureSpec{
+			Kind:        KindAPIResource,
+			Version:     gvk.Version,
+			Description: "API " + gvk.GroupVersion().String() + " " + gvk.Kind,
+		},
+	}
+}
+
+// FeatureGateName is the name of the feature of a feature gate, "featuregate.<gate>" in lower case.
+func FeatureGateName(gate string) string {
+	return "featuregate." + strings.ToLower(gate)
+}
+
+// FeatureGateFeature returns the feature of an enabled feature gate. The stage (alpha, beta or empty for GA) is its
+// version.
+func FeatureGateFeature(gate string, stage string) *featuresv1alpha1.InstalledFeature {
+	description := "Feature gate " + gate
+	if stage != "" {
+		description += " (" + stage + ")"
+	}
+
+	return &featuresv1alpha1.InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: FeatureGateName(gate),
+			Annotations: map[string]string{
+				featuresv1alpha1.AnnotationDiscoveredFrom: MetricFeatureEnabled + "/" + gate,
+			},
+		},
+		Spec: featuresv1alpha1.InstalledFeatureSpec{
+			Kind:        KindFeatureGate,
+			Version:     strings.ToLower(stage),
+			Description: description,
+		},
+	}
+}
+
+// featureGates reads the metrics of the API server and returns a feature for every enabled feature gate.
+func (d *Discoverer) featureGates(ctx context.Context) ([]featuresv1alpha1.InstalledFeature, error) {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, d.MetricsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("reading the metrics of the API server from %s: %s", d.MetricsURL, resp.Status)
+	}
+
+	families, err := (&expfmt.TextParser{}).TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	family, ok := families[MetricFeatureEnabled]
+	if !ok {
+		return nil, nil
+	}
+
+	var result []featuresv1alpha1.InstalledFeature
+	for _, metric := range family.GetMetric() {
+		if metric.GetGauge().GetValue() != 1 {
+			continue
+		}
+
+		var gate, stage string
+		for _, label := range metric.GetLabel() {
+			switch label.GetName() {
+			case "name":
+				gate = label.GetValue()
+			case "stage":
+				stage = label.GetValue()
+			}
+		}
+
+		if gate != "" {
+			result = append(result, *FeatureGateFeature(gate, stage))
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package apiserverdiscovery_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/apiserverdiscovery"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sdiscovery "k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const metrics = `# HELP kubernetes_feature_enabled [ALPHA] This metric records the data about the stage and enablement of a k8s feature.
+# TYPE kubernetes_feature_enabled gauge
+kubernetes_feature_enabled{name="APIPriorityAndFairness",stage="BETA"} 1
+kubernetes_feature_enabled{name="CSIMigration",stage=""} 1
+kubernetes_feature_enabled{name="InPlacePodVerticalScaling",stage="ALPHA"} 0
+# HELP apiserver_request_total Counter of apiserver requests.
+# TYPE apiserver_request_total counter
+apiserver_request_total{code="200",verb="GET"} 42
+`
+
+// partialDiscovery fails to discover the aggregated metrics API.
+type partialDiscovery struct {
+	*fakediscovery.FakeDiscovery
+}
+
+func (d partialDiscovery) ServerGroupsAndResources() ([]*metav1.APIGroup, []*metav1.APIResourceList, error) {
+	metricsAPI := schema.GroupVersion{Group: "metrics.k8s.io", Version: "v1beta1"}
+	groups, resources, _ := d.FakeDiscovery.ServerGroupsAndResources()
+
+	available := make([]*metav1.APIResourceList, 0, len(resources))
+	for _, list := range resources {
+		if list.GroupVersion != metricsAPI.String() {
+			available = append(available, list)
+		}
+	}
+
+	return groups, available, &k8sdiscovery.ErrGroupDiscoveryFailed{
+		Groups: map[schema.GroupVersion]error{metricsAPI: fmt.Errorf("service unavailable")},
+	}
+}
+
+var _ = Describe("API server discovery source", func() {
+	var (
+		apiServer *httptest.Server
+		served    *fakediscovery.FakeDiscovery
+		sut       *apiserverdiscovery.Discoverer
+	)
+
+	BeforeEach(func() {
+		apiServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/metrics" {
+				http.NotFound(w, req)
+				return
+			}
+
+			_, _ = w.Write([]byte(metrics))
+		}))
+
+		served = &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+			{GroupVersion: "v1", APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod"},
+				{Name: "pods/status", Kind: "Pod"},
+			}},
+			{GroupVersion: "batch/v1beta1", APIResources: []metav1.APIResource{{Name: "cronjobs", Kind: "CronJob"}}},
+			{GroupVersion: "policy/v1", APIResources: []metav1.APIResource{{Name: "poddisruptionbudgets", Kind: "PodDisruptionBudget"}}},
+		}}}
+
+		sut = &apiserverdiscovery.Discoverer{
+			Discovery:  served,
+			HTTPClient: apiServer.Client(),
+			Log:        logf.NullLogger{},
+		}
+	})
+
+	AfterEach(func() {
+		apiServer.Close()
+	})
+
+	discover := func() map[string]InstalledFeature {
+		features, err := sut.Discover(context.Background())
+		Expect(err).ShouldNot(HaveOccurred())
+
+		result := make(map[string]InstalledFeature, len(features))
+		for _, feature := range features {
+			result[feature.Name] = feature
+		}
+
+		return result
+	}
+
+	It("should publish every served kind without subresources", func() {
+		features := discover()
+
+		Expect(features).Should(HaveLen(3))
+		Expect(features).Should(HaveKey("pod.v1.core"))
+		Expect(features).Should(HaveKey("cronjob.v1beta1.batch"))
+		Expect(features["poddisruptionbudget.v1.policy"].Spec).Should(Equal(InstalledFeatureSpec{
+			Kind:        apiserverdiscovery.KindAPIResource,
+			Version:     "v1",
+			Description: "API policy/v1 PodDisruptionBudget",
+		}))
+		Expect(features["poddisruptionbudget.v1.policy"].Annotations).Should(HaveKeyWithValue(AnnotationDiscoveredFrom, "policy/v1/PodDisruptionBudget"))
+	})
+
+	It("should publish the enabled feature gates from the metrics", func() {
+		sut.MetricsURL = apiServer.URL + "/metrics"
+
+		features := discover()
+
+		Expect(features).Should(HaveLen(5))
+		Expect(features["featuregate.apipriorityandfairness"].Spec).Should(Equal(InstalledFeatureSpec{
+			Kind:        apiserverdiscovery.KindFeatureGate,
+			Version:     "beta",
+			Description: "Feature gate APIPriorityAndFairness (BETA)",
+		}))
+		Expect(features["featuregate.csimigration"].Spec.Description).Should(Equal("Feature gate CSIMigration"))
+		Expect(features).ShouldNot(HaveKey("featuregate.inplacepodverticalscaling"))
+	})
+
+	It("should fail when the metrics can not be read", func() {
+		sut.MetricsURL = apiServer.URL + "/unknown"
+
+		_, err := sut.Discover(context.Background())
+
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should give up when the metrics are not answered in time", func() {
+		release := make(chan struct{})
+		hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			<-release
+		}))
+		defer hanging.Close()
+		defer close(release)
+
+		sut.MetricsURL = hanging.URL + "/metrics"
+		sut.HTTPClient = hanging.Client()
+		sut.Timeout = 100 * time.Millisecond
+
+		_, err := sut.Discover(context.Background())
+
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should publish the APIs discovered when some groups are not available", func() {
+		sut.Discovery = partialDiscovery{served}
+
+		Expect(discover()).Should(HaveLen(3))
+	})
+
+	It("should keep the features of groups not available since the last discovery", func() {
+		served.Resources = append(served.Resources, &metav1.APIResourceList{
+			GroupVersion: "metrics.k8s.io/v1beta1", APIResources: []metav1.APIResource{{Name: "nodes", Kind: "NodeMetrics"}},
+		})
+		Expect(discover()).Should(HaveKey("nodemetrics.v1beta1.metrics.k8s.io"))
+
+		sut.Discovery = partialDiscovery{served}
+		features := discover()
+
+		Expect(features).Should(HaveLen(4))
+		Expect(features).Should(HaveKey("nodemetrics.v1beta1.metrics.k8s.io"))
+	})
+})
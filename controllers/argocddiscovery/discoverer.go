@@ -0,0 +1,207 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package argocddiscovery is the discovery source of the applications deployed by Argo CD. Every Application deployed
+// into this cluster is turned into a feature in the namespace of the Application. The Applications are read as
+// unstructured objects, so the Argo CD API is not needed to build or run the operator.
+package argocddiscovery
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// Source is the name of the discovery source and the value of the discovered-by label of its features.
+	Source = "argocd"
+
+	// InClusterServer and InClusterName are the destination of Applications deployed into the cluster Argo CD runs in.
+	InClusterServer = "https://kubernetes.default.svc"
+	InClusterName   = "in-cluster"
+)
+
+// The sync, health and operation states of Argo CD Applications.
+const (
+	SyncStatusSynced    = "Synced"
+	SyncStatusOutOfSync = "OutOfSync"
+
+	HealthStatusHealthy     = "Healthy"
+	HealthStatusProgressing = "Progressing"
+	HealthStatusDegraded    = "Degraded"
+	HealthStatusSuspended   = "Suspended"
+	HealthStatusMissing     = "Missing"
+
+	OperationPhaseFailed = "Failed"
+	OperationPhaseError  = "Error"
+)
+
+var (
+	_ discovery.Discoverer = &Discoverer{}
+	_ discovery.Watcher    = &Discoverer{}
+
+	// ApplicationKind is the kind of the Argo CD Applications.
+	ApplicationKind = schema.GroupVersionKind{
+		Group:   "argoproj.io",
+		Version: "v1alpha1",
+		Kind:    "Application",
+	}
+)
+
+// Discoverer emits a feature for every Application synced into this cluster or healthy in it.
+type Discoverer struct {
+	Reader client.Reader
+}
+
+// New is the factory of the source.
+func New(mgr ctrl.Manager) (discovery.Discoverer, error) {
+	return &Discoverer{Reader: mgr.GetClient()}, nil
+}
+
+// +kubebuilder:rbac:groups=argoproj.io,resources=applications,verbs=get;list;watch
+
+func (d *Discoverer) Name() string {
+	return Source
+}
+
+// Watch triggers a sync on every change of an Application.
+func (d *Discoverer) Watch(mgr ctrl.Manager, trigger func()) error {
+	application := &unstructured.Unstructured{}
+	application.SetGroupVersionKind(ApplicationKind)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("argocd-discovery").
+		For(application).
+		Complete(reconcile.Func(func(ctrl.Request) (ctrl.Result, error) {
+			trigger()
+
+			return ctrl.Result{}, nil
+		}))
+}
+
+// Discover returns a feature for every Application deployed into this cluster that is synced or healthy. Applications
+// being deleted, deployed into other clusters or neither synced nor healthy (e.g. out of sync and still progressing)
+// are ignored.
+func (d *Discoverer) Discover(ctx context.Context) ([]featuresv1alpha1.InstalledFeature, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(ApplicationKind.GroupVersion().WithKind(ApplicationKind.Kind + "List"))
+	if err := d.Reader.List(ctx, list); err != nil {
+		return nil, err
+	}
+
+	result := make([]featuresv1alpha1.InstalledFeature, 0, len(list.Items))
+	for i := range list.Items {
+		application := &list.Items[i]
+		if application.GetDeletionTimestamp() != nil || !InCluster(application) || !Deployed(application) {
+			continue
+		}
+
+		result = append(result, *Feature(application))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// InCluster checks if the Application is deployed into the cluster Argo CD runs in.
+func InCluster(application *unstructured.Unstructured) bool {
+	server := nested(application, "spec", "destination", "server")
+	name := nested(application, "spec", "destination", "name")
+
+	return strings.TrimSuffix(server, "/") == InClusterServer || (server == "" && name == InClusterName)
+}
+
+// Deployed checks if the Application is synced or healthy, so the revision it has been synced to runs in the cluster.
+func Deployed(application *unstructured.Unstructured) bool {
+	return nested(application, "status", "sync", "status") == SyncStatusSynced ||
+		nested(application, "status", "health", "status") == HealthStatusHealthy
+}
+
+// Feature returns the feature of the Application. A Helm chart is the kind of the feature, the synced revision (the
+// chart version of Helm sources) its version.
+func Feature(application *unstructured.Unstructured) *featuresv1alpha1.InstalledFeature {
+	kind := nested(application, "spec", "source", "chart")
+	if kind == "" {
+		kind = application.GetName()
+	}
+
+	phase, message := Phase(application)
+
+	return &featuresv1alpha1.InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: application.GetNamespace(),
+			Name:      application.GetName(),
+			Annotations: map[string]string{
+				featuresv1alpha1.AnnotationDiscoveredFrom: ApplicationKind.Kind + "/" + application.GetName(),
+				featuresv1alpha1.AnnotationSourcePhase:    phase,
+				featuresv1alpha1.AnnotationSourceMessage:  message,
+			},
+		},
+		Spec: featuresv1alpha1.InstalledFeatureSpec{
+			Kind:        kind,
+			Version:     nested(application, "status", "sync", "revision"),
+			Provider:    nested(application, "spec", "project"),
+			Description: application.GetAnnotations()["description"],
+			Uri:         nested(application, "spec", "source", "repoURL"),
+		},
+	}
+}
+
+// Phase maps the state of an Application to the phase of a feature. A failed sync operation or a degraded health
+// fail the feature, a healthy or suspended Application is provisioned and all other states (progressing, missing, ...)
+// are initializing. The message contains the sync and health status and the message of the failure.
+func Phase(application *unstructured.Unstructured) (string, string) {
+	sync := nested(application, "status", "sync", "status")
+	health := nested(application, "status", "health", "status")
+	message := "sync: " + sync + ", health: " + health
+
+	operation := nested(application, "status", "operationState", "phase")
+	if operation == OperationPhaseFailed || operation == OperationPhaseError {
+		return featuresv1alpha1.PhaseFailed, message + ", " + nested(application, "status", "operationState", "message")
+	}
+
+	switch health {
+	case HealthStatusDegraded:
+		if healthMessage := nested(application, "status", "health", "message"); healthMessage != "" {
+			message += ", " + healthMessage
+		}
+		return featuresv1alpha1.PhaseFailed, message
+	case HealthStatusHealthy, HealthStatusSuspended:
+		return featuresv1alpha1.PhaseProvisioned, message
+	default:
+		return featuresv1alpha1.PhaseInitializing, message
+	}
+}
+
+func nested(application *unstructured.Unstructured, fields ...string) string {
+	value, _, _ := unstructured.NestedString(application.Object, fields...)
+	return value
+}
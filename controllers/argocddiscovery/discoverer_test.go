@@ -0,0 +1,143 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package argocddiscovery_test
+
+import (
+	"context"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/argocddiscovery"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Argo CD discovery source", func() {
+	discover := func(applications ...runtime.Object) []InstalledFeature {
+		sut := &argocddiscovery.Discoverer{Reader: fake.NewFakeClientWithScheme(scheme, applications...)}
+
+		features, err := sut.Discover(context.Background())
+		Expect(err).ShouldNot(HaveOccurred())
+
+		return features
+	}
+
+	It("should use chart, synced revision, project and repository of a Helm application", func() {
+		features := discover(application("argocd", "ingress", "Synced", "Healthy"))
+
+		Expect(features).Should(HaveLen(1))
+		Expect(features[0].Namespace).Should(Equal("argocd"))
+		Expect(features[0].Name).Should(Equal("ingress"))
+		Expect(features[0].Spec).Should(Equal(InstalledFeatureSpec{
+			Kind:     "ingress-nginx",
+			Version:  "3.4.0",
+			Provider: "platform",
+			Uri:      "https://kubernetes.github.io/ingress-nginx",
+		}))
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationDiscoveredFrom, "Application/ingress"))
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationSourcePhase, PhaseProvisioned))
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationSourceMessage, "sync: Synced, health: Healthy"))
+	})
+
+	It("should name the kind after the application for git sources", func() {
+		git := application("argocd", "billing", "Synced", "Healthy")
+		unstructured.RemoveNestedField(git.Object, "spec", "source", "chart")
+		Expect(unstructured.SetNestedField(git.Object, "main", "spec", "source", "targetRevision")).Should(Succeed())
+		Expect(unstructured.SetNestedField(git.Object, "v1.2.0", "status", "sync", "revision")).Should(Succeed())
+
+		features := discover(git)
+
+		Expect(features[0].Spec.Kind).Should(Equal("billing"))
+		Expect(features[0].Spec.Version).Should(Equal("v1.2.0"))
+	})
+
+	It("should ignore applications of other clusters and applications neither synced nor healthy", func() {
+		remote := application("argocd", "remote", "Synced", "Healthy")
+		Expect(unstructured.SetNestedField(remote.Object, "https://remote.example.com:6443", "spec", "destination", "server")).Should(Succeed())
+		named := application("argocd", "named", "Synced", "Healthy")
+		unstructured.RemoveNestedField(named.Object, "spec", "destination", "server")
+		Expect(unstructured.SetNestedField(named.Object, "in-cluster", "spec", "destination", "name")).Should(Succeed())
+		fresh := application("argocd", "fresh", "", "")
+		outOfSync := application("argocd", "out-of-sync", "OutOfSync", "Progressing")
+		unknown := application("argocd", "unknown", "Unknown", "Missing")
+		healthy := application("argocd", "healthy", "OutOfSync", "Healthy")
+
+		features := discover(remote, named, fresh, outOfSync, unknown, healthy)
+
+		Expect(features).Should(HaveLen(2))
+		Expect(features[0].Name).Should(Equal("healthy"))
+		Expect(features[1].Name).Should(Equal("named"))
+	})
+
+	It("should map sync and health status onto the phase", func() {
+		phase := func(sync string, health string) string {
+			result, _ := argocddiscovery.Phase(application("argocd", "app", sync, health))
+			return result
+		}
+
+		Expect(phase("Synced", "Healthy")).Should(Equal(PhaseProvisioned))
+		Expect(phase("OutOfSync", "Healthy")).Should(Equal(PhaseProvisioned))
+		Expect(phase("Synced", "Suspended")).Should(Equal(PhaseProvisioned))
+		Expect(phase("Synced", "Progressing")).Should(Equal(PhaseInitializing))
+		Expect(phase("OutOfSync", "Missing")).Should(Equal(PhaseInitializing))
+		Expect(phase("Synced", "Degraded")).Should(Equal(PhaseFailed))
+	})
+
+	It("should fail the feature when the sync operation failed", func() {
+		failed := application("argocd", "ingress", "OutOfSync", "Healthy")
+		Expect(unstructured.SetNestedField(failed.Object, "Failed", "status", "operationState", "phase")).Should(Succeed())
+		Expect(unstructured.SetNestedField(failed.Object, "one or more objects failed to apply", "status", "operationState", "message")).Should(Succeed())
+
+		features := discover(failed)
+
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationSourcePhase, PhaseFailed))
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationSourceMessage,
+			"sync: OutOfSync, health: Healthy, one or more objects failed to apply"))
+	})
+})
+
+func application(namespace string, name string, sync string, health string) *unstructured.Unstructured {
+	result := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"project": "platform",
+			"source": map[string]interface{}{
+				"repoURL":        "https://kubernetes.github.io/ingress-nginx",
+				"chart":          "ingress-nginx",
+				"targetRevision": "3.4.0",
+			},
+			"destination": map[string]interface{}{
+				"server":    "https://kubernetes.default.svc",
+				"namespace": "ingress",
+			},
+		},
+	}}
+	result.SetGroupVersionKind(argocddiscovery.ApplicationKind)
+	result.SetNamespace(namespace)
+	result.SetName(name)
+
+	if sync != "" {
+		Expect(unstructured.SetNestedField(result.Object, sync, "status", "sync", "status")).Should(Succeed())
+		Expect(unstructured.SetNestedField(result.Object, "3.4.0", "status", "sync", "revision")).Should(Succeed())
+	}
+	if health != "" {
+		Expect(unstructured.SetNestedField(result.Object, health, "status", "health", "status")).Should(Succeed())
+	}
+
+	return result
+}
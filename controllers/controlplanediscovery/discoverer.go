@@ -0,0 +1,257 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package controlplanediscovery is the discovery source of the Kubernetes version and the core components of the
+// cluster. The server version is read from the discovery API, the versions of DNS, kube-proxy and CNI plugin are the
+// image tags of their workloads in kube-system. Features can then require a Kubernetes version or conflict with
+// kube-proxy like with any other feature.
+package controlplanediscovery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/images"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	k8sversion "k8s.io/apimachinery/pkg/version"
+	k8sdiscovery "k8s.io/client-go/discovery"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// Source is the name of the discovery source and the value of the discovered-by label of its features.
+	Source = "controlplane"
+
+	// SystemNamespace is the namespace of the core components.
+	SystemNamespace = "kube-system"
+
+	// FeatureKubernetes is the name of the feature of the Kubernetes server version.
+	FeatureKubernetes = "kubernetes"
+)
+
+// The kinds of the control plane features.
+const (
+	KindKubernetes = "Kubernetes"
+	KindDNS        = "DNS"
+	KindProxy      = "Proxy"
+	KindCNI        = "CNI"
+)
+
+// Component is a core component running as workload in kube-system.
+type Component struct {
+	// Feature is the name of the feature of the component.
+	Feature string
+	Kind    string
+	// Workload is the kind of the workload, "Deployment" or "DaemonSet".
+	Workload string
+	// Name is the name of the workload. Workloads named "<Name>-<suffix>" match, too (e.g. "kube-flannel-ds-amd64").
+	Name string
+}
+
+var (
+	_ discovery.Discoverer = &Discoverer{}
+	_ discovery.Watcher    = &Discoverer{}
+
+	// Components are the core components known to the source.
+	Components = []Component{
+		{Feature: "coredns", Kind: KindDNS, Workload: "Deployment", Name: "coredns"},
+		{Feature: "kube-dns", Kind: KindDNS, Workload: "Deployment", Name: "kube-dns"},
+		{Feature: "kube-proxy", Kind: KindProxy, Workload: "DaemonSet", Name: "kube-proxy"},
+		{Feature: "calico", Kind: KindCNI, Workload: "DaemonSet", Name: "calico-node"},
+		{Feature: "canal", Kind: KindCNI, Workload: "DaemonSet", Name: "canal"},
+		{Feature: "cilium", Kind: KindCNI, Workload: "DaemonSet", Name: "cilium"},
+		{Feature: "flannel", Kind: KindCNI, Workload: "DaemonSet", Name: "kube-flannel-ds"},
+		{Feature: "weave-net", Kind: KindCNI, Workload: "DaemonSet", Name: "weave-net"},
+		{Feature: "antrea", Kind: KindCNI, Workload: "DaemonSet", Name: "antrea-agent"},
+		{Feature: "kube-router", Kind: KindCNI, Workload: "DaemonSet", Name: "kube-router"},
+		{Feature: "aws-vpc-cni", Kind: KindCNI, Workload: "DaemonSet", Name: "aws-node"},
+		{Feature: "ovn-kubernetes", Kind: KindCNI, Workload: "DaemonSet", Name: "ovnkube-node"},
+	}
+)
+
+// Discoverer emits the features of the Kubernetes version and the core components.
+type Discoverer struct {
+	Reader    client.Reader
+	Discovery k8sdiscovery.ServerVersionInterface
+}
+
+// New is the factory of the source.
+func New(mgr ctrl.Manager) (discovery.Discoverer, error) {
+	discoveryClient, err := k8sdiscovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Discoverer{Reader: mgr.GetClient(), Discovery: discoveryClient}, nil
+}
+
+// +kubebuilder:rbac:groups=apps,resources=deployments;daemonsets,verbs=get;list;watch
+
+func (d *Discoverer) Name() string {
+	return Source
+}
+
+// Watch triggers a sync on every change of a Deployment or DaemonSet in kube-system. Upgrades of the Kubernetes
+// version are found by the periodic sync.
+func (d *Discoverer) Watch(mgr ctrl.Manager, trigger func()) error {
+	system := predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return e.Meta.GetNamespace() == SystemNamespace
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.MetaNew.GetNamespace() == SystemNamespace
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return e.Meta.GetNamespace() == SystemNamespace
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return e.Meta.GetNamespace() == SystemNamespace
+		},
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("controlplane-discovery").
+		For(&appsv1.DaemonSet{}).
+		Watches(&source.Kind{Type: &appsv1.Deployment{}}, &handler.EnqueueRequestForObject{}).
+		WithEventFilter(system).
+		Complete(reconcile.Func(func(ctrl.Request) (ctrl.Result, error) {
+			trigger()
+
+			return ctrl.Result{}, nil
+		}))
+}
+
+// Discover returns the feature of the Kubernetes version and a feature for every known core component.
+func (d *Discoverer) Discover(ctx context.Context) ([]featuresv1alpha1.InstalledFeature, error) {
+	info, err := d.Discovery.ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	deployments := &appsv1.DeploymentList{}
+	if err := d.Reader.List(ctx, deployments, client.InNamespace(SystemNamespace)); err != nil {
+		return nil, err
+	}
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := d.Reader.List(ctx, daemonSets, client.InNamespace(SystemNamespace)); err != nil {
+		return nil, err
+	}
+
+	// components running as several workloads (e.g. one flannel DaemonSet per architecture) are reported once
+	result := []featuresv1alpha1.InstalledFeature{*KubernetesFeature(info)}
+	found := make(map[string]bool)
+	add := func(workload metav1.Object, kind string, template *corev1.PodTemplateSpec, ready int32, desired int32) {
+		if workload.GetDeletionTimestamp() != nil {
+			return
+		}
+
+		for _, component := range Components {
+			if component.Workload == kind && component.matches(workload.GetName()) && !found[component.Feature] {
+				found[component.Feature] = true
+				result = append(result, *Feature(component, workload, template, ready, desired))
+				return
+			}
+		}
+	}
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		desired := int32(1)
+		if deployment.Spec.Replicas != nil {
+			desired = *deployment.Spec.Replicas
+		}
+		add(deployment, "Deployment", &deployment.Spec.Template, deployment.Status.ReadyReplicas, desired)
+	}
+	for i := range daemonSets.Items {
+		daemonSet := &daemonSets.Items[i]
+		add(daemonSet, "DaemonSet", &daemonSet.Spec.Template, daemonSet.Status.NumberReady, daemonSet.Status.DesiredNumberScheduled)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// KubernetesFeature returns the feature of the Kubernetes server version. Its version is major, minor and patch of
+// the server version without the vendor suffix (e.g. "1.20.4" for "v1.20.4-gke.1"), so version ranges like ">=1.20"
+// match vendor builds, too.
+func KubernetesFeature(info *k8sversion.Info) *featuresv1alpha1.InstalledFeature {
+	serverVersion := strings.TrimPrefix(info.GitVersion, "v")
+	if parsed, err := version.ParseGeneric(info.GitVersion); err == nil {
+		serverVersion = fmt.Sprintf("%d.%d.%d", parsed.Major(), parsed.Minor(), parsed.Patch())
+	}
+
+	return &featuresv1alpha1.InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: FeatureKubernetes,
+			Annotations: map[string]string{
+				featuresv1alpha1.AnnotationDiscoveredFrom: "ServerVersion/" + info.GitVersion,
+			},
+		},
+		Spec: featuresv1alpha1.InstalledFeatureSpec{
+			Kind:        KindKubernetes,
+			Version:     serverVersion,
+			Description: "Kubernetes " + info.GitVersion + " (" + info.Platform + ")",
+		},
+	}
+}
+
+// Feature returns the feature of a core component. The version is the image tag of the first container. A component
+// with less ready pods than desired is reported as initializing.
+func Feature(component Component, workload metav1.Object, template *corev1.PodTemplateSpec, ready int32, desired int32) *featuresv1alpha1.InstalledFeature {
+	componentVersion := ""
+	if len(template.Spec.Containers) > 0 {
+		componentVersion = images.Parse(template.Spec.Containers[0].Image).Tag
+	}
+
+	phase := featuresv1alpha1.PhaseProvisioned
+	if ready < desired {
+		phase = featuresv1alpha1.PhaseInitializing
+	}
+
+	return &featuresv1alpha1.InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: component.Feature,
+			Annotations: map[string]string{
+				featuresv1alpha1.AnnotationDiscoveredFrom: component.Workload + "/" + workload.GetName(),
+				featuresv1alpha1.AnnotationSourcePhase:    phase,
+				featuresv1alpha1.AnnotationSourceMessage:  fmt.Sprintf("%d of %d pods ready", ready, desired),
+			},
+		},
+		Spec: featuresv1alpha1.InstalledFeatureSpec{
+			Kind:    component.Kind,
+			Version: componentVersion,
+		},
+	}
+}
+
+func (c Component) matches(name string) bool {
+	return name == c.Name || strings.HasPrefix(name, c.Name+"-")
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controlplanediscovery_test
+
+import (
+	"context"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/controlplanediscovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/resolver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("Control plane discovery source", func() {
+	var (
+		ctx        context.Context
+		workloads  client.Client
+		fakeServer *fakediscovery.FakeDiscovery
+		sut        *controlplanediscovery.Discoverer
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).Should(Succeed())
+		workloads = fake.NewFakeClientWithScheme(scheme)
+
+		fakeServer = &fakediscovery.FakeDiscovery{
+			Fake:               &clienttesting.Fake{},
+			FakedServerVersion: &version.Info{GitVersion: "v1.20.4-gke.1", Platform: "linux/amd64"},
+		}
+
+		sut = &controlplanediscovery.Discoverer{Reader: workloads, Discovery: fakeServer}
+	})
+
+	discover := func() map[string]InstalledFeature {
+		features, err := sut.Discover(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		result := make(map[string]InstalledFeature, len(features))
+		for _, feature := range features {
+			result[feature.Name] = feature
+		}
+
+		return result
+	}
+
+	It("should publish the Kubernetes version without vendor suffix", func() {
+		features := discover()
+
+		Expect(features).Should(HaveLen(1))
+		Expect(features[controlplanediscovery.FeatureKubernetes].Spec).Should(Equal(InstalledFeatureSpec{
+			Kind:        controlplanediscovery.KindKubernetes,
+			Version:     "1.20.4",
+			Description: "Kubernetes v1.20.4-gke.1 (linux/amd64)",
+		}))
+	})
+
+	It("should publish DNS, kube-proxy and CNI with the versions of their images", func() {
+		Expect(workloads.Create(ctx, deployment("kube-system", "coredns", "k8s.gcr.io/coredns:1.7.0", 2, 2))).Should(Succeed())
+		Expect(workloads.Create(ctx, daemonSet("kube-system", "kube-proxy", "k8s.gcr.io/kube-proxy:v1.20.4", 3, 3))).Should(Succeed())
+		Expect(workloads.Create(ctx, daemonSet("kube-system", "calico-node", "docker.io/calico/node:v3.17.1", 3, 2))).Should(Succeed())
+
+		features := discover()
+
+		Expect(features).Should(HaveLen(4))
+		Expect(features["coredns"].Spec).Should(Equal(InstalledFeatureSpec{Kind: controlplanediscovery.KindDNS, Version: "1.7.0"}))
+		Expect(features["coredns"].Annotations).Should(HaveKeyWithValue(AnnotationDiscoveredFrom, "Deployment/coredns"))
+		Expect(features["coredns"].Annotations).Should(HaveKeyWithValue(AnnotationSourcePhase, PhaseProvisioned))
+		Expect(features["kube-proxy"].Spec).Should(Equal(InstalledFeatureSpec{Kind: controlplanediscovery.KindProxy, Version: "v1.20.4"}))
+		Expect(features["calico"].Spec).Should(Equal(InstalledFeatureSpec{Kind: controlplanediscovery.KindCNI, Version: "v3.17.1"}))
+		Expect(features["calico"].Annotations).Should(HaveKeyWithValue(AnnotationSourcePhase, PhaseInitializing))
+		Expect(features["calico"].Annotations).Should(HaveKeyWithValue(AnnotationSourceMessage, "2 of 3 pods ready"))
+	})
+
+	It("should report components with several workloads once and ignore other namespaces", func() {
+		Expect(workloads.Create(ctx, daemonSet("kube-system", "kube-flannel-ds-amd64", "quay.io/coreos/flannel:v0.13.0", 2, 2))).Should(Succeed())
+		Expect(workloads.Create(ctx, daemonSet("kube-system", "kube-flannel-ds-arm64", "quay.io/coreos/flannel:v0.13.0", 1, 1))).Should(Succeed())
+		Expect(workloads.Create(ctx, daemonSet("default", "kube-proxy", "k8s.gcr.io/kube-proxy:v1.20.4", 1, 1))).Should(Succeed())
+
+		features := discover()
+
+		Expect(features).Should(HaveLen(2))
+		Expect(features["flannel"].Annotations).Should(HaveKeyWithValue(AnnotationDiscoveredFrom, "DaemonSet/kube-flannel-ds-amd64"))
+	})
+
+	It("should make Kubernetes version and kube-proxy ordinary dependencies and conflicts", func() {
+		store := controllers.NewOcpClientMemory()
+		engine := discovery.NewEngine(store, logf.NullLogger{}, "cluster", 0)
+		engine.Add(sut)
+		Expect(workloads.Create(ctx, daemonSet("kube-system", "kube-proxy", "k8s.gcr.io/kube-proxy:v1.20.4", 3, 3))).Should(Succeed())
+		Expect(engine.Sync(ctx, sut).Created).Should(Equal(2))
+
+		features, err := store.ListInstalledFeatures(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		modern := InstalledFeature{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "modern"},
+			Spec: InstalledFeatureSpec{
+				Kind:      "app",
+				Version:   "1.0.0",
+				DependsOn: []InstalledFeatureRef{{Namespace: "cluster", Name: "kubernetes", Version: ">=1.20"}},
+			},
+		}
+		replacement := InstalledFeature{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "proxy-replacement"},
+			Spec: InstalledFeatureSpec{
+				Kind:      "app",
+				Version:   "1.0.0",
+				DependsOn: []InstalledFeatureRef{{Namespace: "cluster", Name: "kubernetes", Version: ">=1.20"}},
+				Conflicts: []InstalledFeatureRef{{Namespace: "cluster", Name: "kube-proxy"}},
+			},
+		}
+		future := InstalledFeature{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "future"},
+			Spec: InstalledFeatureSpec{
+				Kind:      "app",
+				Version:   "1.0.0",
+				DependsOn: []InstalledFeatureRef{{Namespace: "cluster", Name: "kubernetes", Version: ">=1.21"}},
+			},
+		}
+		features = append(features, modern, replacement, future)
+
+		result := resolver.Resolve(features, nil)
+
+		Expect(result.FeatureStatus(&modern).Phase).Should(Equal(PhaseProvisioned))
+		Expect(result.FeatureStatus(&replacement).Phase).Should(Equal(PhaseFailed))
+		Expect(result.FeatureStatus(&future).Phase).Should(Equal(PhasePending))
+		Expect(result.FeatureStatus(&replacement).ConflictingFeatures).Should(Equal([]InstalledFeatureRef{{Namespace: "cluster", Name: "kube-proxy"}}))
+	})
+})
+
+func template(image string) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: image}}},
+	}
+}
+
+func deployment(namespace string, name string, image string, desired int32, ready int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       appsv1.DeploymentSpec{Replicas: &desired, Template: template(image)},
+		Status:     appsv1.DeploymentStatus{ReadyReplicas: ready},
+	}
+}
+
+func daemonSet(namespace string, name string, image string, desired int32, ready int32) *appsv1.DaemonSet {
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       appsv1.DaemonSetSpec{Template: template(image)},
+		Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: desired, NumberReady: ready},
+	}
+}
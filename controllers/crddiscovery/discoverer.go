@@ -0,0 +1,175 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package crddiscovery is the discovery source of the features provided by CustomResourceDefinitions. All CRDs of an
+// API group form a single feature named after the group.
+package crddiscovery
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/versions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// Source is the name of the discovery source and the value of the discovered-by label of its features.
+	Source = "crd"
+
+	// LabelVersion is the label (or annotation) of the CRDs containing the version of the feature.
+	LabelVersion = "app.kubernetes.io/version"
+	// LabelPartOf is the label of the CRDs containing the provider of the feature.
+	LabelPartOf = "app.kubernetes.io/part-of"
+)
+
+var (
+	_ discovery.Discoverer = &Discoverer{}
+	_ discovery.Watcher    = &Discoverer{}
+)
+
+// Discoverer emits a feature for every API group provided by CRDs.
+type Discoverer struct {
+	Reader client.Reader
+}
+
+// New is the factory of the source.
+func New(mgr ctrl.Manager) (discovery.Discoverer, error) {
+	return &Discoverer{Reader: mgr.GetClient()}, nil
+}
+
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
+
+func (d *Discoverer) Name() string {
+	return Source
+}
+
+// Watch triggers a sync on every change of a CRD.
+func (d *Discoverer) Watch(mgr ctrl.Manager, trigger func()) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("crd-discovery").
+		For(&apiextensionsv1.CustomResourceDefinition{}).
+		Complete(reconcile.Func(func(ctrl.Request) (ctrl.Result, error) {
+			trigger()
+
+			return ctrl.Result{}, nil
+		}))
+}
+
+// Discover groups the CRDs by API group and returns a feature for every group. CRDs being deleted are ignored.
+func (d *Discoverer) Discover(ctx context.Context) ([]featuresv1alpha1.InstalledFeature, error) {
+	list := &apiextensionsv1.CustomResourceDefinitionList{}
+	if err := d.Reader.List(ctx, list); err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]apiextensionsv1.CustomResourceDefinition)
+	for _, crd := range list.Items {
+		if crd.DeletionTimestamp == nil {
+			groups[crd.Spec.Group] = append(groups[crd.Spec.Group], crd)
+		}
+	}
+
+	result := make([]featuresv1alpha1.InstalledFeature, 0, len(groups))
+	for group, crds := range groups {
+		result = append(result, *Feature(group, crds))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// Feature returns the feature of an API group provided by the given CRDs. The kind is the API group, the version is
+// the highest app.kubernetes.io/version found in the labels or annotations of the CRDs. Without such a version the
+// storage version of the API is used, e.g. "v1beta1".
+func Feature(group string, crds []apiextensionsv1.CustomResourceDefinition) *featuresv1alpha1.InstalledFeature {
+	sort.Slice(crds, func(i, j int) bool {
+		return crds[i].Name < crds[j].Name
+	})
+
+	names := make([]string, len(crds))
+	kinds := make([]string, len(crds))
+	provider := ""
+	for i, crd := range crds {
+		names[i] = crd.Name
+		kinds[i] = crd.Spec.Names.Kind
+
+		if provider == "" {
+			provider = crd.Labels[LabelPartOf]
+		}
+	}
+
+	return &featuresv1alpha1.InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        group,
+			Annotations: map[string]string{featuresv1alpha1.AnnotationDiscoveredFrom: strings.Join(names, ",")},
+		},
+		Spec: featuresv1alpha1.InstalledFeatureSpec{
+			Kind:        group,
+			Version:     version(crds),
+			Provider:    provider,
+			Description: "Custom resources " + strings.Join(kinds, ", "),
+		},
+	}
+}
+
+// version returns the highest version given in the labels or annotations of the CRDs.
+func version(crds []apiextensionsv1.CustomResourceDefinition) string {
+	result := ""
+	for _, crd := range crds {
+		for _, candidate := range []string{crd.Labels[LabelVersion], crd.Annotations[LabelVersion]} {
+			if candidate == "" {
+				continue
+			}
+
+			if result == "" {
+				result = candidate
+				continue
+			}
+
+			parsed, err := versions.Parse(candidate)
+			if err != nil {
+				continue
+			}
+			current, err := versions.Parse(result)
+			if err != nil || current.LessThan(parsed) {
+				result = candidate
+			}
+		}
+	}
+	if result != "" {
+		return result
+	}
+
+	for _, crd := range crds {
+		for _, v := range crd.Spec.Versions {
+			if v.Storage {
+				return v.Name
+			}
+		}
+	}
+
+	return ""
+}
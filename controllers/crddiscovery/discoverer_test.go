@@ -0,0 +1,193 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crddiscovery_test
+
+import (
+	"context"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/crddiscovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("CRD discovery source", func() {
+	const (
+		namespace = "features"
+		group     = "cert-manager.io"
+	)
+
+	var (
+		ctx    context.Context
+		store  *controllers.OcpClientMemory
+		crds   client.Client
+		sut    *discovery.Engine
+		lookup = types.NamespacedName{Namespace: namespace, Name: group}
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		store = controllers.NewOcpClientMemory()
+
+		crds = fake.NewFakeClientWithScheme(crdScheme())
+
+		sut = discovery.NewEngine(store, logf.NullLogger{}, namespace, 0)
+		sut.Add(&crddiscovery.Discoverer{Reader: crds})
+	})
+
+	sync := func() {
+		for _, stats := range sut.SyncAll(ctx) {
+			Expect(stats.Error).Should(BeEmpty())
+		}
+	}
+
+	It("should create a feature for all CRDs of the API group", func() {
+		Expect(crds.Create(ctx, crd("certificates", group, "Certificate", "1.0.3"))).Should(Succeed())
+		Expect(crds.Create(ctx, crd("issuers", group, "Issuer", "1.0.4"))).Should(Succeed())
+		Expect(crds.Create(ctx, crd("widgets", "example.com", "Widget", "2.0.0"))).Should(Succeed())
+
+		sync()
+
+		feature, err := store.LoadInstalledFeature(ctx, lookup)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(feature.Labels).Should(HaveKeyWithValue(LabelDiscoveredBy, crddiscovery.Source))
+		Expect(feature.Annotations).Should(HaveKeyWithValue(AnnotationDiscoveredFrom, "certificates.cert-manager.io,issuers.cert-manager.io"))
+		Expect(feature.Spec).Should(Equal(InstalledFeatureSpec{
+			Kind:        group,
+			Version:     "1.0.4",
+			Provider:    "cert-manager",
+			Description: "Custom resources Certificate, Issuer",
+		}))
+		Expect(store.InstalledFeatures()).Should(HaveLen(2))
+	})
+
+	It("should use the storage version of the API without version label", func() {
+		unlabeled := crd("certificates", group, "Certificate", "")
+		Expect(crds.Create(ctx, unlabeled)).Should(Succeed())
+
+		sync()
+
+		feature, err := store.LoadInstalledFeature(ctx, lookup)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(feature.Spec.Version).Should(Equal("v1"))
+	})
+
+	It("should update the version of a discovered feature", func() {
+		certificates := crd("certificates", group, "Certificate", "1.0.3")
+		Expect(crds.Create(ctx, certificates)).Should(Succeed())
+		sync()
+
+		feature, err := store.LoadInstalledFeature(ctx, lookup)
+		Expect(err).ShouldNot(HaveOccurred())
+		feature.Spec.DependsOn = []InstalledFeatureRef{{Namespace: namespace, Name: "base"}}
+		Expect(store.SaveInstalledFeature(ctx, feature)).Should(Succeed())
+
+		certificates.Labels[crddiscovery.LabelVersion] = "1.1.0"
+		Expect(crds.Update(ctx, certificates)).Should(Succeed())
+		sync()
+
+		feature, err = store.LoadInstalledFeature(ctx, lookup)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(feature.Spec.Version).Should(Equal("1.1.0"))
+		Expect(feature.Spec.DependsOn).Should(HaveLen(1))
+	})
+
+	It("should delete the discovered feature when all CRDs of the group are removed", func() {
+		certificates := crd("certificates", group, "Certificate", "1.0.3")
+		Expect(crds.Create(ctx, certificates)).Should(Succeed())
+		sync()
+
+		Expect(crds.Delete(ctx, certificates)).Should(Succeed())
+		sync()
+
+		_, err := store.LoadInstalledFeature(ctx, lookup)
+		Expect(errors.IsNotFound(err)).Should(BeTrue())
+	})
+
+	It("should never change manually authored features", func() {
+		manual := &InstalledFeature{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: group},
+			Spec:       InstalledFeatureSpec{Kind: "cert-manager", Version: "0.16.0"},
+		}
+		Expect(store.CreateInstalledFeature(ctx, manual)).Should(Succeed())
+
+		certificates := crd("certificates", group, "Certificate", "1.0.3")
+		Expect(crds.Create(ctx, certificates)).Should(Succeed())
+		sync()
+
+		feature, err := store.LoadInstalledFeature(ctx, lookup)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(feature.Spec).Should(Equal(manual.Spec))
+		Expect(feature.Labels).ShouldNot(HaveKey(LabelDiscoveredBy))
+
+		Expect(crds.Delete(ctx, certificates)).Should(Succeed())
+		sync()
+
+		_, err = store.LoadInstalledFeature(ctx, lookup)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should ignore CRDs being deleted", func() {
+		certificates := crd("certificates", group, "Certificate", "1.0.3")
+		now := metav1.Now()
+		certificates.DeletionTimestamp = &now
+
+		features, err := (&crddiscovery.Discoverer{Reader: fake.NewFakeClientWithScheme(crdScheme(), certificates)}).Discover(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(features).Should(BeEmpty())
+	})
+})
+
+func crdScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	Expect(apiextensionsv1.AddToScheme(scheme)).Should(Succeed())
+
+	return scheme
+}
+
+func crd(plural string, group string, kind string, version string) *apiextensionsv1.CustomResourceDefinition {
+	result := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   plural + "." + group,
+			Labels: map[string]string{crddiscovery.LabelPartOf: "cert-manager"},
+		},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: group,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{Plural: plural, Kind: kind},
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{Name: "v1alpha1", Served: true},
+				{Name: "v1", Served: true, Storage: true},
+			},
+		},
+	}
+
+	if version != "" {
+		result.Labels[crddiscovery.LabelVersion] = version
+	}
+
+	return result
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package discovery generates InstalledFeatures from the resources found in the cluster. Every source of features
+// implements the Discoverer interface and emits the features it wants to exist. The sync engine creates, updates and
+// deletes the features of a source, so the sources don't write to the cluster themselves. The features of a source are
+// tracked by the discovered-by label; features without this label are authored manually and never touched.
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Discoverer is a source of features.
+type Discoverer interface {
+	// Name is the unique name of the source. It is the value of the discovered-by label of the generated features.
+	Name() string
+	// Discover returns the features that should exist. Features without namespace are created in the namespace of the
+	// engine. The spec is owned by the source, only the group, dependencies and conflicts may be added by users when the
+	// source leaves them empty.
+	Discover(ctx context.Context) ([]featuresv1alpha1.InstalledFeature, error)
+}
+
+// Watcher is implemented by discoverers reacting on changes in the cluster. Watch registers the watches with the
+// manager and calls trigger whenever the source should be synced again.
+type Watcher interface {
+	Watch(mgr ctrl.Manager, trigger func()) error
+}
+
+// Factory creates a discoverer using the clients of the manager.
+type Factory func(mgr ctrl.Manager) (Discoverer, error)
+
+// Registry contains the factories of all known sources by name.
+type Registry struct {
+	factories map[string]Factory
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]Factory)}
+}
+
+// Register adds the factory of a source. Every name may only be registered once.
+func (r *Registry) Register(name string, factory Factory) error {
+	if _, ok := r.factories[name]; ok {
+		return fmt.Errorf("discovery source '%s' is already registered", name)
+	}
+
+	r.factories[name] = factory
+	return nil
+}
+
+// Names returns the sorted names of all registered sources.
+func (r *Registry) Names() []string {
+	result := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// New creates the discoverer of the named source.
+func (r *Registry) New(name string, mgr ctrl.Manager) (Discoverer, error) {
+	factory, ok := r.factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown discovery source '%s', known sources are %v", name, r.Names())
+	}
+
+	return factory(mgr)
+}
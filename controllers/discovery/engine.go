@@ -0,0 +1,436 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	_ manager.Runnable               = &Engine{}
+	_ manager.LeaderElectionRunnable = &Engine{}
+)
+
+var (
+	syncedFeatures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "installedfeatures_discovery_synced_total",
+		Help: "Number of discovered features created, updated, deleted or skipped by source.",
+	}, []string{"source", "action"})
+	syncErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "installedfeatures_discovery_errors_total",
+		Help: "Number of failed syncs by source.",
+	}, []string{"source"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(syncedFeatures, syncErrors)
+}
+
+// Stats are the results of the last sync of a source.
+type Stats struct {
+	Source     string    `json:"source"`
+	LastSync   time.Time `json:"lastSync"`
+	Discovered int       `json:"discovered"`
+	Created    int       `json:"created"`
+	Updated    int       `json:"updated"`
+	Deleted    int       `json:"deleted"`
+	// Skipped counts the discovered features clashing with manually authored features or features of other sources.
+	Skipped int    `json:"skipped"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Engine syncs the features of the added sources. All sources are synced on start and every interval, sources
+// implementing Watcher are synced on every trigger, too.
+type Engine struct {
+	Client controllers.OcpClient
+	Log    logr.Logger
+
+	// Namespace is the namespace of the discovered features without namespace.
+	Namespace string
+	// Interval is the time between two syncs of all sources. 0 disables the periodic sync.
+	Interval time.Duration
+
+	discoverers []Discoverer
+
+	mutex   sync.Mutex
+	running bool
+	pending map[string]bool
+	wake    chan struct{}
+	stats   map[string]Stats
+
+	// requests are the syncs of all sources requested via RequestSync. They are answered with the stats by the loop.
+	requests chan chan []Stats
+}
+
+// ErrNotRunning is returned by RequestSync when the engine is not started, e.g. on managers not being the leader.
+var ErrNotRunning = errors.New("the discovery is not running on this manager")
+
+// NewEngine creates an engine without sources.
+func NewEngine(client controllers.OcpClient, log logr.Logger, namespace string, interval time.Duration) *Engine {
+	return &Engine{
+		Client:    client,
+		Log:       log,
+		Namespace: namespace,
+		Interval:  interval,
+		pending:   make(map[string]bool),
+		wake:      make(chan struct{}, 1),
+		stats:     make(map[string]Stats),
+		requests:  make(chan chan []Stats),
+	}
+}
+
+// Add adds a source to the engine. Sources have to be added before the engine is started.
+func (e *Engine) Add(discoverer Discoverer) {
+	e.discoverers = append(e.discoverers, discoverer)
+}
+
+// Trigger requests a sync of the named source. It never blocks, multiple triggers before the sync are merged.
+func (e *Engine) Trigger(source string) {
+	e.mutex.Lock()
+	e.pending[source] = true
+	e.mutex.Unlock()
+
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// RequestSync syncs all sources within the loop of the started engine and returns their stats. It fails with
+// ErrNotRunning when the engine is not started.
+func (e *Engine) RequestSync(ctx context.Context) ([]Stats, error) {
+	e.mutex.Lock()
+	running := e.running
+	e.mutex.Unlock()
+	if !running {
+		return nil, ErrNotRunning
+	}
+
+	result := make(chan []Stats, 1)
+	select {
+	case e.requests <- result:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case stats := <-result:
+		return stats, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Start syncs the sources until the stop channel is closed. All syncs are done by this loop, so they never overlap.
+func (e *Engine) Start(stop <-chan struct{}) error {
+	e.mutex.Lock()
+	e.running = true
+	e.mutex.Unlock()
+	defer func() {
+		e.mutex.Lock()
+		e.running = false
+		e.mutex.Unlock()
+	}()
+
+	var tick <-chan time.Time
+	if e.Interval > 0 {
+		ticker := time.NewTicker(e.Interval)
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	e.SyncAll(context.Background())
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-tick:
+			e.SyncAll(context.Background())
+		case result := <-e.requests:
+			result <- e.SyncAll(context.Background())
+		case <-e.wake:
+			e.mutex.Lock()
+			pending := e.pending
+			e.pending = make(map[string]bool)
+			e.mutex.Unlock()
+
+			for _, discoverer := range e.discoverers {
+				if pending[discoverer.Name()] {
+					e.Sync(context.Background(), discoverer)
+				}
+			}
+		}
+	}
+}
+
+// NeedLeaderElection makes sure only the leading manager writes the discovered features.
+func (e *Engine) NeedLeaderElection() bool {
+	return true
+}
+
+// SyncAll syncs all sources and returns their stats.
+func (e *Engine) SyncAll(ctx context.Context) []Stats {
+	result := make([]Stats, len(e.discoverers))
+	for i, discoverer := range e.discoverers {
+		result[i] = e.Sync(ctx, discoverer)
+	}
+
+	return result
+}
+
+// Stats returns the stats of the last sync of every source sorted by source.
+func (e *Engine) Stats() []Stats {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	result := make([]Stats, 0, len(e.stats))
+	for _, stats := range e.stats {
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Source < result[j].Source
+	})
+
+	return result
+}
+
+// Sync creates the missing features of the source, updates the changed ones and deletes the features no longer
+// discovered. When the source fails, nothing is deleted.
+func (e *Engine) Sync(ctx context.Context, discoverer Discoverer) Stats {
+	source := discoverer.Name()
+	log := e.Log.WithValues("source", source)
+	stats := Stats{Source: source, LastSync: time.Now()}
+
+	err := e.sync(ctx, discoverer, &stats, log)
+	if err != nil {
+		log.Error(err, "discovery failed")
+		stats.Error = err.Error()
+		syncErrors.WithLabelValues(source).Inc()
+	} else {
+		log.Info("discovery synced", "discovered", stats.Discovered, "created", stats.Created,
+			"updated", stats.Updated, "deleted", stats.Deleted, "skipped", stats.Skipped)
+	}
+
+	syncedFeatures.WithLabelValues(source, "created").Add(float64(stats.Created))
+	syncedFeatures.WithLabelValues(source, "updated").Add(float64(stats.Updated))
+	syncedFeatures.WithLabelValues(source, "deleted").Add(float64(stats.Deleted))
+	syncedFeatures.WithLabelValues(source, "skipped").Add(float64(stats.Skipped))
+
+	e.mutex.Lock()
+	e.stats[source] = stats
+	e.mutex.Unlock()
+
+	return stats
+}
+
+func (e *Engine) sync(ctx context.Context, discoverer Discoverer, stats *Stats, log logr.Logger) error {
+	source := discoverer.Name()
+
+	desired, err := discoverer.Discover(ctx)
+	if err != nil {
+		return err
+	}
+
+	features, err := e.Client.ListInstalledFeatures(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[types.NamespacedName]*featuresv1alpha1.InstalledFeature, len(features))
+	for i := range features {
+		existing[types.NamespacedName{Namespace: features[i].Namespace, Name: features[i].Name}] = &features[i]
+	}
+
+	var errs []error
+	discovered := make(map[types.NamespacedName]bool, len(desired))
+	for i := range desired {
+		feature := e.prepare(&desired[i], source)
+		lookup := types.NamespacedName{Namespace: feature.Namespace, Name: feature.Name}
+		if discovered[lookup] {
+			log.Info("ignoring feature discovered twice", "feature", lookup)
+			continue
+		}
+		discovered[lookup] = true
+		stats.Discovered++
+
+		current, ok := existing[lookup]
+		switch {
+		case !ok:
+			log.Info("creating feature", "feature", lookup, "version", feature.Spec.Version)
+			if err := e.Client.CreateInstalledFeature(ctx, feature); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			stats.Created++
+
+		case current.Labels[featuresv1alpha1.LabelDiscoveredBy] != source:
+			log.Info("not touching feature not managed by this source", "feature", lookup,
+				"managed-by", current.Labels[featuresv1alpha1.LabelDiscoveredBy])
+			stats.Skipped++
+
+		case current.DeletionTimestamp != nil:
+			log.Info("feature is being deleted, it is created again by the next sync", "feature", lookup)
+
+		case Merge(current, feature):
+			log.Info("updating feature", "feature", lookup, "version", current.Spec.Version)
+			if err := e.Client.SaveInstalledFeature(ctx, current); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			stats.Updated++
+		}
+	}
+
+	for lookup, current := range existing {
+		if discovered[lookup] || current.DeletionTimestamp != nil || current.Labels[featuresv1alpha1.LabelDiscoveredBy] != source {
+			continue
+		}
+
+		log.Info("deleting feature no longer discovered", "feature", lookup)
+		if err := e.Client.DeleteInstalledFeature(ctx, lookup); err != nil && !k8serrors.IsNotFound(err) {
+			errs = append(errs, err)
+			continue
+		}
+		stats.Deleted++
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// prepare sets the namespace and the label of the managed features. References without namespace point to features
+// in the namespace of the engine, e.g. the features of sources not knowing a better namespace.
+func (e *Engine) prepare(desired *featuresv1alpha1.InstalledFeature, source string) *featuresv1alpha1.InstalledFeature {
+	result := desired.DeepCopy()
+	if result.Namespace == "" {
+		result.Namespace = e.Namespace
+	}
+
+	if result.Spec.Group != nil && result.Spec.Group.Namespace == "" {
+		result.Spec.Group.Namespace = e.Namespace
+	}
+	for _, refs := range [][]featuresv1alpha1.InstalledFeatureRef{result.Spec.DependsOn, result.Spec.Conflicts} {
+		for i := range refs {
+			if refs[i].Namespace == "" {
+				refs[i].Namespace = e.Namespace
+			}
+		}
+	}
+
+	if result.Labels == nil {
+		result.Labels = make(map[string]string, 1)
+	}
+	result.Labels[featuresv1alpha1.LabelDiscoveredBy] = source
+
+	return result
+}
+
+// Merge copies the discovered feature into the existing one and returns true if the existing feature changed. The
+// labels, annotations and owner references of the discovered feature are added. Only the fields owned by the sources
+// are copied: kind, version, provider, description and URI. The group, dependencies and conflicts are only copied when
+// discovered, so users may add them to features of sources not knowing them. All other fields, e.g. the provided APIs
+// or the workloads, are left to the users.
+func Merge(existing *featuresv1alpha1.InstalledFeature, desired *featuresv1alpha1.InstalledFeature) bool {
+	original := existing.DeepCopy()
+	spec := desired.Spec.DeepCopy()
+
+	existing.Spec.Kind = spec.Kind
+	existing.Spec.Version = spec.Version
+	existing.Spec.Provider = spec.Provider
+	existing.Spec.Description = spec.Description
+	existing.Spec.Uri = spec.Uri
+	if spec.Group != nil {
+		existing.Spec.Group = spec.Group
+	}
+	if spec.DependsOn != nil {
+		existing.Spec.DependsOn = spec.DependsOn
+	}
+	if spec.Conflicts != nil {
+		existing.Spec.Conflicts = spec.Conflicts
+	}
+
+	existing.Labels = mergeMap(existing.Labels, desired.Labels)
+	existing.Annotations = mergeMap(existing.Annotations, desired.Annotations)
+	if desired.OwnerReferences != nil {
+		existing.OwnerReferences = desired.OwnerReferences
+	}
+
+	return !equality.Semantic.DeepEqual(original.Spec, existing.Spec) ||
+		!equality.Semantic.DeepEqual(original.ObjectMeta, existing.ObjectMeta)
+}
+
+func mergeMap(existing map[string]string, desired map[string]string) map[string]string {
+	if len(desired) == 0 {
+		return existing
+	}
+
+	if existing == nil {
+		existing = make(map[string]string, len(desired))
+	}
+	for k, v := range desired {
+		existing[k] = v
+	}
+
+	return existing
+}
+
+// Handler serves the stats of the last sync of every source as JSON. A POST syncs all sources first. The sync is
+// done by the loop of the engine, so it is only available on the leading manager.
+func Handler(engine *Engine) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var stats []Stats
+		switch req.Method {
+		case http.MethodGet:
+			stats = engine.Stats()
+		case http.MethodPost:
+			var err error
+			stats, err = engine.RequestSync(req.Context())
+			if err == ErrNotRunning {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "only GET and POST are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(stats)
+	})
+}
@@ -0,0 +1,348 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package discovery_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("Discovery sync engine", func() {
+	const namespace = "features"
+
+	var (
+		ctx    context.Context
+		store  *controllers.OcpClientMemory
+		source *staticSource
+		sut    *discovery.Engine
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		store = controllers.NewOcpClientMemory()
+		source = &staticSource{name: "static"}
+
+		sut = discovery.NewEngine(store, logf.NullLogger{}, namespace, 0)
+		sut.Add(source)
+	})
+
+	load := func(name string) (*InstalledFeature, error) {
+		return store.LoadInstalledFeature(ctx, types.NamespacedName{Namespace: namespace, Name: name})
+	}
+
+	It("should create the discovered features in the namespace of the engine", func() {
+		source.set(discovered("basic", "1.0.0"), discovered("other", "2.0.0"))
+
+		stats := sut.Sync(ctx, source)
+
+		Expect(stats).Should(matchStats(source.name, 2, 2, 0, 0, 0))
+		feature, err := load("basic")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(feature.Labels).Should(HaveKeyWithValue(LabelDiscoveredBy, "static"))
+		Expect(feature.Spec.Version).Should(Equal("1.0.0"))
+	})
+
+	It("should keep features in the namespace given by the source", func() {
+		feature := discovered("basic", "1.0.0")
+		feature.Namespace = "apps"
+		source.set(feature)
+
+		sut.Sync(ctx, source)
+
+		_, err := store.LoadInstalledFeature(ctx, types.NamespacedName{Namespace: "apps", Name: "basic"})
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should point references without namespace to the namespace of the engine", func() {
+		feature := discovered("basic", "1.0.0")
+		feature.Namespace = "apps"
+		feature.Spec.DependsOn = []InstalledFeatureRef{{Name: "cert-manager.io"}, {Namespace: "apps", Name: "other"}}
+		source.set(feature)
+
+		sut.Sync(ctx, source)
+
+		created, err := store.LoadInstalledFeature(ctx, types.NamespacedName{Namespace: "apps", Name: "basic"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(created.Spec.DependsOn).Should(Equal([]InstalledFeatureRef{
+			{Namespace: namespace, Name: "cert-manager.io"},
+			{Namespace: "apps", Name: "other"},
+		}))
+	})
+
+	It("should only update changed features", func() {
+		source.set(discovered("basic", "1.0.0"), discovered("other", "2.0.0"))
+		sut.Sync(ctx, source)
+
+		source.set(discovered("basic", "1.1.0"), discovered("other", "2.0.0"))
+		stats := sut.Sync(ctx, source)
+
+		Expect(stats).Should(matchStats(source.name, 2, 0, 1, 0, 0))
+		feature, err := load("basic")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(feature.Spec.Version).Should(Equal("1.1.0"))
+	})
+
+	It("should keep the dependencies added by users when the source does not discover any", func() {
+		source.set(discovered("basic", "1.0.0"))
+		sut.Sync(ctx, source)
+
+		feature, err := load("basic")
+		Expect(err).ShouldNot(HaveOccurred())
+		feature.Spec.DependsOn = []InstalledFeatureRef{{Namespace: namespace, Name: "other"}}
+		Expect(store.SaveInstalledFeature(ctx, feature)).Should(Succeed())
+
+		stats := sut.Sync(ctx, source)
+
+		Expect(stats.Updated).Should(BeZero())
+		feature, err = load("basic")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(feature.Spec.DependsOn).Should(HaveLen(1))
+	})
+
+	It("should keep the fields set by users when syncing", func() {
+		source.set(discovered("basic", "1.0.0"))
+		sut.Sync(ctx, source)
+
+		feature, err := load("basic")
+		Expect(err).ShouldNot(HaveOccurred())
+		feature.Spec.ProvidedAPIs = []ProvidedAPI{{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}}
+		feature.Spec.Workloads = &WorkloadSelector{Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "basic"}}}
+		feature.Spec.MinKubernetesVersion = "1.19"
+		Expect(store.SaveInstalledFeature(ctx, feature)).Should(Succeed())
+
+		source.set(discovered("basic", "1.1.0"))
+		stats := sut.Sync(ctx, source)
+
+		Expect(stats.Updated).Should(Equal(1))
+		feature, err = load("basic")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(feature.Spec.Version).Should(Equal("1.1.0"))
+		Expect(feature.Spec.ProvidedAPIs).Should(HaveLen(1))
+		Expect(feature.Spec.Workloads).ShouldNot(BeNil())
+		Expect(feature.Spec.MinKubernetesVersion).Should(Equal("1.19"))
+	})
+
+	It("should delete the features no longer discovered", func() {
+		source.set(discovered("basic", "1.0.0"), discovered("other", "2.0.0"))
+		sut.Sync(ctx, source)
+
+		source.set(discovered("basic", "1.0.0"))
+		stats := sut.Sync(ctx, source)
+
+		Expect(stats).Should(matchStats(source.name, 1, 0, 0, 1, 0))
+		_, err := load("other")
+		Expect(k8serrors.IsNotFound(err)).Should(BeTrue())
+	})
+
+	It("should neither change nor delete manually authored features or features of other sources", func() {
+		Expect(store.CreateInstalledFeature(ctx, &InstalledFeature{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "basic"},
+			Spec:       InstalledFeatureSpec{Kind: "basic", Version: "0.9.0"},
+		})).Should(Succeed())
+		Expect(store.CreateInstalledFeature(ctx, &InstalledFeature{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "foreign", Labels: map[string]string{LabelDiscoveredBy: "other"}},
+			Spec:       InstalledFeatureSpec{Kind: "foreign", Version: "1.0.0"},
+		})).Should(Succeed())
+		source.set(discovered("basic", "1.0.0"))
+
+		stats := sut.Sync(ctx, source)
+
+		Expect(stats).Should(matchStats(source.name, 1, 0, 0, 0, 1))
+		feature, err := load("basic")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(feature.Spec.Version).Should(Equal("0.9.0"))
+		_, err = load("foreign")
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should not delete anything when the source fails", func() {
+		source.set(discovered("basic", "1.0.0"))
+		sut.Sync(ctx, source)
+
+		source.fail(errors.New("source not available"))
+		stats := sut.Sync(ctx, source)
+
+		Expect(stats.Error).Should(Equal("source not available"))
+		_, err := load("basic")
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should report the stats of the last sync of every source", func() {
+		other := &staticSource{name: "another"}
+		sut.Add(other)
+		source.set(discovered("basic", "1.0.0"))
+
+		sut.SyncAll(ctx)
+
+		stats := sut.Stats()
+		Expect(stats).Should(HaveLen(2))
+		Expect(stats[0].Source).Should(Equal("another"))
+		Expect(stats[1]).Should(matchStats("static", 1, 1, 0, 0, 0))
+	})
+
+	It("should sync the triggered sources after starting", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+
+		go func() {
+			defer GinkgoRecover()
+			Expect(sut.Start(stop)).Should(Succeed())
+		}()
+		Eventually(sut.Stats).Should(HaveLen(1))
+
+		source.set(discovered("basic", "1.0.0"))
+		sut.Trigger(source.name)
+
+		Eventually(func() error {
+			_, err := load("basic")
+			return err
+		}).Should(Succeed())
+	})
+
+	It("should serve the stats and sync all sources on POST", func() {
+		handler := discovery.Handler(sut)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/discovery", nil))
+		Expect(recorder.Code).Should(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).Should(Equal("[]\n"))
+
+		stop := make(chan struct{})
+		defer close(stop)
+
+		go func() {
+			defer GinkgoRecover()
+			Expect(sut.Start(stop)).Should(Succeed())
+		}()
+		Eventually(sut.Stats).Should(HaveLen(1))
+
+		source.set(discovered("basic", "1.0.0"))
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/discovery", nil))
+		Expect(recorder.Code).Should(Equal(http.StatusOK))
+
+		var stats []discovery.Stats
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &stats)).Should(Succeed())
+		Expect(stats).Should(HaveLen(1))
+		Expect(stats[0].Created).Should(Equal(1))
+
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/discovery", nil))
+		Expect(recorder.Code).Should(Equal(http.StatusMethodNotAllowed))
+	})
+
+	It("should refuse to sync on POST when the engine is not running", func() {
+		source.set(discovered("basic", "1.0.0"))
+
+		recorder := httptest.NewRecorder()
+		discovery.Handler(sut).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/discovery", nil))
+		Expect(recorder.Code).Should(Equal(http.StatusServiceUnavailable))
+
+		_, err := load("basic")
+		Expect(k8serrors.IsNotFound(err)).Should(BeTrue())
+	})
+})
+
+var _ = Describe("Discovery registry", func() {
+	It("should create the registered sources by name", func() {
+		registry := discovery.NewRegistry()
+		Expect(registry.Register("static", func(ctrl.Manager) (discovery.Discoverer, error) {
+			return &staticSource{name: "static"}, nil
+		})).Should(Succeed())
+
+		source, err := registry.New("static", nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(source.Name()).Should(Equal("static"))
+		Expect(registry.Names()).Should(Equal([]string{"static"}))
+	})
+
+	It("should reject sources registered twice", func() {
+		registry := discovery.NewRegistry()
+		factory := func(ctrl.Manager) (discovery.Discoverer, error) { return nil, nil }
+
+		Expect(registry.Register("static", factory)).Should(Succeed())
+		Expect(registry.Register("static", factory)).ShouldNot(Succeed())
+	})
+
+	It("should fail on unknown sources", func() {
+		_, err := discovery.NewRegistry().New("unknown", nil)
+		Expect(err).Should(HaveOccurred())
+	})
+})
+
+// matchStats matches the counters of the stats.
+func matchStats(source string, discovered, created, updated, deleted, skipped int) OmegaMatcher {
+	return WithTransform(func(s discovery.Stats) []interface{} {
+		return []interface{}{s.Source, s.Discovered, s.Created, s.Updated, s.Deleted, s.Skipped, s.Error}
+	}, Equal([]interface{}{source, discovered, created, updated, deleted, skipped, ""}))
+}
+
+func discovered(name string, version string) InstalledFeature {
+	return InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       InstalledFeatureSpec{Kind: name, Version: version},
+	}
+}
+
+// staticSource discovers the features set by the test.
+type staticSource struct {
+	name string
+
+	mutex    sync.Mutex
+	features []InstalledFeature
+	err      error
+}
+
+func (s *staticSource) Name() string {
+	return s.name
+}
+
+func (s *staticSource) Discover(context.Context) ([]InstalledFeature, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.features, s.err
+}
+
+func (s *staticSource) set(features ...InstalledFeature) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.features, s.err = features, nil
+}
+
+func (s *staticSource) fail(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.err = err
+}
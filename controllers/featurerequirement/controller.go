@@ -0,0 +1,138 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package featurerequirement
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/resolver"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// RequeueTime is the default requeuing time when the operator is running in problems
+const RequeueTime = 60 * time.Second
+
+// The default requeue for error handling
+var errorRequeue = ctrl.Result{RequeueAfter: RequeueTime}
+
+// Reconciler checks FeatureRequirement objects against the installed features. The requirements are checked again
+// whenever a feature they refer to changes.
+type Reconciler struct {
+	Client client.Client
+
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	// MaxConcurrentReconciles is the number of requirements reconciled in parallel. Defaults to 1.
+	MaxConcurrentReconciles int
+}
+
+// +kubebuilder:rbac:groups=features.kaiserpfalz-edv.de,resources=featurerequirements,verbs=get;list;watch
+// +kubebuilder:rbac:groups=features.kaiserpfalz-edv.de,resources=featurerequirements/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=features.kaiserpfalz-edv.de,resources=installedfeatures,verbs=get;list;watch
+
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&featuresv1alpha1.FeatureRequirement{}).
+		Watches(&source.Kind{Type: &featuresv1alpha1.InstalledFeature{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.requiringFeature)}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+// requiringFeature maps a feature to the requests of all requirements referring to it by name or kind.
+func (r *Reconciler) requiringFeature(obj handler.MapObject) []reconcile.Request {
+	feature, ok := obj.Object.(*featuresv1alpha1.InstalledFeature)
+	if !ok {
+		return nil
+	}
+
+	requirements := &featuresv1alpha1.FeatureRequirementList{}
+	if err := r.Client.List(context.Background(), requirements); err != nil {
+		r.Log.Error(err, "could not list the featurerequirements", "feature", obj.Meta.GetName())
+		return nil
+	}
+
+	var result []reconcile.Request
+	for i := range requirements.Items {
+		if resolver.RequiresFeature(&requirements.Items[i], feature) {
+			result = append(result, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: requirements.Items[i].Namespace, Name: requirements.Items[i].Name},
+			})
+		}
+	}
+
+	return result
+}
+
+func (r *Reconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	reqLogger := r.Log.WithValues("feature-requirement", req.NamespacedName)
+	reqLogger.Info("working on", "ctx", ctx)
+
+	attempt := 0
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		attempt++
+		if attempt > 1 {
+			reqLogger.Info("retrying after conflict", "attempt", attempt)
+		}
+
+		requirement := &featuresv1alpha1.FeatureRequirement{}
+		if err := r.Client.Get(ctx, req.NamespacedName, requirement); err != nil {
+			return err
+		}
+
+		features := &featuresv1alpha1.InstalledFeatureList{}
+		if err := r.Client.List(ctx, features); err != nil {
+			reqLogger.Info("could not list the installedfeatures")
+
+			return err
+		}
+
+		status := resolver.ResolveRequirement(features.Items, requirement)
+		if equality.Semantic.DeepEqual(requirement.Status, status) {
+			return nil
+		}
+
+		patch := client.MergeFromWithOptions(requirement.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		requirement.Status = status
+
+		return r.Client.Status().Patch(ctx, requirement, patch)
+	})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{Requeue: false}, nil
+		}
+
+		return errorRequeue, err
+	}
+
+	return ctrl.Result{}, nil
+}
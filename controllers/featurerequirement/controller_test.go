@@ -0,0 +1,121 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package featurerequirement_test
+
+import (
+	"context"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/controllers/featurerequirement"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/resolver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("FeatureRequirement controller", func() {
+	const (
+		name      = "my-app"
+		namespace = "default"
+	)
+	var (
+		key     = types.NamespacedName{Namespace: namespace, Name: name}
+		request = reconcile.Request{NamespacedName: key}
+
+		k8s client.Client
+		sut Reconciler
+	)
+
+	setup := func(objs ...runtime.Object) {
+		scheme := runtime.NewScheme()
+		Expect(AddToScheme(scheme)).Should(Succeed())
+
+		k8s = fake.NewFakeClientWithScheme(scheme, objs...)
+		sut = Reconciler{Client: k8s, Log: logf.Log, Scheme: scheme}
+	}
+
+	feature := func(name, version string) *InstalledFeature {
+		return &InstalledFeature{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       InstalledFeatureSpec{Kind: name, Version: version},
+		}
+	}
+
+	requirement := func(requires ...RequiredFeature) *FeatureRequirement {
+		return &FeatureRequirement{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, ResourceVersion: "1"},
+			Spec:       FeatureRequirementSpec{Requires: requires},
+		}
+	}
+
+	load := func() FeatureRequirementStatus {
+		result := &FeatureRequirement{}
+		Expect(k8s.Get(context.Background(), key, result)).Should(Succeed())
+
+		return result.Status
+	}
+
+	It("should mark requirements met by the installed features as satisfied", func() {
+		setup(
+			feature("postgres-operator", "1.6.0"),
+			requirement(RequiredFeature{Name: "postgres-operator", Version: ">=1.5.0"}),
+		)
+
+		result, err := sut.Reconcile(request)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result).Should(Equal(reconcile.Result{}))
+		Expect(load()).Should(Equal(FeatureRequirementStatus{Phase: PhaseSatisfied}))
+	})
+
+	It("should list the unsatisfied features", func() {
+		setup(
+			feature("postgres-operator", "1.4.2"),
+			requirement(RequiredFeature{Name: "postgres-operator", Version: ">=1.5.0"}, RequiredFeature{Name: "cert-manager"}),
+		)
+
+		_, err := sut.Reconcile(request)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(load()).Should(Equal(FeatureRequirementStatus{
+			Phase:   PhaseUnsatisfied,
+			Message: resolver.MessageMissingRequirements,
+			Unsatisfied: []UnsatisfiedFeature{
+				{
+					RequiredFeature: RequiredFeature{Namespace: namespace, Name: "postgres-operator", Version: ">=1.5.0"},
+					Reason:          "version '1.4.2' is not in range '>=1.5.0'",
+				},
+				{RequiredFeature: RequiredFeature{Namespace: namespace, Name: "cert-manager"}, Reason: "not installed"},
+			},
+		}))
+	})
+
+	It("should ignore deleted requirements", func() {
+		setup(feature("postgres-operator", "1.6.0"))
+
+		result, err := sut.Reconcile(request)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result).Should(Equal(reconcile.Result{}))
+	})
+})
@@ -0,0 +1,183 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package fluxdiscovery is the discovery source of the Helm releases managed by Flux. Every HelmRelease is turned into
+// a feature in the namespace of the HelmRelease. The HelmReleases are read as unstructured objects, so the Flux API is
+// not needed to build or run the operator.
+package fluxdiscovery
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// Source is the name of the discovery source and the value of the discovered-by label of its features.
+	Source = "flux"
+
+	// ConditionReady is the condition Flux reports the state of the release with.
+	ConditionReady = "Ready"
+)
+
+var (
+	_ discovery.Discoverer = &Discoverer{}
+	_ discovery.Watcher    = &Discoverer{}
+
+	// HelmReleaseKind is the kind of the Flux HelmReleases.
+	HelmReleaseKind = schema.GroupVersionKind{
+		Group:   "helm.toolkit.fluxcd.io",
+		Version: "v2beta1",
+		Kind:    "HelmRelease",
+	}
+)
+
+// Discoverer emits a feature for every HelmRelease.
+type Discoverer struct {
+	Reader client.Reader
+}
+
+// New is the factory of the source.
+func New(mgr ctrl.Manager) (discovery.Discoverer, error) {
+	return &Discoverer{Reader: mgr.GetClient()}, nil
+}
+
+// +kubebuilder:rbac:groups=helm.toolkit.fluxcd.io,resources=helmreleases,verbs=get;list;watch
+
+func (d *Discoverer) Name() string {
+	return Source
+}
+
+// Watch triggers a sync on every change of a HelmRelease.
+func (d *Discoverer) Watch(mgr ctrl.Manager, trigger func()) error {
+	release := &unstructured.Unstructured{}
+	release.SetGroupVersionKind(HelmReleaseKind)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("flux-discovery").
+		For(release).
+		Complete(reconcile.Func(func(ctrl.Request) (ctrl.Result, error) {
+			trigger()
+
+			return ctrl.Result{}, nil
+		}))
+}
+
+// Discover returns a feature for every HelmRelease that has been reconciled by Flux at least once. HelmReleases being
+// deleted are ignored.
+func (d *Discoverer) Discover(ctx context.Context) ([]featuresv1alpha1.InstalledFeature, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(HelmReleaseKind.GroupVersion().WithKind(HelmReleaseKind.Kind + "List"))
+	if err := d.Reader.List(ctx, list); err != nil {
+		return nil, err
+	}
+
+	result := make([]featuresv1alpha1.InstalledFeature, 0, len(list.Items))
+	for i := range list.Items {
+		release := &list.Items[i]
+		if release.GetDeletionTimestamp() != nil || condition(release, ConditionReady) == nil {
+			continue
+		}
+
+		result = append(result, *Feature(release))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// Feature returns the feature of the HelmRelease. The chart is the kind of the feature, the last applied chart version
+// its version. Before the first successful release the requested chart version is used.
+func Feature(release *unstructured.Unstructured) *featuresv1alpha1.InstalledFeature {
+	version := nested(release, "status", "lastAppliedRevision")
+	if version == "" {
+		version = nested(release, "spec", "chart", "spec", "version")
+	}
+
+	phase, message := Phase(release)
+
+	return &featuresv1alpha1.InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: release.GetNamespace(),
+			Name:      release.GetName(),
+			Annotations: map[string]string{
+				featuresv1alpha1.AnnotationDiscoveredFrom: HelmReleaseKind.Kind + "/" + release.GetName(),
+				featuresv1alpha1.AnnotationSourcePhase:    phase,
+				featuresv1alpha1.AnnotationSourceMessage:  message,
+			},
+		},
+		Spec: featuresv1alpha1.InstalledFeatureSpec{
+			Kind:        nested(release, "spec", "chart", "spec", "chart"),
+			Version:     version,
+			Description: release.GetAnnotations()["description"],
+		},
+	}
+}
+
+// Phase maps the Ready condition of a HelmRelease to the phase of a feature. A ready release is provisioned, a release
+// not ready because of a failure (InstallFailed, UpgradeFailed, ...) failed and all other releases (progressing,
+// unknown, ...) are initializing. The message is the message of the condition.
+func Phase(release *unstructured.Unstructured) (string, string) {
+	ready := condition(release, ConditionReady)
+	if ready == nil {
+		return featuresv1alpha1.PhaseInitializing, ""
+	}
+
+	status, _ := ready["status"].(string)
+	reason, _ := ready["reason"].(string)
+	message, _ := ready["message"].(string)
+
+	switch {
+	case status == string(metav1.ConditionTrue):
+		return featuresv1alpha1.PhaseProvisioned, message
+	case status == string(metav1.ConditionFalse) && strings.HasSuffix(reason, "Failed"):
+		return featuresv1alpha1.PhaseFailed, message
+	default:
+		return featuresv1alpha1.PhaseInitializing, message
+	}
+}
+
+// condition returns the condition of the given type or nil.
+func condition(release *unstructured.Unstructured, conditionType string) map[string]interface{} {
+	conditions, _, _ := unstructured.NestedSlice(release.Object, "status", "conditions")
+	for _, value := range conditions {
+		if c, ok := value.(map[string]interface{}); ok && c["type"] == conditionType {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func nested(release *unstructured.Unstructured, fields ...string) string {
+	value, _, _ := unstructured.NestedString(release.Object, fields...)
+	return value
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fluxdiscovery_test
+
+import (
+	"context"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/fluxdiscovery"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Flux discovery source", func() {
+	discover := func(releases ...runtime.Object) []InstalledFeature {
+		sut := &fluxdiscovery.Discoverer{Reader: fake.NewFakeClientWithScheme(scheme, releases...)}
+
+		features, err := sut.Discover(context.Background())
+		Expect(err).ShouldNot(HaveOccurred())
+
+		return features
+	}
+
+	It("should use chart and last applied revision of the release", func() {
+		ready := helmRelease("monitoring", "prometheus", "11.x")
+		setReady(ready, "True", "ReconciliationSucceeded", "Release reconciliation succeeded")
+		Expect(unstructured.SetNestedField(ready.Object, "11.16.2", "status", "lastAppliedRevision")).Should(Succeed())
+
+		features := discover(ready)
+
+		Expect(features).Should(HaveLen(1))
+		Expect(features[0].Namespace).Should(Equal("monitoring"))
+		Expect(features[0].Name).Should(Equal("prometheus"))
+		Expect(features[0].Spec).Should(Equal(InstalledFeatureSpec{
+			Kind:    "prometheus",
+			Version: "11.16.2",
+		}))
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationDiscoveredFrom, "HelmRelease/prometheus"))
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationSourcePhase, PhaseProvisioned))
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationSourceMessage, "Release reconciliation succeeded"))
+	})
+
+	It("should use the requested chart version before the first release", func() {
+		progressing := helmRelease("monitoring", "prometheus", "11.16.2")
+		setReady(progressing, "Unknown", "Progressing", "reconciliation in progress")
+
+		features := discover(progressing)
+
+		Expect(features[0].Spec.Version).Should(Equal("11.16.2"))
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationSourcePhase, PhaseInitializing))
+	})
+
+	It("should ignore releases never reconciled by Flux", func() {
+		Expect(discover(helmRelease("monitoring", "prometheus", "11.16.2"))).Should(BeEmpty())
+	})
+
+	It("should map the ready condition onto the phase", func() {
+		phase := func(status string, reason string) string {
+			release := helmRelease("monitoring", "prometheus", "11.16.2")
+			setReady(release, status, reason, "")
+
+			result, _ := fluxdiscovery.Phase(release)
+			return result
+		}
+
+		Expect(phase("True", "ReconciliationSucceeded")).Should(Equal(PhaseProvisioned))
+		Expect(phase("False", "InstallFailed")).Should(Equal(PhaseFailed))
+		Expect(phase("False", "UpgradeFailed")).Should(Equal(PhaseFailed))
+		Expect(phase("False", "ArtifactFailed")).Should(Equal(PhaseFailed))
+		Expect(phase("False", "DependencyNotReady")).Should(Equal(PhaseInitializing))
+		Expect(phase("Unknown", "Progressing")).Should(Equal(PhaseInitializing))
+	})
+})
+
+func helmRelease(namespace string, name string, version string) *unstructured.Unstructured {
+	result := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"chart": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"chart":   "prometheus",
+					"version": version,
+					"sourceRef": map[string]interface{}{
+						"kind": "HelmRepository",
+						"name": "prometheus-community",
+					},
+				},
+			},
+		},
+	}}
+	result.SetGroupVersionKind(fluxdiscovery.HelmReleaseKind)
+	result.SetNamespace(namespace)
+	result.SetName(name)
+
+	return result
+}
+
+func setReady(release *unstructured.Unstructured, status string, reason string, message string) {
+	Expect(unstructured.SetNestedSlice(release.Object, []interface{}{
+		map[string]interface{}{
+			"type":    fluxdiscovery.ConditionReady,
+			"status":  status,
+			"reason":  reason,
+			"message": message,
+		},
+	}, "status", "conditions")).Should(Succeed())
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fluxdiscovery_test
+
+import (
+	"context"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/fluxdiscovery"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("Flux discovery against the API server", func() {
+	const namespace = "monitoring"
+
+	var (
+		ctx    context.Context
+		k8s    client.Client
+		engine *discovery.Engine
+		lookup = types.NamespacedName{Namespace: namespace, Name: "prometheus"}
+	)
+
+	BeforeEach(func() {
+		if cfg == nil {
+			Skip("the envtest binaries are not installed")
+		}
+
+		ctx = context.Background()
+
+		envScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(envScheme)).Should(Succeed())
+		Expect(AddToScheme(envScheme)).Should(Succeed())
+
+		var err error
+		k8s, err = client.New(cfg, client.Options{Scheme: envScheme})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = k8s.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+		Expect(err == nil || errors.IsAlreadyExists(err)).Should(BeTrue())
+
+		engine = discovery.NewEngine(&controllers.OcpClientProd{Client: k8s}, logf.Log, "default", 0)
+		engine.Add(&fluxdiscovery.Discoverer{Reader: k8s})
+	})
+
+	sync := func() discovery.Stats {
+		stats := engine.SyncAll(ctx)
+		Expect(stats).Should(HaveLen(1))
+		Expect(stats[0].Error).Should(BeEmpty())
+
+		return stats[0]
+	}
+
+	It("should create the feature of the release, follow its condition and delete it with the release", func() {
+		prometheus := helmRelease(namespace, "prometheus", "11.16.2")
+		Expect(k8s.Create(ctx, prometheus)).Should(Succeed())
+		setReady(prometheus, "Unknown", "Progressing", "reconciliation in progress")
+		Expect(k8s.Status().Update(ctx, prometheus)).Should(Succeed())
+
+		Expect(sync().Created).Should(Equal(1))
+
+		feature := &InstalledFeature{}
+		Expect(k8s.Get(ctx, lookup, feature)).Should(Succeed())
+		Expect(feature.Spec.Version).Should(Equal("11.16.2"))
+		Expect(feature.Labels).Should(HaveKeyWithValue(LabelDiscoveredBy, fluxdiscovery.Source))
+		Expect(feature.Annotations).Should(HaveKeyWithValue(AnnotationSourcePhase, PhaseInitializing))
+
+		setReady(prometheus, "False", "InstallFailed", "install retries exhausted")
+		Expect(k8s.Status().Update(ctx, prometheus)).Should(Succeed())
+		Expect(sync().Updated).Should(Equal(1))
+
+		Expect(k8s.Get(ctx, lookup, feature)).Should(Succeed())
+		Expect(feature.Annotations).Should(HaveKeyWithValue(AnnotationSourcePhase, PhaseFailed))
+
+		Expect(k8s.Delete(ctx, prometheus)).Should(Succeed())
+		Expect(sync().Deleted).Should(Equal(1))
+
+		Expect(errors.IsNotFound(k8s.Get(ctx, lookup, feature))).Should(BeTrue())
+	})
+})
@@ -0,0 +1,227 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package helmdiscovery is the discovery source of the releases installed by Helm 3. Helm stores every revision of a
+// release in a secret of type helm.sh/release.v1, the latest revision of every release is turned into a feature in the
+// namespace of the release.
+package helmdiscovery
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/go-logr/logr"
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Source is the name of the discovery source and the value of the discovered-by label of its features.
+	Source = "helm"
+
+	// SecretType is the type of the secrets containing the Helm release records.
+	SecretType corev1.SecretType = "helm.sh/release.v1"
+	// AnnotationAppVersion contains the version of the application packaged by the chart.
+	AnnotationAppVersion = "features.kaiserpfalz-edv.de/app-version"
+)
+
+// The states of Helm releases.
+const (
+	StatusDeployed        = "deployed"
+	StatusFailed          = "failed"
+	StatusUninstalling    = "uninstalling"
+	StatusUninstalled     = "uninstalled"
+	StatusSuperseded      = "superseded"
+	StatusPendingInstall  = "pending-install"
+	StatusPendingUpgrade  = "pending-upgrade"
+	StatusPendingRollback = "pending-rollback"
+)
+
+var _ discovery.Discoverer = &Discoverer{}
+
+// Release is the part of a Helm release record needed for the feature.
+type Release struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		Status      string `json:"status"`
+		Description string `json:"description"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name        string `json:"name"`
+			Version     string `json:"version"`
+			AppVersion  string `json:"appVersion"`
+			Description string `json:"description"`
+			Home        string `json:"home"`
+			Maintainers []struct {
+				Name string `json:"name"`
+			} `json:"maintainers"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+// Discoverer emits a feature for every installed Helm release. The secrets are read directly from the API server and
+// not watched, so the operator does not cache all secrets of the cluster. The releases are synced with the interval of
+// the discovery.
+type Discoverer struct {
+	Reader client.Reader
+	Log    logr.Logger
+}
+
+// New is the factory of the source.
+func New(mgr ctrl.Manager) (discovery.Discoverer, error) {
+	return &Discoverer{Reader: mgr.GetAPIReader(), Log: ctrl.Log.WithName("discovery").WithName(Source)}, nil
+}
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list
+
+func (d *Discoverer) Name() string {
+	return Source
+}
+
+// Discover returns a feature for the latest revision of every release. Uninstalled releases and releases being
+// uninstalled are left out, so their features are deleted. Records that can not be decoded are logged and ignored.
+func (d *Discoverer) Discover(ctx context.Context) ([]featuresv1alpha1.InstalledFeature, error) {
+	secrets := &corev1.SecretList{}
+	if err := d.Reader.List(ctx, secrets, client.MatchingLabels{"owner": "helm"}); err != nil {
+		return nil, err
+	}
+
+	latest := make(map[types.NamespacedName]*Release)
+	for _, secret := range secrets.Items {
+		if secret.Type != SecretType {
+			continue
+		}
+
+		release, err := Decode(secret.Data["release"])
+		if err != nil {
+			d.Log.Info("ignoring undecodable helm release", "secret", secret.Namespace+"/"+secret.Name, "error", err.Error())
+			continue
+		}
+		if release.Namespace == "" {
+			release.Namespace = secret.Namespace
+		}
+
+		key := types.NamespacedName{Namespace: release.Namespace, Name: release.Name}
+		if current, ok := latest[key]; !ok || current.Version < release.Version {
+			latest[key] = release
+		}
+	}
+
+	result := make([]featuresv1alpha1.InstalledFeature, 0, len(latest))
+	for _, release := range latest {
+		if release.Info.Status == StatusUninstalled || release.Info.Status == StatusUninstalling {
+			continue
+		}
+
+		result = append(result, *Feature(release))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// Decode decodes a release record. Helm stores the releases as base64 encoded and gzipped JSON.
+func Decode(data []byte) (*Release, error) {
+	decoded := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(decoded, data)
+	if err != nil {
+		return nil, err
+	}
+	decoded = decoded[:n]
+
+	if bytes.HasPrefix(decoded, []byte{0x1f, 0x8b, 0x08}) {
+		reader, err := gzip.NewReader(bytes.NewReader(decoded))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		decoded, err = ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	release := &Release{}
+	if err := json.Unmarshal(decoded, release); err != nil {
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// Feature returns the feature of the release. The chart is the kind and the chart version the version of the feature.
+func Feature(release *Release) *featuresv1alpha1.InstalledFeature {
+	chart := release.Chart.Metadata
+
+	provider := ""
+	if len(chart.Maintainers) > 0 {
+		provider = chart.Maintainers[0].Name
+	}
+
+	return &featuresv1alpha1.InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: release.Namespace,
+			Name:      release.Name,
+			Annotations: map[string]string{
+				featuresv1alpha1.AnnotationDiscoveredFrom: fmt.Sprintf("sh.helm.release.v1.%s.v%d", release.Name, release.Version),
+				featuresv1alpha1.AnnotationSourcePhase:    Phase(release.Info.Status),
+				featuresv1alpha1.AnnotationSourceMessage:  release.Info.Description,
+				AnnotationAppVersion:                      chart.AppVersion,
+			},
+		},
+		Spec: featuresv1alpha1.InstalledFeatureSpec{
+			Kind:        chart.Name,
+			Version:     chart.Version,
+			Provider:    provider,
+			Description: chart.Description,
+			Uri:         chart.Home,
+		},
+	}
+}
+
+// Phase maps the status of a release to the phase of a feature. A deployed release is provisioned, a failed one failed
+// and a pending one initializing.
+func Phase(status string) string {
+	switch status {
+	case StatusDeployed, StatusSuperseded:
+		return featuresv1alpha1.PhaseProvisioned
+	case StatusFailed:
+		return featuresv1alpha1.PhaseFailed
+	default:
+		return featuresv1alpha1.PhaseInitializing
+	}
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package helmdiscovery_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/helmdiscovery"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("Helm discovery source", func() {
+	discover := func(secrets ...runtime.Object) []InstalledFeature {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).Should(Succeed())
+
+		sut := &helmdiscovery.Discoverer{Reader: fake.NewFakeClientWithScheme(scheme, secrets...), Log: logf.NullLogger{}}
+
+		features, err := sut.Discover(context.Background())
+		Expect(err).ShouldNot(HaveOccurred())
+
+		return features
+	}
+
+	It("should create a feature of the deployed release", func() {
+		features := discover(release("monitoring", "prometheus", 1, helmdiscovery.StatusDeployed, "13.2.1", "2.24.0"))
+
+		Expect(features).Should(HaveLen(1))
+		Expect(features[0].Namespace).Should(Equal("monitoring"))
+		Expect(features[0].Name).Should(Equal("prometheus"))
+		Expect(features[0].Spec).Should(Equal(InstalledFeatureSpec{
+			Kind:        "prometheus",
+			Version:     "13.2.1",
+			Provider:    "prometheus-community",
+			Description: "Prometheus is a monitoring system and time series database.",
+			Uri:         "https://prometheus.io/",
+		}))
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(helmdiscovery.AnnotationAppVersion, "2.24.0"))
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationSourcePhase, PhaseProvisioned))
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationDiscoveredFrom, "sh.helm.release.v1.prometheus.v1"))
+	})
+
+	It("should use the latest revision of the release", func() {
+		features := discover(
+			release("monitoring", "prometheus", 1, helmdiscovery.StatusSuperseded, "13.2.1", "2.24.0"),
+			release("monitoring", "prometheus", 3, helmdiscovery.StatusDeployed, "13.3.0", "2.24.1"),
+			release("monitoring", "prometheus", 2, helmdiscovery.StatusSuperseded, "13.2.2", "2.24.0"),
+		)
+
+		Expect(features).Should(HaveLen(1))
+		Expect(features[0].Spec.Version).Should(Equal("13.3.0"))
+	})
+
+	It("should fail the feature of a failed release", func() {
+		features := discover(release("monitoring", "prometheus", 1, helmdiscovery.StatusFailed, "13.2.1", "2.24.0"))
+
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationSourcePhase, PhaseFailed))
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationSourceMessage, "Release failed"))
+	})
+
+	It("should initialize the feature of a pending release", func() {
+		features := discover(release("monitoring", "prometheus", 1, helmdiscovery.StatusPendingInstall, "13.2.1", "2.24.0"))
+
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationSourcePhase, PhaseInitializing))
+	})
+
+	It("should leave out uninstalled releases and releases being uninstalled", func() {
+		features := discover(
+			release("monitoring", "prometheus", 1, helmdiscovery.StatusDeployed, "13.2.1", "2.24.0"),
+			release("monitoring", "prometheus", 2, helmdiscovery.StatusUninstalled, "13.2.1", "2.24.0"),
+			release("logging", "loki", 1, helmdiscovery.StatusUninstalling, "2.3.0", "2.1.0"),
+		)
+
+		Expect(features).Should(BeEmpty())
+	})
+
+	It("should ignore other secrets and undecodable records", func() {
+		other := release("monitoring", "prometheus", 1, helmdiscovery.StatusDeployed, "13.2.1", "2.24.0")
+		other.Type = corev1.SecretTypeOpaque
+		broken := release("logging", "loki", 1, helmdiscovery.StatusDeployed, "2.3.0", "2.1.0")
+		broken.Data["release"] = []byte("not a release")
+
+		features := discover(other, broken)
+
+		Expect(features).Should(BeEmpty())
+	})
+
+	It("should decode uncompressed records", func() {
+		record, err := json.Marshal(map[string]interface{}{"name": "loki", "version": 1})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		decoded, err := helmdiscovery.Decode([]byte(base64.StdEncoding.EncodeToString(record)))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(decoded.Name).Should(Equal("loki"))
+	})
+})
+
+// release creates the secret of a release revision like Helm does.
+func release(namespace string, name string, revision int, status string, chartVersion string, appVersion string) *corev1.Secret {
+	record, err := json.Marshal(map[string]interface{}{
+		"name":      name,
+		"namespace": namespace,
+		"version":   revision,
+		"info": map[string]interface{}{
+			"status":      status,
+			"description": "Release failed",
+		},
+		"chart": map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":        name,
+				"version":     chartVersion,
+				"appVersion":  appVersion,
+				"description": "Prometheus is a monitoring system and time series database.",
+				"home":        "https://prometheus.io/",
+				"maintainers": []interface{}{map[string]interface{}{"name": "prometheus-community"}},
+			},
+		},
+	})
+	Expect(err).ShouldNot(HaveOccurred())
+
+	compressed := &bytes.Buffer{}
+	writer := gzip.NewWriter(compressed)
+	_, err = writer.Write(record)
+	Expect(err).ShouldNot(HaveOccurred())
+	Expect(writer.Close()).Should(Succeed())
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      fmt.Sprintf("sh.helm.release.v1.%s.v%d", name, revision),
+			Labels: map[string]string{
+				"owner":   "helm",
+				"name":    name,
+				"status":  status,
+				"version": fmt.Sprint(revision),
+			},
+		},
+		Type: helmdiscovery.SecretType,
+		Data: map[string][]byte{"release": []byte(base64.StdEncoding.EncodeToString(compressed.Bytes()))},
+	}
+}
@@ -21,9 +21,20 @@ import (
 	"github.com/go-logr/logr"
 	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
 	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/compatibility"
+	appsv1 "k8s.io/api/apps/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"time"
 )
 
@@ -44,96 +55,142 @@ type Reconciler struct {
 
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+
+	// MaxConcurrentReconciles is the number of features reconciled in parallel. Defaults to 1.
+	MaxConcurrentReconciles int
+
+	// APIs checks the provided APIs of the features. Nil disables the check.
+	APIs controllers.APIChecker
+
+	// Workloads checks the workloads of the features. Nil disables the check.
+	Workloads controllers.WorkloadChecker
+
+	// Kubernetes returns the version of the cluster the Kubernetes bounds of the features are checked against. Nil
+	// disables the check.
+	Kubernetes discovery.ServerVersionInterface
+
+	// UpgradeTarget is the Kubernetes version of the next upgrade the features are checked against. Empty disables the
+	// check.
+	UpgradeTarget string
+	// Removals are the API removals the consumed APIs of the features are checked against.
+	Removals compatibility.Removals
 }
 
 // +kubebuilder:rbac:groups=features.kaiserpfalz-edv.de,resources=installedfeatures,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=features.kaiserpfalz-edv.de,resources=installedfeatures/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=features.kaiserpfalz-edv.de,resources=installedfeaturegroups,verbs=get;list;watch
 // +kubebuilder:rbac:groups=features.kaiserpfalz-edv.de,resources=installedfeaturegroups/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list;watch
 
+// SetupWithManager registers the reconciler. With an API checker, the features providing an API of a changed CRD are
+// reconciled, too. With a workload checker, the features selecting a changed workload are reconciled.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&featuresv1alpha1.InstalledFeature{}).
-		Complete(r)
-}
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles})
 
-func (r *Reconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	ctx := context.Background()
-	reqLogger := r.Log.WithValues("installedfeature", req.NamespacedName)
-	reqLogger.Info("working on", "ctx", ctx)
-
-	changed := false
+	if r.APIs != nil {
+		builder = builder.Watches(&source.Kind{Type: &apiextensionsv1.CustomResourceDefinition{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.providingFeatures)})
+	}
 
-	instance, err := r.Client.LoadInstalledFeature(ctx, req.NamespacedName)
-	if err != nil {
-		if errors.IsNotFound(err) {
-			return ctrl.Result{}, err
+	if r.Workloads != nil {
+		for _, workload := range []runtime.Object{&appsv1.Deployment{}, &appsv1.StatefulSet{}, &appsv1.DaemonSet{}} {
+			builder = builder.Watches(&source.Kind{Type: workload},
+				&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.selectingFeatures)})
 		}
-
-		return errorRequeue, err
 	}
 
-	changed, err = r.handleDependingOn(ctx, instance, reqLogger, changed)
-	if err != nil {
-		return errorRequeue, err
-	}
+	return builder.Complete(r)
+}
 
-	changed, err = r.handleDependent(ctx, instance, reqLogger, changed)
+// selectingFeatures maps a workload to the requests of all features selecting it.
+func (r *Reconciler) selectingFeatures(obj handler.MapObject) []reconcile.Request {
+	features, err := r.Client.ListInstalledFeatures(context.Background())
 	if err != nil {
-		return errorRequeue, err
+		r.Log.Error(err, "could not list the installedfeatures", "workload", obj.Meta.GetName())
+		return nil
 	}
 
-	changed, err = r.handleGroupEntry(ctx, instance, reqLogger, changed)
-	if err != nil {
-		return errorRequeue, err
+	var result []reconcile.Request
+	for _, feature := range features {
+		if feature.Spec.Workloads == nil || workloadNamespace(&feature) != obj.Meta.GetNamespace() {
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(&feature.Spec.Workloads.Selector)
+		if err != nil || !selector.Matches(labels.Set(obj.Meta.GetLabels())) {
+			continue
+		}
+
+		result = append(result, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: feature.Namespace, Name: feature.Name},
+		})
 	}
 
-	changed = r.handleFinalizer(ctx, instance, reqLogger, changed)
+	return result
+}
 
-	return r.handleUpdate(ctx, instance, reqLogger, changed)
+// workloadNamespace returns the namespace of the workloads of the feature, which defaults to the feature namespace.
+func workloadNamespace(feature *featuresv1alpha1.InstalledFeature) string {
+	if feature.Spec.Workloads.Namespace == "" {
+		return feature.Namespace
+	}
+
+	return feature.Spec.Workloads.Namespace
 }
 
-func (r *Reconciler) markDependencyAsMissing(instance *featuresv1alpha1.InstalledFeature, dependency featuresv1alpha1.InstalledFeatureRef, reqLogger logr.Logger) {
-	if instance.Status.MissingDependencies == nil {
-		instance.Status.MissingDependencies = make([]featuresv1alpha1.InstalledFeatureRef, 0)
+// providingFeatures maps a CRD to the requests of all features providing an API of its group and kind.
+func (r *Reconciler) providingFeatures(obj handler.MapObject) []reconcile.Request {
+	crd, ok := obj.Object.(*apiextensionsv1.CustomResourceDefinition)
+	if !ok {
+		return nil
 	}
 
-	for _, dep := range instance.Status.MissingDependencies {
-		reqLogger.Info("missing feature already listed", "feature", dependency)
+	features, err := r.Client.ListInstalledFeatures(context.Background())
+	if err != nil {
+		r.Log.Error(err, "could not list the installedfeatures", "crd", crd.Name)
+		return nil
+	}
 
-		if dep.Namespace == dependency.Namespace && dep.Name == dependency.Name {
-			return
+	var result []reconcile.Request
+	for _, feature := range features {
+		for _, api := range feature.Spec.ProvidedAPIs {
+			if api.Group == crd.Spec.Group && api.Kind == crd.Spec.Names.Kind {
+				result = append(result, reconcile.Request{
+					NamespacedName: types.NamespacedName{Namespace: feature.Namespace, Name: feature.Name},
+				})
+				break
+			}
 		}
 	}
 
-	reqLogger.Info("mark the missing feature", "feature", dependency)
-
-	instance.Status.MissingDependencies = append(instance.Status.MissingDependencies, dependency)
+	return result
 }
 
-func (r *Reconciler) removeMissingDependencyStatus(instance *featuresv1alpha1.InstalledFeature, dependency featuresv1alpha1.InstalledFeatureRef, reqLogger logr.Logger) bool {
-	i := r.indexOfMissingDependency(instance, dependency)
-	if i == -1 {
-		return false
-	}
-
-	reqLogger.Info("remove the marked missing feature", "feature", dependency)
+func (r *Reconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	reqLogger := r.Log.WithValues("installedfeature", req.NamespacedName)
+	reqLogger.Info("working on", "ctx", ctx)
 
-	instance.Status.MissingDependencies[i] = instance.Status.MissingDependencies[len(instance.Status.MissingDependencies)-1]
-	instance.Status.MissingDependencies = instance.Status.MissingDependencies[:len(instance.Status.MissingDependencies)-1]
+	changed := false
 
-	return true
-}
+	instance, err := r.Client.LoadInstalledFeature(ctx, req.NamespacedName)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
 
-func (r *Reconciler) indexOfMissingDependency(instance *featuresv1alpha1.InstalledFeature, dependency featuresv1alpha1.InstalledFeatureRef) int {
-	if instance.Status.MissingDependencies == nil {
-		return -1
+		return errorRequeue, err
 	}
 
-	for i, d := range instance.Status.MissingDependencies {
-		if d.Name == dependency.Name && d.Namespace == dependency.Namespace {
-			return i
-		}
+	err = r.handleStatus(ctx, instance, reqLogger)
+	if err != nil {
+		return errorRequeue, err
 	}
 
-	return -1
+	changed = r.handleFinalizer(ctx, instance, reqLogger, changed)
+
+	return r.handleUpdate(ctx, instance, reqLogger, changed)
 }
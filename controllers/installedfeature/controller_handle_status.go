@@ -0,0 +1,232 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package installedfeature
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/compatibility"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/resolver"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// handleStatus resolves all features and groups and patches every status differing from the resolved one. So the
+// dependent features, the dependencies and the group of the instance are updated, too. The loaded instance replaces
+// the listed one since it is the most recent version.
+//
+// Every patch is only applied to the listed version of the object. When another reconcile changed the object in the
+// meantime, the instance is reloaded and the status is resolved again from a fresh list. So concurrent reconciles never
+// overwrite the entries written by each other.
+func (r *Reconciler) handleStatus(ctx context.Context, instance *featuresv1alpha1.InstalledFeature, reqLogger logr.Logger) error {
+	attempt := 0
+
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		attempt++
+		if attempt > 1 {
+			reqLogger.Info("retrying after conflict", "attempt", attempt)
+
+			reloaded, err := r.Client.LoadInstalledFeature(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name})
+			if err != nil {
+				return err
+			}
+			reloaded.DeepCopyInto(instance)
+		}
+
+		return r.resolveStatus(ctx, instance, reqLogger)
+	})
+}
+
+func (r *Reconciler) resolveStatus(ctx context.Context, instance *featuresv1alpha1.InstalledFeature, reqLogger logr.Logger) error {
+	reqLogger.Info("handling status")
+
+	listed, err := r.Client.ListInstalledFeatures(ctx)
+	if err != nil {
+		reqLogger.Info("could not list the installedfeatures")
+
+		return err
+	}
+
+	groups, err := r.Client.ListInstalledFeatureGroups(ctx)
+	if err != nil {
+		reqLogger.Info("could not list the installedfeaturegroups")
+
+		return err
+	}
+
+	features := make([]*featuresv1alpha1.InstalledFeature, 0, len(listed)+1)
+	features = append(features, instance)
+	for i := range listed {
+		if listed[i].Namespace != instance.Namespace || listed[i].Name != instance.Name {
+			features = append(features, &listed[i])
+		}
+	}
+
+	input := make([]featuresv1alpha1.InstalledFeature, len(features))
+	for i, feature := range features {
+		input[i] = *feature
+	}
+
+	// the resolver takes the missing APIs, workloads and conditions over from the status, only the ones of the instance
+	// are checked again
+	if r.APIs != nil {
+		missing, err := r.APIs.MissingAPIs(ctx, instance.Spec.ProvidedAPIs)
+		if err != nil {
+			reqLogger.Info("could not check the provided APIs")
+
+			return err
+		}
+		input[0].Status.MissingAPIs = missing
+	}
+
+	if r.Workloads != nil && instance.Spec.Workloads != nil {
+		workloads, err := r.Workloads.Workloads(ctx, workloadNamespace(instance), instance.Spec.Workloads)
+		if err != nil {
+			reqLogger.Info("could not check the workloads")
+
+			return err
+		}
+		input[0].Status.Workloads = workloads
+	}
+
+	conditions := instance.Status.Conditions
+	if r.Kubernetes != nil {
+		conditions, err = r.kubernetesConditions(instance, conditions)
+		if err != nil {
+			reqLogger.Info("could not check the kubernetes version")
+
+			return err
+		}
+	}
+	conditions, err = r.upgradeConditions(instance, conditions)
+	if err != nil {
+		reqLogger.Info("could not check the upgrade target")
+
+		return err
+	}
+	input[0].Status.Conditions = conditions
+
+	result := resolver.Resolve(input, groups)
+
+	for _, feature := range features {
+		status := result.FeatureStatus(feature)
+		if equality.Semantic.DeepEqual(feature.Status, status) {
+			continue
+		}
+
+		reqLogger.Info("updating the status", "feature", feature.String(), "phase", status.Phase)
+
+		patch := r.Client.GetInstalledFeaturePatchBase(feature)
+		feature.Status = status
+		err = r.Client.PatchInstalledFeatureStatus(ctx, feature, patch)
+		if err != nil && (feature == instance || !errors.IsNotFound(err)) {
+			reqLogger.Info("could not update the status", "feature", feature.String())
+
+			return err
+		}
+	}
+
+	for i := range groups {
+		group := &groups[i]
+
+		status := result.GroupStatus(group)
+		if equality.Semantic.DeepEqual(group.Status, status) {
+			continue
+		}
+
+		reqLogger.Info("updating the status", "group", group.Name, "features", status.Features)
+
+		patch := r.Client.GetInstalledFeatureGroupPatchBase(group)
+		group.Status = status
+		err = r.Client.PatchInstalledFeatureGroupStatus(ctx, group, patch)
+		if err != nil && !errors.IsNotFound(err) {
+			reqLogger.Info("could not update the status", "group", group.Name)
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// kubernetesConditions returns the conditions with the compatibility of the instance with the Kubernetes version of the
+// cluster. Features without Kubernetes bounds don't get the condition.
+func (r *Reconciler) kubernetesConditions(instance *featuresv1alpha1.InstalledFeature, conditions []featuresv1alpha1.FeatureCondition) ([]featuresv1alpha1.FeatureCondition, error) {
+	if !compatibility.HasKubernetesBounds(instance) {
+		return featuresv1alpha1.RemoveCondition(conditions, featuresv1alpha1.ConditionKubernetesCompatible), nil
+	}
+
+	info, err := r.Kubernetes.ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	reason, err := compatibility.Kubernetes(instance, info.GitVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	condition := featuresv1alpha1.FeatureCondition{
+		Type:               featuresv1alpha1.ConditionKubernetesCompatible,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Compatible",
+		Message:            fmt.Sprintf("Kubernetes %s is supported", info.GitVersion),
+		LastTransitionTime: metav1.Now(),
+	}
+	if reason != "" {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Incompatible"
+		condition.Message = reason
+	}
+
+	return featuresv1alpha1.SetCondition(conditions, condition), nil
+}
+
+// upgradeConditions returns the conditions with the readiness of the instance for the upgrade to the target Kubernetes
+// version. Without target version, the condition is removed.
+func (r *Reconciler) upgradeConditions(instance *featuresv1alpha1.InstalledFeature, conditions []featuresv1alpha1.FeatureCondition) ([]featuresv1alpha1.FeatureCondition, error) {
+	if r.UpgradeTarget == "" {
+		return featuresv1alpha1.RemoveCondition(conditions, featuresv1alpha1.ConditionUpgradeReady), nil
+	}
+
+	reasons, err := compatibility.Upgrade(instance, r.UpgradeTarget, r.Removals)
+	if err != nil {
+		return nil, err
+	}
+
+	condition := featuresv1alpha1.FeatureCondition{
+		Type:               featuresv1alpha1.ConditionUpgradeReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Ready",
+		Message:            fmt.Sprintf("works with Kubernetes %s", r.UpgradeTarget),
+		LastTransitionTime: metav1.Now(),
+	}
+	if len(reasons) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Blocked"
+		condition.Message = strings.Join(reasons, "; ")
+	}
+
+	return featuresv1alpha1.SetCondition(conditions, condition), nil
+}
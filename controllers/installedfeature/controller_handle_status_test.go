@@ -0,0 +1,814 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package installedfeature_test
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/compatibility"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/resolver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sversion "k8s.io/apimachinery/pkg/version"
+	"time"
+	// +kubebuilder:scaffold:imports
+)
+
+const thirdName = "third-feature"
+
+var _ = Describe("InstalledFeature status handling", func() {
+	Context("Handling dependencies", func() {
+		It("Should add dependency status when there is a dependency defined that has already other depending features", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			ift.Spec.DependsOn = []InstalledFeatureRef{ref(otherName)}
+			other := provisioned(createIFT(otherName, namespace, version, provider, description, uri, true, false))
+			other.Status.DependingFeatures = []InstalledFeatureRef{ref(thirdName)}
+			third := provisioned(createIFT(thirdName, namespace, version, provider, description, uri, true, false))
+			third.Spec.DependsOn = []InstalledFeatureRef{ref(otherName)}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift, other, third}, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+			otherStatus := expectFeatureStatusPatch(otherName, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*iftStatus).Should(Equal(InstalledFeatureStatus{Phase: PhaseProvisioned}))
+			Expect(otherStatus.DependingFeatures).Should(Equal([]InstalledFeatureRef{ref(name), ref(thirdName)}))
+		})
+
+		It("Should add dependency status when there is a dependency defined that has no other dependencies", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			ift.Spec.DependsOn = []InstalledFeatureRef{ref(otherName)}
+			other := provisioned(createIFT(otherName, namespace, version, provider, description, uri, true, false))
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift, other}, nil)
+			expectFeatureStatusPatch(name, nil)
+			otherStatus := expectFeatureStatusPatch(otherName, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*otherStatus).Should(Equal(InstalledFeatureStatus{
+				Phase:             PhaseProvisioned,
+				DependingFeatures: []InstalledFeatureRef{ref(name)},
+			}))
+		})
+
+		It("Should not patch the dependency when it already lists the instance as depending feature", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			ift.Spec.DependsOn = []InstalledFeatureRef{ref(otherName)}
+			other := provisioned(createIFT(otherName, namespace, version, provider, description, uri, true, false))
+			other.Status.DependingFeatures = []InstalledFeatureRef{ref(name)}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift, other}, nil)
+			expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Should remove dependency status when the instance is deleted and already listed in the status of dependency", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, true))
+			ift.Spec.DependsOn = []InstalledFeatureRef{ref(otherName)}
+			other := provisioned(createIFT(otherName, namespace, version, provider, description, uri, true, false))
+			other.Status.DependingFeatures = []InstalledFeatureRef{ref(name)}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift, other}, nil)
+			otherStatus := expectFeatureStatusPatch(otherName, nil)
+
+			expected := copyIFT(ift)
+			expected.Finalizers = make([]string, 0)
+			expected.Status.Phase = PhaseProvisioned
+			client.EXPECT().SaveInstalledFeature(gomock.Any(), expected).Return(nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(otherStatus.DependingFeatures).Should(BeEmpty())
+		})
+
+		It("Should mark missing dependency when dependency is marked as deleted", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			ift.Spec.DependsOn = []InstalledFeatureRef{ref(otherName)}
+			other := provisioned(createIFT(otherName, namespace, version, provider, description, uri, true, true))
+			other.Status.DependingFeatures = []InstalledFeatureRef{ref(name)}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift, other}, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(errorResult))
+			Expect(err).To(HaveOccurred())
+			Expect(*iftStatus).Should(Equal(InstalledFeatureStatus{
+				Phase:               PhasePending,
+				Message:             resolver.MessageMissingDependencies,
+				MissingDependencies: []InstalledFeatureRef{ref(otherName)},
+			}))
+		})
+
+		It("Should mark missing dependency when dependency is not installed", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			ift.Spec.DependsOn = []InstalledFeatureRef{ref(otherName)}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(errorResult))
+			Expect(err).To(HaveOccurred())
+			Expect(iftStatus.Phase).Should(Equal(PhasePending))
+			Expect(iftStatus.MissingDependencies).Should(Equal([]InstalledFeatureRef{ref(otherName)}))
+		})
+
+		It("Should mark missing dependency when the dependency does not match the required version", func() {
+			dependency := ref(otherName)
+			dependency.Version = ">=2.0.0"
+
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			ift.Spec.DependsOn = []InstalledFeatureRef{dependency}
+			other := provisioned(createIFT(otherName, namespace, version, provider, description, uri, true, false))
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift, other}, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+			otherStatus := expectFeatureStatusPatch(otherName, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(errorResult))
+			Expect(err).To(HaveOccurred())
+			Expect(iftStatus.MissingDependencies).Should(Equal([]InstalledFeatureRef{dependency}))
+			Expect(otherStatus.DependingFeatures).Should(Equal([]InstalledFeatureRef{ref(name)}))
+		})
+	})
+
+	Context("Handling dependent features", func() {
+		It("should remove the dependent feature from the missing dependencies list of the depending feature", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			ift.Spec.DependsOn = []InstalledFeatureRef{ref(otherName)}
+			ift.Status = InstalledFeatureStatus{
+				Phase:               PhasePending,
+				Message:             resolver.MessageMissingDependencies,
+				MissingDependencies: []InstalledFeatureRef{ref(otherName)},
+			}
+			other := createIFT(otherName, namespace, version, provider, description, uri, true, false)
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), otherLookupKey).Return(other, nil)
+			expectList([]*InstalledFeature{ift, other}, nil)
+			otherStatus := expectFeatureStatusPatch(otherName, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(otherReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*iftStatus).Should(Equal(InstalledFeatureStatus{Phase: PhaseProvisioned}))
+			Expect(otherStatus.DependingFeatures).Should(Equal([]InstalledFeatureRef{ref(name)}))
+		})
+
+		It("should mark the dependent feature as missing dependency when the version does not match the required one", func() {
+			dependency := ref(otherName)
+			dependency.Version = "<1.0.0-alpha1"
+
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			ift.Spec.DependsOn = []InstalledFeatureRef{dependency}
+			other := createIFT(otherName, namespace, version, provider, description, uri, true, false)
+			other.Status = InstalledFeatureStatus{
+				Phase:             PhaseProvisioned,
+				DependingFeatures: []InstalledFeatureRef{ref(name)},
+			}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), otherLookupKey).Return(other, nil)
+			expectList([]*InstalledFeature{ift, other}, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(otherReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(iftStatus.Phase).Should(Equal(PhasePending))
+			Expect(iftStatus.MissingDependencies).Should(Equal([]InstalledFeatureRef{dependency}))
+		})
+
+		It("should do nothing to the missing dependency list of the depending feature when the depending feature is deleted", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, true))
+			ift.Spec.DependsOn = []InstalledFeatureRef{ref(otherName)}
+			other := provisioned(createIFT(otherName, namespace, version, provider, description, uri, true, false))
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), otherLookupKey).Return(other, nil)
+			expectList([]*InstalledFeature{ift, other}, nil)
+
+			result, err := sut.Reconcile(otherReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should add the dependent feature to the missing dependency list when the depending feature is not deleted", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			ift.Spec.DependsOn = []InstalledFeatureRef{ref(otherName)}
+			other := createIFT(otherName, namespace, version, provider, description, uri, true, true)
+			other.Status = InstalledFeatureStatus{
+				Phase:             PhaseProvisioned,
+				DependingFeatures: []InstalledFeatureRef{ref(name)},
+			}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), otherLookupKey).Return(other, nil)
+			expectList([]*InstalledFeature{ift, other}, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+
+			expected := copyIFT(other)
+			expected.Finalizers = make([]string, 0)
+			expected.Status.Phase = PhaseProvisioned
+			client.EXPECT().SaveInstalledFeature(gomock.Any(), expected).Return(nil)
+
+			result, err := sut.Reconcile(otherReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(iftStatus.Phase).Should(Equal(PhasePending))
+			Expect(iftStatus.MissingDependencies).Should(Equal([]InstalledFeatureRef{ref(otherName)}))
+		})
+
+		It("should not requeue when the depending feature is 'not found' while patching it", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			ift.Spec.DependsOn = []InstalledFeatureRef{ref(otherName)}
+			other := provisioned(createIFT(otherName, namespace, version, provider, description, uri, true, true))
+			other.Status.DependingFeatures = []InstalledFeatureRef{ref(name)}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), otherLookupKey).Return(other, nil)
+			expectList([]*InstalledFeature{ift, other}, nil)
+			expectFeatureStatusPatch(name, createNotFound("installedfeatures", name))
+
+			expected := copyIFT(other)
+			expected.Finalizers = make([]string, 0)
+			expected.Status.Phase = PhaseProvisioned
+			client.EXPECT().SaveInstalledFeature(gomock.Any(), expected).Return(nil)
+
+			result, err := sut.Reconcile(otherReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Should requeue the request when patching the depending feature fails", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			ift.Spec.DependsOn = []InstalledFeatureRef{ref(otherName)}
+			other := provisioned(createIFT(otherName, namespace, version, provider, description, uri, true, true))
+			other.Status.DependingFeatures = []InstalledFeatureRef{ref(name)}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), otherLookupKey).Return(other, nil)
+			expectList([]*InstalledFeature{ift, other}, nil)
+			expectFeatureStatusPatch(name, errors.New("patching failed"))
+
+			result, err := sut.Reconcile(otherReconcileRequest)
+
+			Expect(result).Should(Equal(errorResult))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Handling conflicts", func() {
+		It("should fail the feature when a conflicting feature is installed", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			ift.Spec.Conflicts = []InstalledFeatureRef{ref(otherName)}
+			other := provisioned(createIFT(otherName, namespace, version, provider, description, uri, true, false))
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift, other}, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*iftStatus).Should(Equal(InstalledFeatureStatus{
+				Phase:               PhaseFailed,
+				Message:             resolver.MessageConflictingFeatures,
+				ConflictingFeatures: []InstalledFeatureRef{ref(otherName)},
+			}))
+		})
+
+		It("should provision the feature when the conflicting feature is deleted", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			ift.Spec.Conflicts = []InstalledFeatureRef{ref(otherName)}
+			other := provisioned(createIFT(otherName, namespace, version, provider, description, uri, true, true))
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift, other}, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*iftStatus).Should(Equal(InstalledFeatureStatus{Phase: PhaseProvisioned}))
+		})
+	})
+
+	Context("Handle Library Groups", func() {
+		It("should add the status entry on the IFTG when the IFTG has no features yet", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			setGroupToIFT(ift, group, namespace)
+			iftg := createIFTG(group, namespace, provider, description, uri, true, false)
+			iftg.Status.Phase = PhaseProvisioned
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, []*InstalledFeatureGroup{iftg})
+			groupStatus := expectGroupStatusPatch(group, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*groupStatus).Should(Equal(InstalledFeatureGroupStatus{
+				Phase:    PhaseProvisioned,
+				Features: []InstalledFeatureGroupListedFeature{member(name)},
+			}))
+		})
+
+		It("should add the status entry on the IFTG when the IFTG has already features", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			setGroupToIFT(ift, group, namespace)
+			other := provisioned(createIFT(otherName, namespace, version, provider, description, uri, true, false))
+			setGroupToIFT(other, group, namespace)
+			iftg := createIFTG(group, namespace, provider, description, uri, true, false)
+			iftg.Status.Phase = PhaseProvisioned
+			iftg.Status.Features = []InstalledFeatureGroupListedFeature{member(otherName)}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift, other}, []*InstalledFeatureGroup{iftg})
+			groupStatus := expectGroupStatusPatch(group, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(groupStatus.Features).Should(Equal([]InstalledFeatureGroupListedFeature{member(name), member(otherName)}))
+		})
+
+		It("should not add the feature to the status entry on the IFTG when the IFTG already lists this feature", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			setGroupToIFT(ift, group, namespace)
+			iftg := createIFTG(group, namespace, provider, description, uri, true, false)
+			iftg.Status.Phase = PhaseProvisioned
+			iftg.Status.Features = []InstalledFeatureGroupListedFeature{member(name)}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, []*InstalledFeatureGroup{iftg})
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should remove the status entry on the IFTG when IFT is deleted and is listed in IFTG", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, true))
+			setGroupToIFT(ift, group, namespace)
+			iftg := createIFTG(group, namespace, provider, description, uri, true, false)
+			iftg.Status.Phase = PhaseProvisioned
+			iftg.Status.Features = []InstalledFeatureGroupListedFeature{member(name)}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, []*InstalledFeatureGroup{iftg})
+			groupStatus := expectGroupStatusPatch(group, nil)
+
+			expected := copyIFT(ift)
+			expected.Finalizers = make([]string, 0)
+			expected.Status.Phase = PhaseProvisioned
+			client.EXPECT().SaveInstalledFeature(gomock.Any(), expected).Return(nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(groupStatus.Features).Should(BeEmpty())
+		})
+
+		It("should ignore the IFTG when it is 'not found' while patching it", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			setGroupToIFT(ift, group, namespace)
+			iftg := createIFTG(group, namespace, provider, description, uri, true, false)
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, []*InstalledFeatureGroup{iftg})
+			expectGroupStatusPatch(group, createNotFound("installedfeaturegroups", group))
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should requeue the request when the IFTG status can't be patched", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			setGroupToIFT(ift, group, namespace)
+			iftg := createIFTG(group, namespace, provider, description, uri, true, false)
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, []*InstalledFeatureGroup{iftg})
+			expectGroupStatusPatch(group, errors.New("patching failed"))
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(errorResult))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Handling concurrent changes", func() {
+		It("should reload the instance and resolve again when patching a dependency conflicts", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			ift.Spec.DependsOn = []InstalledFeatureRef{ref(otherName)}
+			other := provisioned(createIFT(otherName, namespace, version, provider, description, uri, true, false))
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift, other}, nil)
+			expectFeatureStatusPatch(name, nil)
+			expectFeatureStatusPatch(otherName, createConflict("installedfeatures", otherName))
+
+			By("another feature depending on the dependency has been added in the meantime")
+			reloaded := provisioned(copyIFT(ift))
+			reloaded.ResourceVersion = "2"
+			third := provisioned(createIFT(thirdName, namespace, version, provider, description, uri, true, false))
+			third.Spec.DependsOn = []InstalledFeatureRef{ref(otherName)}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(reloaded, nil)
+			expectList([]*InstalledFeature{reloaded, other, third}, nil)
+			otherStatus := expectFeatureStatusPatch(otherName, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(otherStatus.DependingFeatures).Should(Equal([]InstalledFeatureRef{ref(name), ref(thirdName)}))
+		})
+
+		It("should requeue the request when the instance vanished before retrying", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+			expectFeatureStatusPatch(name, createConflict("installedfeatures", name))
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(nil, createNotFound("installedfeatures", name))
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(errorResult))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Checking provided APIs", func() {
+		certificates := ProvidedAPI{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+		It("should fail the feature when a provided API is missing", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			ift.Spec.ProvidedAPIs = []ProvidedAPI{certificates}
+			missing := []MissingAPI{{ProvidedAPI: certificates, Reason: "CRD certificates.cert-manager.io is not established"}}
+			sut.APIs = apiCheckerStub{certificates: missing[0].Reason}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*iftStatus).Should(Equal(InstalledFeatureStatus{
+				Phase:       PhaseFailed,
+				Message:     resolver.MessageMissingAPIs,
+				MissingAPIs: missing,
+			}))
+		})
+
+		It("should provision the feature again when the provided API is served", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			ift.Spec.ProvidedAPIs = []ProvidedAPI{certificates}
+			ift.Status = InstalledFeatureStatus{
+				Phase:       PhaseFailed,
+				Message:     resolver.MessageMissingAPIs,
+				MissingAPIs: []MissingAPI{{ProvidedAPI: certificates, Reason: "not served"}},
+			}
+			sut.APIs = apiCheckerStub{}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*iftStatus).Should(Equal(InstalledFeatureStatus{Phase: PhaseProvisioned}))
+		})
+
+		It("should keep the missing APIs of other features", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			ift.Spec.DependsOn = []InstalledFeatureRef{ref(otherName)}
+			other := createIFT(otherName, namespace, version, provider, description, uri, true, false)
+			other.Spec.ProvidedAPIs = []ProvidedAPI{certificates}
+			other.Status = InstalledFeatureStatus{
+				Phase:       PhaseFailed,
+				Message:     resolver.MessageMissingAPIs,
+				MissingAPIs: []MissingAPI{{ProvidedAPI: certificates, Reason: "not served"}},
+			}
+			sut.APIs = apiCheckerStub{}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift, other}, nil)
+			otherStatus := expectFeatureStatusPatch(otherName, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(otherStatus.Phase).Should(Equal(PhaseFailed))
+			Expect(otherStatus.MissingAPIs).Should(HaveLen(1))
+			Expect(otherStatus.DependingFeatures).Should(Equal([]InstalledFeatureRef{ref(name)}))
+		})
+
+		It("should requeue the request when the APIs can not be checked", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			ift.Spec.ProvidedAPIs = []ProvidedAPI{certificates}
+			sut.APIs = apiCheckerStub{ProvidedAPI{}: "discovery failed"}
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(errorResult))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Checking workloads", func() {
+		selector := &WorkloadSelector{Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": name}}}
+
+		It("should degrade the feature when its replicas are not ready", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			ift.Spec.Workloads = selector
+			sut.Workloads = workloadCheckerStub(func(string) (*WorkloadStatus, error) {
+				return &WorkloadStatus{Workloads: 1, ReadyReplicas: 1, DesiredReplicas: 2}, nil
+			})
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*iftStatus).Should(Equal(InstalledFeatureStatus{
+				Phase:     PhaseDegraded,
+				Message:   "1 of 2 replicas ready",
+				Workloads: &WorkloadStatus{Workloads: 1, ReadyReplicas: 1, DesiredReplicas: 2},
+			}))
+		})
+
+		It("should check the workloads in the namespace of the selector", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			ift.Spec.Workloads = &WorkloadSelector{Namespace: "cert-manager", Selector: selector.Selector}
+			var checked string
+			sut.Workloads = workloadCheckerStub(func(namespace string) (*WorkloadStatus, error) {
+				checked = namespace
+				return &WorkloadStatus{Workloads: 1, ReadyReplicas: 1, DesiredReplicas: 1}, nil
+			})
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(checked).Should(Equal("cert-manager"))
+			Expect(iftStatus.Phase).Should(Equal(PhaseProvisioned))
+		})
+
+		It("should requeue the request when the workloads can not be checked", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			ift.Spec.Workloads = selector
+			sut.Workloads = workloadCheckerStub(func(string) (*WorkloadStatus, error) {
+				return nil, errors.New("list failed")
+			})
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(errorResult))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Checking the Kubernetes version", func() {
+		kubernetes := func(gitVersion string) serverVersionStub {
+			return func() (*k8sversion.Info, error) {
+				return &k8sversion.Info{GitVersion: gitVersion}, nil
+			}
+		}
+
+		It("should fail the feature when the Kubernetes version is not supported", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			ift.Spec.MaxKubernetesVersion = "1.21"
+			sut.Kubernetes = kubernetes("v1.22.1")
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(iftStatus.Phase).Should(Equal(PhaseFailed))
+			Expect(iftStatus.Message).Should(Equal(resolver.MessageIncompatibleKubernetes))
+			Expect(iftStatus.Conditions).Should(HaveLen(1))
+			Expect(iftStatus.Conditions[0].Status).Should(Equal(metav1.ConditionFalse))
+			Expect(iftStatus.Conditions[0].Message).Should(Equal("supports Kubernetes up to 1.21, got v1.22.1"))
+		})
+
+		It("should keep the transition time while the feature stays compatible", func() {
+			transition := metav1.NewTime(time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC))
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			ift.Spec.MinKubernetesVersion = "1.19"
+			ift.Status.Conditions = []FeatureCondition{{
+				Type:               ConditionKubernetesCompatible,
+				Status:             metav1.ConditionTrue,
+				Reason:             "Compatible",
+				Message:            "Kubernetes v1.20.4 is supported",
+				LastTransitionTime: transition,
+			}}
+			sut.Kubernetes = kubernetes("v1.20.4")
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should remove the condition when the feature has no bounds", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			ift.Status.Conditions = []FeatureCondition{{Type: ConditionKubernetesCompatible, Status: metav1.ConditionFalse}}
+			sut.Kubernetes = kubernetes("v1.20.4")
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*iftStatus).Should(Equal(InstalledFeatureStatus{Phase: PhaseProvisioned}))
+		})
+
+		It("should requeue the request when the Kubernetes version can not be read", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			ift.Spec.MinKubernetesVersion = "1.19"
+			sut.Kubernetes = serverVersionStub(func() (*k8sversion.Info, error) {
+				return nil, errors.New("connection refused")
+			})
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(errorResult))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Checking the upgrade target", func() {
+		ingress := ProvidedAPI{Group: "networking.k8s.io", Version: "v1beta1", Kind: "Ingress"}
+
+		It("should block the upgrade when a consumed API is removed", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			ift.Spec.ConsumedAPIs = []ProvidedAPI{ingress}
+			sut.UpgradeTarget = "1.22"
+			sut.Removals = compatibility.DefaultRemovals()
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(iftStatus.Phase).Should(Equal(PhaseProvisioned))
+			Expect(iftStatus.Conditions).Should(HaveLen(1))
+			Expect(iftStatus.Conditions[0].Type).Should(Equal(ConditionUpgradeReady))
+			Expect(iftStatus.Conditions[0].Status).Should(Equal(metav1.ConditionFalse))
+			Expect(iftStatus.Conditions[0].Message).Should(Equal(
+				"networking.k8s.io/v1beta1 Ingress is removed in Kubernetes 1.22, use networking.k8s.io/v1 Ingress"))
+		})
+
+		It("should mark the feature ready for the upgrade", func() {
+			ift := provisioned(createIFT(name, namespace, version, provider, description, uri, true, false))
+			ift.Spec.ConsumedAPIs = []ProvidedAPI{ingress}
+			sut.UpgradeTarget = "1.21"
+			sut.Removals = compatibility.DefaultRemovals()
+
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+			iftStatus := expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(iftStatus.Conditions).Should(HaveLen(1))
+			Expect(iftStatus.Conditions[0].Status).Should(Equal(metav1.ConditionTrue))
+		})
+	})
+})
+
+func ref(name string) InstalledFeatureRef {
+	return InstalledFeatureRef{Namespace: namespace, Name: name}
+}
+
+func member(name string) InstalledFeatureGroupListedFeature {
+	return InstalledFeatureGroupListedFeature{Namespace: namespace, Name: name}
+}
+
+func provisioned(feature *InstalledFeature) *InstalledFeature {
+	feature.Status.Phase = PhaseProvisioned
+
+	return feature
+}
+
+// apiCheckerStub reports the APIs it contains as missing with the mapped reason. The empty API fails the check.
+type apiCheckerStub map[ProvidedAPI]string
+
+func (a apiCheckerStub) MissingAPIs(_ context.Context, apis []ProvidedAPI) ([]MissingAPI, error) {
+	if reason, ok := a[ProvidedAPI{}]; ok {
+		return nil, errors.New(reason)
+	}
+
+	var result []MissingAPI
+	for _, api := range apis {
+		if reason, ok := a[api]; ok {
+			result = append(result, MissingAPI{ProvidedAPI: api, Reason: reason})
+		}
+	}
+
+	return result, nil
+}
+
+// workloadCheckerStub returns the workload status for the checked namespace.
+type workloadCheckerStub func(namespace string) (*WorkloadStatus, error)
+
+func (w workloadCheckerStub) Workloads(_ context.Context, namespace string, _ *WorkloadSelector) (*WorkloadStatus, error) {
+	return w(namespace)
+}
+
+// serverVersionStub returns the version of the cluster.
+type serverVersionStub func() (*k8sversion.Info, error)
+
+func (s serverVersionStub) ServerVersion() (*k8sversion.Info, error) {
+	return s()
+}
@@ -18,6 +18,7 @@ package installedfeature
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/go-logr/logr"
 	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
@@ -34,26 +35,8 @@ func (r *Reconciler) handleUpdate(ctx context.Context, instance *featuresv1alpha
 		}
 	}
 
-	statusChanged := false
-	status := r.Client.GetInstalledFeaturePatchBase(instance)
-
-	if len(instance.Status.MissingDependencies) > 0 {
-		instance.Status.Phase = "pending"
-		instance.Status.Message = "dependencies are missing"
-		statusChanged = true
-	} else if instance.Status.Phase != "provisioned" {
-		instance.Status.Phase = "provisioned"
-		instance.Status.Message = ""
-		statusChanged = true
-	}
-
-	if statusChanged {
-		err := r.Client.PatchInstalledFeatureStatus(ctx, instance, status)
-		if err != nil {
-			reqLogger.Error(err, "could not set the status to the installedfeature")
-
-			return errorRequeue, err
-		}
+	if instance.DeletionTimestamp == nil && len(instance.Status.MissingDependencies) > 0 {
+		return errorRequeue, fmt.Errorf("missing dependencies: %v", instance.Status.MissingDependencies)
 	}
 
 	return ctrl.Result{}, nil
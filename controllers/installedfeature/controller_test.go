@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"github.com/golang/mock/gomock"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
 	. "github.com/klenkes74/k8s-installed-features-catalogue/controllers/installedfeature"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -27,7 +28,6 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
-	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	// +kubebuilder:scaffold:imports
 )
@@ -69,35 +69,36 @@ var _ = Describe("InstalledFeature controller basics", func() {
 
 			ift := createIFT(name, namespace, version, provider, description, uri, false, false)
 			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+			status := expectFeatureStatusPatch(name, nil)
 
 			expected := copyIFT(ift)
 			expected.Finalizers = make([]string, 1)
 			expected.Finalizers[0] = FinalizerName
+			expected.Status.Phase = PhaseProvisioned
 
 			client.EXPECT().SaveInstalledFeature(gomock.Any(), expected).Return(nil)
 
-			client.EXPECT().GetInstalledFeaturePatchBase(gomock.Any()).Return(k8sclient.MergeFrom(ift))
-			client.EXPECT().PatchInstalledFeatureStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
-
 			result, err := sut.Reconcile(iftReconcileRequest)
 			Expect(result).Should(Equal(successResult))
 			Expect(err).ToNot(HaveOccurred())
+			Expect(status.Phase).Should(Equal(PhaseProvisioned))
 		})
 
 		It("should remove the finalizer when the finalizer is set while being deleted", func() {
 			By("By creating a new InstalledFeature without finalizer")
 
 			ift := createIFT(name, namespace, version, provider, description, uri, true, true)
+			ift.Status.Phase = PhaseProvisioned
 			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
 
 			expected := copyIFT(ift)
 			expected.Finalizers = make([]string, 0)
+			expected.Status.Phase = PhaseProvisioned
 
 			client.EXPECT().SaveInstalledFeature(gomock.Any(), expected).Return(nil)
 
-			client.EXPECT().GetInstalledFeaturePatchBase(gomock.Any()).Return(k8sclient.MergeFrom(ift))
-			client.EXPECT().PatchInstalledFeatureStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
-
 			result, err := sut.Reconcile(iftReconcileRequest)
 			Expect(result).Should(Equal(successResult))
 			Expect(err).ToNot(HaveOccurred())
@@ -110,19 +111,51 @@ var _ = Describe("InstalledFeature controller basics", func() {
 
 			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
 			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+			status := expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*status).Should(Equal(InstalledFeatureStatus{Phase: PhaseProvisioned}))
+		})
+
+		It("should not patch the status when it is already up to date", func() {
+			By("By reconciling an already provisioned InstalledFeature")
 
-			client.EXPECT().GetInstalledFeaturePatchBase(gomock.Any()).Return(k8sclient.MergeFrom(ift))
-			client.EXPECT().PatchInstalledFeatureStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			ift.Status.Phase = PhaseProvisioned
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
 
 			result, err := sut.Reconcile(iftReconcileRequest)
 
 			Expect(result).Should(Equal(successResult))
 			Expect(err).ToNot(HaveOccurred())
 		})
+
+		It("should use the loaded feature instead of an outdated listed one", func() {
+			By("By listing an outdated version of the InstalledFeature")
+
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+
+			outdated := copyIFT(ift)
+			outdated.Spec.DependsOn = []InstalledFeatureRef{{Namespace: namespace, Name: otherName}}
+			expectList([]*InstalledFeature{outdated}, nil)
+			status := expectFeatureStatusPatch(name, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(successResult))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*status).Should(Equal(InstalledFeatureStatus{Phase: PhaseProvisioned}))
+		})
 	})
 
 	Context("Technical Error handling", func() {
-		It("should drop the request when the ift can't be loaded due to NotFoundError", func() {
+		It("should requeue the request when the ift can't be loaded due to another error but NotFoundError", func() {
 			By("By having a problem loading the ift")
 
 			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(nil, errors.New("some error"))
@@ -132,7 +165,7 @@ var _ = Describe("InstalledFeature controller basics", func() {
 			Expect(err).To(HaveOccurred())
 		})
 
-		It("should requeue request when the ift can't be loaded due to another error but NotFoundError", func() {
+		It("should drop the request when the ift can't be loaded due to NotFoundError", func() {
 			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(nil, k8serrors.NewNotFound(schema.GroupResource{
 				Group:    "features.kaiserpfalz-edv.de",
 				Resource: "installedfeatures",
@@ -143,38 +176,66 @@ var _ = Describe("InstalledFeature controller basics", func() {
 			Expect(err).To(HaveOccurred())
 		})
 
+		It("should requeue the request when the features can't be listed", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			client.EXPECT().ListInstalledFeatures(gomock.Any()).Return(nil, errors.New("some error"))
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+			Expect(result).Should(Equal(errorResult))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should requeue the request when the groups can't be listed", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			client.EXPECT().ListInstalledFeatures(gomock.Any()).Return([]InstalledFeature{*ift.DeepCopy()}, nil)
+			client.EXPECT().ListInstalledFeatureGroups(gomock.Any()).Return(nil, errors.New("some error"))
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+			Expect(result).Should(Equal(errorResult))
+			Expect(err).To(HaveOccurred())
+		})
+
 		It("should requeue request when writing the reconciled object fails", func() {
 			By("By getting a failure while saving the data back into the k8s cluster")
 
 			ift := createIFT(name, namespace, version, provider, description, uri, false, false)
+			ift.Status.Phase = PhaseProvisioned
 			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
 
 			expected := copyIFT(ift)
 			expected.Finalizers = make([]string, 1)
 			expected.Finalizers[0] = FinalizerName
+			expected.Status.Phase = PhaseProvisioned
 
 			client.EXPECT().SaveInstalledFeature(gomock.Any(), expected).Return(errors.New("some error"))
 
 			result, err := sut.Reconcile(iftReconcileRequest)
 			Expect(result).Should(Equal(errorResult))
 			Expect(err).To(HaveOccurred())
-
 		})
 
 		It("should requeue the request when updating the status fails", func() {
 			By("By getting an error when updating the status")
 
-			ift := createIFT(name, namespace, version, provider, description, uri, false, false)
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
 			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+			expectFeatureStatusPatch(name, errors.New("patching status failed"))
 
-			expected := copyIFT(ift)
-			expected.Finalizers = make([]string, 1)
-			expected.Finalizers[0] = FinalizerName
+			result, err := sut.Reconcile(iftReconcileRequest)
 
-			client.EXPECT().SaveInstalledFeature(gomock.Any(), expected).Return(nil)
+			Expect(result).Should(Equal(errorResult))
+			Expect(err).To(HaveOccurred())
+		})
 
-			client.EXPECT().GetInstalledFeaturePatchBase(gomock.Any()).Return(k8sclient.MergeFrom(expected))
-			client.EXPECT().PatchInstalledFeatureStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("patching status failed"))
+		It("should requeue the request when the status of the instance can't be patched due to NotFoundError", func() {
+			ift := createIFT(name, namespace, version, provider, description, uri, true, false)
+			client.EXPECT().LoadInstalledFeature(gomock.Any(), iftLookupKey).Return(ift, nil)
+			expectList([]*InstalledFeature{ift}, nil)
+			expectFeatureStatusPatch(name, createNotFound("installedfeatures", name))
 
 			result, err := sut.Reconcile(iftReconcileRequest)
 
@@ -17,6 +17,8 @@
 package installedfeature_test
 
 import (
+	"context"
+	"fmt"
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
@@ -29,6 +31,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
 	"testing"
 	"time"
@@ -236,6 +239,7 @@ func createFeatureRef(orig InstalledFeatureRef) InstalledFeatureRef {
 	return InstalledFeatureRef{
 		Namespace: orig.Namespace,
 		Name:      orig.Name,
+		Version:   orig.Version,
 	}
 }
 
@@ -283,7 +287,7 @@ func createIFTG(name string, namespace string, provider string, description stri
 	return result
 }
 
-func createNotFound(resourceType string, name string) errors.APIStatus {
+func createNotFound(resourceType string, name string) error {
 	return errors.NewNotFound(
 		schema.GroupResource{
 			Group:    GroupVersion.Group,
@@ -292,3 +296,79 @@ func createNotFound(resourceType string, name string) errors.APIStatus {
 		name,
 	)
 }
+
+func createConflict(resourceType string, name string) error {
+	return errors.NewConflict(
+		schema.GroupResource{
+			Group:    GroupVersion.Group,
+			Resource: resourceType,
+		},
+		name,
+		fmt.Errorf("the object has been modified"),
+	)
+}
+
+// expectList lets the mock return the given features and groups when listing them.
+func expectList(features []*InstalledFeature, groups []*InstalledFeatureGroup) {
+	listedFeatures := make([]InstalledFeature, len(features))
+	for i, feature := range features {
+		listedFeatures[i] = *feature.DeepCopy()
+	}
+
+	listedGroups := make([]InstalledFeatureGroup, len(groups))
+	for i, group := range groups {
+		listedGroups[i] = *group.DeepCopy()
+	}
+
+	client.EXPECT().ListInstalledFeatures(gomock.Any()).Return(listedFeatures, nil)
+	client.EXPECT().ListInstalledFeatureGroups(gomock.Any()).Return(listedGroups, nil)
+}
+
+// expectFeatureStatusPatch expects exactly one status patch of the named feature. The patched status is copied into
+// the returned status, so the test can check it after reconciling.
+func expectFeatureStatusPatch(name string, err error) *InstalledFeatureStatus {
+	result := &InstalledFeatureStatus{}
+
+	client.EXPECT().GetInstalledFeaturePatchBase(hasName(name)).DoAndReturn(func(instance *InstalledFeature) k8sclient.Patch {
+		return k8sclient.MergeFrom(instance.DeepCopy())
+	})
+	client.EXPECT().PatchInstalledFeatureStatus(gomock.Any(), hasName(name), gomock.Any()).DoAndReturn(
+		func(_ context.Context, instance *InstalledFeature, _ k8sclient.Patch) error {
+			instance.Status.DeepCopyInto(result)
+
+			return err
+		})
+
+	return result
+}
+
+// expectGroupStatusPatch expects exactly one status patch of the named group. The patched status is copied into
+// the returned status, so the test can check it after reconciling.
+func expectGroupStatusPatch(name string, err error) *InstalledFeatureGroupStatus {
+	result := &InstalledFeatureGroupStatus{}
+
+	client.EXPECT().GetInstalledFeatureGroupPatchBase(hasName(name)).DoAndReturn(func(instance *InstalledFeatureGroup) k8sclient.Patch {
+		return k8sclient.MergeFrom(instance.DeepCopy())
+	})
+	client.EXPECT().PatchInstalledFeatureGroupStatus(gomock.Any(), hasName(name), gomock.Any()).DoAndReturn(
+		func(_ context.Context, instance *InstalledFeatureGroup, _ k8sclient.Patch) error {
+			instance.Status.DeepCopyInto(result)
+
+			return err
+		})
+
+	return result
+}
+
+// hasName matches the kubernetes objects with the given name.
+type hasName string
+
+func (m hasName) Matches(x interface{}) bool {
+	object, ok := x.(metav1.Object)
+
+	return ok && object.GetName() == string(m)
+}
+
+func (m hasName) String() string {
+	return fmt.Sprintf("has name %q", string(m))
+}
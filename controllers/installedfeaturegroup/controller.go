@@ -19,7 +19,12 @@ package installedfeaturegroup
 import (
 	"context"
 	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/resolver"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"github.com/go-logr/logr"
@@ -38,15 +43,20 @@ type Reconciler struct {
 
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+
+	// MaxConcurrentReconciles is the number of groups reconciled in parallel. Defaults to 1.
+	MaxConcurrentReconciles int
 }
 
 // +kubebuilder:rbac:groups=features.kaiserpfalz-edv.de,resources=installedfeaturegroups,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=features.kaiserpfalz-edv.de,resources=installedfeaturegroups/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=features.kaiserpfalz-edv.de,resources=installedfeatures,verbs=get;list;watch
 // +kubebuilder:rbac:groups=features.kaiserpfalz-edv.de,resources=installedfeatures/status,verbs=get;update;patch
 
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&featuresv1alpha1.InstalledFeatureGroup{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Complete(r)
 }
 
@@ -100,20 +110,46 @@ func (r *Reconciler) handleUpdate(ctx context.Context, instance *featuresv1alpha
 		}
 	}
 
-	statusChanged := false
-	status := r.Client.GetInstalledFeatureGroupPatchBase(instance)
-	if instance.Status.Phase == "" {
-		instance.Status.Phase = "provisioned"
-		instance.Status.Message = ""
-		statusChanged = true
+	err := r.handleStatus(ctx, instance, reqLogger)
+	if err != nil {
+		return ctrl.Result{RequeueAfter: 60}, err
 	}
 
-	if statusChanged {
-		err := r.Client.PatchInstalledFeatureGroupStatus(ctx, instance, status)
+	return ctrl.Result{}, nil
+}
+
+// handleStatus resolves the members of the group and patches the status if it differs. The patch is only applied to
+// the loaded version of the group, on conflicts the group is reloaded and resolved again.
+func (r *Reconciler) handleStatus(ctx context.Context, instance *featuresv1alpha1.InstalledFeatureGroup, reqLogger logr.Logger) error {
+	attempt := 0
+
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		attempt++
+		if attempt > 1 {
+			reqLogger.Info("retrying after conflict", "attempt", attempt)
+
+			reloaded, err := r.Client.LoadInstalledFeatureGroup(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name})
+			if err != nil {
+				return err
+			}
+			reloaded.DeepCopyInto(instance)
+		}
+
+		features, err := r.Client.ListInstalledFeatures(ctx)
 		if err != nil {
-			return ctrl.Result{RequeueAfter: 60}, err
+			reqLogger.Info("could not list the installedfeatures")
+
+			return err
 		}
-	}
 
-	return ctrl.Result{}, nil
+		status := resolver.Resolve(features, []featuresv1alpha1.InstalledFeatureGroup{*instance}).GroupStatus(instance)
+		if equality.Semantic.DeepEqual(instance.Status, status) {
+			return nil
+		}
+
+		patch := r.Client.GetInstalledFeatureGroupPatchBase(instance)
+		instance.Status = status
+
+		return r.Client.PatchInstalledFeatureGroupStatus(ctx, instance, patch)
+	})
 }
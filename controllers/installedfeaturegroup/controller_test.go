@@ -17,6 +17,7 @@
 package installedfeaturegroup_test
 
 import (
+	"context"
 	"errors"
 	"github.com/golang/mock/gomock"
 	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
@@ -55,12 +56,56 @@ var _ = Describe("InstalledFeature controller", func() {
 
 			ift := createIFTG(name, namespace, provider, description, uri, true, false)
 			client.EXPECT().LoadInstalledFeatureGroup(gomock.Any(), iftLookupKey).Return(ift, nil)
+			client.EXPECT().ListInstalledFeatures(gomock.Any()).Return(nil, nil)
 
 			client.EXPECT().GetInstalledFeatureGroupPatchBase(gomock.Any()).Return(k8sclient.MergeFrom(ift))
 			client.EXPECT().PatchInstalledFeatureGroupStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
 
 			result, err := sut.Reconcile(iftReconcileRequest)
 
+			Expect(result).Should(Equal(reconcile.Result{Requeue: false}))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ift.Status).Should(Equal(InstalledFeatureGroupStatus{Phase: PhaseProvisioned}))
+		})
+
+		It("should list the features belonging to the group", func() {
+			By("By creating a new InstalledFeatureGroup with existing features")
+
+			ift := createIFTG(name, namespace, provider, description, uri, true, false)
+			client.EXPECT().LoadInstalledFeatureGroup(gomock.Any(), iftLookupKey).Return(ift, nil)
+			client.EXPECT().ListInstalledFeatures(gomock.Any()).Return([]InstalledFeature{
+				createMember("other-feature", namespace, name, false),
+				createMember("basic-feature", namespace, name, false),
+				createMember("deleted-feature", namespace, name, true),
+				createMember("foreign-feature", namespace, "other-group", false),
+			}, nil)
+
+			client.EXPECT().GetInstalledFeatureGroupPatchBase(gomock.Any()).Return(k8sclient.MergeFrom(ift))
+			client.EXPECT().PatchInstalledFeatureGroupStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(reconcile.Result{Requeue: false}))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ift.Status.Features).Should(Equal([]InstalledFeatureGroupListedFeature{
+				{Namespace: namespace, Name: "basic-feature"},
+				{Namespace: namespace, Name: "other-feature"},
+			}))
+		})
+
+		It("should not patch the status when it is already up to date", func() {
+			By("By reconciling an already provisioned InstalledFeatureGroup")
+
+			ift := createIFTG(name, namespace, provider, description, uri, true, false)
+			ift.Status.Phase = PhaseProvisioned
+			ift.Status.Features = []InstalledFeatureGroupListedFeature{{Namespace: namespace, Name: "basic-feature"}}
+			client.EXPECT().LoadInstalledFeatureGroup(gomock.Any(), iftLookupKey).Return(ift, nil)
+			client.EXPECT().ListInstalledFeatures(gomock.Any()).Return([]InstalledFeature{
+				createMember("basic-feature", namespace, name, false),
+			}, nil)
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
 			Expect(result).Should(Equal(reconcile.Result{Requeue: false}))
 			Expect(err).ShouldNot(HaveOccurred())
 		})
@@ -76,6 +121,7 @@ var _ = Describe("InstalledFeature controller", func() {
 			expected.Finalizers[0] = FinalizerName
 
 			client.EXPECT().SaveInstalledFeatureGroup(gomock.Any(), expected).Return(nil)
+			client.EXPECT().ListInstalledFeatures(gomock.Any()).Return(nil, nil)
 
 			client.EXPECT().GetInstalledFeatureGroupPatchBase(gomock.Any()).Return(k8sclient.MergeFrom(ift))
 			client.EXPECT().PatchInstalledFeatureGroupStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
@@ -95,6 +141,7 @@ var _ = Describe("InstalledFeature controller", func() {
 			expected.Finalizers = make([]string, 0)
 
 			client.EXPECT().SaveInstalledFeatureGroup(gomock.Any(), expected).Return(nil)
+			client.EXPECT().ListInstalledFeatures(gomock.Any()).Return(nil, nil)
 
 			client.EXPECT().GetInstalledFeatureGroupPatchBase(gomock.Any()).Return(k8sclient.MergeFrom(ift))
 			client.EXPECT().PatchInstalledFeatureGroupStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
@@ -173,11 +220,24 @@ var _ = Describe("InstalledFeature controller", func() {
 
 		})
 
+		It("should requeue request when the features can't be listed", func() {
+			By("By getting a failure while listing the features")
+
+			ift := createIFTG(name, namespace, provider, description, uri, true, false)
+			client.EXPECT().LoadInstalledFeatureGroup(gomock.Any(), iftLookupKey).Return(ift, nil)
+			client.EXPECT().ListInstalledFeatures(gomock.Any()).Return(nil, errors.New("some error"))
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+			Expect(result).Should(Equal(reconcile.Result{RequeueAfter: 60}))
+			Expect(err).To(HaveOccurred())
+		})
+
 		It("should requeue the request when updating the status fails", func() {
 			By("By getting an error when updating the status")
 
 			ift := createIFTG(name, namespace, provider, description, uri, true, false)
 			client.EXPECT().LoadInstalledFeatureGroup(gomock.Any(), iftLookupKey).Return(ift, nil)
+			client.EXPECT().ListInstalledFeatures(gomock.Any()).Return(nil, nil)
 
 			client.EXPECT().GetInstalledFeatureGroupPatchBase(gomock.Any()).Return(k8sclient.MergeFrom(ift))
 			client.EXPECT().
@@ -189,6 +249,41 @@ var _ = Describe("InstalledFeature controller", func() {
 			Expect(result).Should(Equal(reconcile.Result{RequeueAfter: 60}))
 			Expect(err).To(HaveOccurred())
 		})
+
+		It("should reload the group and resolve again when patching the status conflicts", func() {
+			By("By getting a conflict when updating the status")
+
+			ift := createIFTG(name, namespace, provider, description, uri, true, false)
+			client.EXPECT().LoadInstalledFeatureGroup(gomock.Any(), iftLookupKey).Return(ift, nil)
+			client.EXPECT().ListInstalledFeatures(gomock.Any()).Return(nil, nil)
+			client.EXPECT().GetInstalledFeatureGroupPatchBase(gomock.Any()).Return(k8sclient.MergeFrom(ift))
+			client.EXPECT().
+				PatchInstalledFeatureGroupStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(errors2.NewConflict(schema.GroupResource{Group: GroupVersion.Group, Resource: "installedfeaturegroups"}, name, errors.New("changed")))
+
+			reloaded := createIFTG(name, namespace, provider, description, uri, true, false)
+			reloaded.ResourceVersion = "2"
+			client.EXPECT().LoadInstalledFeatureGroup(gomock.Any(), iftLookupKey).Return(reloaded, nil)
+			client.EXPECT().ListInstalledFeatures(gomock.Any()).Return([]InstalledFeature{
+				createMember("basic-feature", namespace, name, false),
+			}, nil)
+			client.EXPECT().GetInstalledFeatureGroupPatchBase(gomock.Any()).Return(k8sclient.MergeFrom(reloaded))
+			client.EXPECT().
+				PatchInstalledFeatureGroupStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, instance *InstalledFeatureGroup, _ k8sclient.Patch) error {
+					Expect(instance.ResourceVersion).Should(Equal("2"))
+					Expect(instance.Status.Features).Should(Equal([]InstalledFeatureGroupListedFeature{
+						{Namespace: namespace, Name: "basic-feature"},
+					}))
+
+					return nil
+				})
+
+			result, err := sut.Reconcile(iftReconcileRequest)
+
+			Expect(result).Should(Equal(reconcile.Result{Requeue: false}))
+			Expect(err).ShouldNot(HaveOccurred())
+		})
 	})
 })
 
@@ -227,6 +322,29 @@ func createIFTG(name string, namespace string, provider string, description stri
 	return result
 }
 
+func createMember(name string, namespace string, group string, deleted bool) InstalledFeature {
+	result := InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: InstalledFeatureSpec{
+			Kind:    name,
+			Version: "1.0.0",
+			Group: &InstalledFeatureRef{
+				Namespace: namespace,
+				Name:      group,
+			},
+		},
+	}
+
+	if deleted {
+		result.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	}
+
+	return result
+}
+
 func copyIFTG(orig *InstalledFeatureGroup) *InstalledFeatureGroup {
 	//goland:noinspection GoDeprecation
 	result := &InstalledFeatureGroup{
@@ -0,0 +1,194 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package nfddiscovery is the discovery source of the node features published by Node Feature Discovery. Every
+// feature.node.kubernetes.io/* node label and the architecture of the nodes is turned into a feature. The version of
+// such a feature is the number of nodes having it, so a dependency with version ">=3" requires at least three nodes
+// with that feature.
+package nfddiscovery
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// Source is the name of the discovery source and the value of the discovered-by label of its features.
+	Source = "nfd"
+
+	// LabelPrefix is the prefix of the node labels published by Node Feature Discovery.
+	LabelPrefix = "feature.node.kubernetes.io/"
+	// LabelArch is the well-known label of the architecture of a node.
+	LabelArch = corev1.LabelArchStable
+
+	// KindNodeFeature is the kind of the node features.
+	KindNodeFeature = "NodeFeature"
+	// AnnotationNodes is the number of nodes having the feature.
+	AnnotationNodes = "features.kaiserpfalz-edv.de/nodes"
+)
+
+var (
+	_ discovery.Discoverer = &Discoverer{}
+	_ discovery.Watcher    = &Discoverer{}
+)
+
+// Discoverer emits a feature for every node feature found on at least one node.
+type Discoverer struct {
+	Reader client.Reader
+}
+
+// New is the factory of the source.
+func New(mgr ctrl.Manager) (discovery.Discoverer, error) {
+	return &Discoverer{Reader: mgr.GetClient()}, nil
+}
+
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+
+func (d *Discoverer) Name() string {
+	return Source
+}
+
+// Watch triggers a sync when a node is added or removed or the labels of a node change. The frequent status updates
+// of the nodes are ignored.
+func (d *Discoverer) Watch(mgr ctrl.Manager, trigger func()) error {
+	labelsChanged := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return !reflect.DeepEqual(e.MetaOld.GetLabels(), e.MetaNew.GetLabels())
+		},
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("nfd-discovery").
+		For(&corev1.Node{}).
+		WithEventFilter(labelsChanged).
+		Complete(reconcile.Func(func(ctrl.Request) (ctrl.Result, error) {
+			trigger()
+
+			return ctrl.Result{}, nil
+		}))
+}
+
+// Discover counts the nodes having each node feature and returns a feature for every node feature found. Boolean
+// labels with value "false" are ignored.
+func (d *Discoverer) Discover(ctx context.Context) ([]featuresv1alpha1.InstalledFeature, error) {
+	nodes := &corev1.NodeList{}
+	if err := d.Reader.List(ctx, nodes); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	labels := make(map[string]string)
+	total := 0
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if node.DeletionTimestamp != nil {
+			continue
+		}
+		total++
+
+		// a node may carry labels mapped to the same feature name, it is counted once
+		found := make(map[string]bool)
+		for key, value := range node.Labels {
+			if name := FeatureName(key, value); name != "" && !found[name] {
+				found[name] = true
+				counts[name]++
+				if labels[name] == "" || key+"="+value < labels[name] {
+					labels[name] = key + "=" + value
+				}
+			}
+		}
+	}
+
+	result := make([]featuresv1alpha1.InstalledFeature, 0, len(counts))
+	for name, count := range counts {
+		result = append(result, *Feature(name, labels[name], count, total))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// FeatureName returns the name of the feature of a node label or "" if the label is no node feature. Boolean labels
+// are named after the label ("node.cpu-cpuid.avx512f" for "feature.node.kubernetes.io/cpu-cpuid.AVX512F=true"),
+// other labels after label and value ("node.kernel-version.major.5"). The architecture is "node.arch.<arch>".
+func FeatureName(key string, value string) string {
+	var name string
+	switch {
+	case key == LabelArch:
+		name = "arch"
+	case strings.HasPrefix(key, LabelPrefix):
+		name = strings.TrimPrefix(key, LabelPrefix)
+	default:
+		return ""
+	}
+
+	switch value {
+	case "false", "":
+		return ""
+	case "true":
+		return "node." + sanitize(name)
+	default:
+		return "node." + sanitize(name) + "." + sanitize(value)
+	}
+}
+
+// Feature returns the feature of a node feature found on count of total nodes. The label is one of the node labels
+// of the feature.
+func Feature(name string, label string, count int, total int) *featuresv1alpha1.InstalledFeature {
+	return &featuresv1alpha1.InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				featuresv1alpha1.AnnotationDiscoveredFrom: label,
+				AnnotationNodes: strconv.Itoa(count),
+			},
+		},
+		Spec: featuresv1alpha1.InstalledFeatureSpec{
+			Kind:        KindNodeFeature,
+			Version:     strconv.Itoa(count),
+			Description: fmt.Sprintf("%s on %d of %d nodes", label, count, total),
+		},
+	}
+}
+
+// sanitize turns a label name or value into a part of an object name: lower case, every character other than
+// letters, digits, "-" and "." replaced by "-".
+func sanitize(value string) string {
+	result := []rune(strings.ToLower(value))
+	for i, r := range result {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.') {
+			result[i] = '-'
+		}
+	}
+
+	return strings.Trim(string(result), "-.")
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nfddiscovery_test
+
+import (
+	"context"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/nfddiscovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/resolver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("NFD discovery source", func() {
+	var (
+		ctx   context.Context
+		nodes client.Client
+		sut   *nfddiscovery.Discoverer
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).Should(Succeed())
+		nodes = fake.NewFakeClientWithScheme(scheme,
+			node("worker-1", "amd64", "feature.node.kubernetes.io/cpu-cpuid.AVX512F", "true",
+				"feature.node.kubernetes.io/kernel-version.major", "5"),
+			node("worker-2", "amd64", "feature.node.kubernetes.io/cpu-cpuid.AVX512F", "true",
+				"feature.node.kubernetes.io/kernel-loadedmodule.nvme", "true"),
+			node("worker-3", "arm64", "feature.node.kubernetes.io/cpu-cpuid.AVX512F", "false",
+				"feature.node.kubernetes.io/system-os_release.ID", "ubuntu"),
+		)
+
+		sut = &nfddiscovery.Discoverer{Reader: nodes}
+	})
+
+	discover := func() map[string]InstalledFeature {
+		features, err := sut.Discover(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		result := make(map[string]InstalledFeature, len(features))
+		for _, feature := range features {
+			result[feature.Name] = feature
+		}
+
+		return result
+	}
+
+	It("should count the nodes having each node feature", func() {
+		features := discover()
+
+		Expect(features).Should(HaveLen(6))
+		Expect(features["node.cpu-cpuid.avx512f"].Spec).Should(Equal(InstalledFeatureSpec{
+			Kind:        nfddiscovery.KindNodeFeature,
+			Version:     "2",
+			Description: "feature.node.kubernetes.io/cpu-cpuid.AVX512F=true on 2 of 3 nodes",
+		}))
+		Expect(features["node.cpu-cpuid.avx512f"].Annotations).Should(HaveKeyWithValue(nfddiscovery.AnnotationNodes, "2"))
+		Expect(features["node.cpu-cpuid.avx512f"].Annotations).Should(HaveKeyWithValue(AnnotationDiscoveredFrom,
+			"feature.node.kubernetes.io/cpu-cpuid.AVX512F=true"))
+		Expect(features["node.kernel-loadedmodule.nvme"].Spec.Version).Should(Equal("1"))
+		Expect(features["node.kernel-version.major.5"].Spec.Version).Should(Equal("1"))
+		Expect(features["node.system-os-release.id.ubuntu"].Spec.Version).Should(Equal("1"))
+		Expect(features["node.arch.amd64"].Spec.Version).Should(Equal("2"))
+		Expect(features["node.arch.arm64"].Spec.Version).Should(Equal("1"))
+	})
+
+	It("should name the features after labels and values", func() {
+		Expect(nfddiscovery.FeatureName("feature.node.kubernetes.io/pci-0300_10de.present", "true")).Should(Equal("node.pci-0300-10de.present"))
+		Expect(nfddiscovery.FeatureName("feature.node.kubernetes.io/kernel-version.full", "5.4.0-1029-aws")).Should(Equal("node.kernel-version.full.5.4.0-1029-aws"))
+		Expect(nfddiscovery.FeatureName("kubernetes.io/arch", "amd64")).Should(Equal("node.arch.amd64"))
+		Expect(nfddiscovery.FeatureName("feature.node.kubernetes.io/cpu-cpuid.AVX", "false")).Should(BeEmpty())
+		Expect(nfddiscovery.FeatureName("topology.kubernetes.io/zone", "eu-central-1a")).Should(BeEmpty())
+	})
+
+	It("should let features require a number of nodes with a node feature", func() {
+		store := controllers.NewOcpClientMemory()
+		engine := discovery.NewEngine(store, logf.NullLogger{}, "nodes", 0)
+		engine.Add(sut)
+		Expect(engine.Sync(ctx, sut).Created).Should(Equal(6))
+
+		features, err := store.ListInstalledFeatures(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		small := InstalledFeature{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "small"},
+			Spec: InstalledFeatureSpec{
+				Kind:      "app",
+				Version:   "1.0.0",
+				DependsOn: []InstalledFeatureRef{{Namespace: "nodes", Name: "node.cpu-cpuid.avx512f", Version: ">=2"}},
+			},
+		}
+		large := InstalledFeature{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "large"},
+			Spec: InstalledFeatureSpec{
+				Kind:      "app",
+				Version:   "1.0.0",
+				DependsOn: []InstalledFeatureRef{{Namespace: "nodes", Name: "node.cpu-cpuid.avx512f", Version: ">=3"}},
+			},
+		}
+		features = append(features, small, large)
+
+		result := resolver.Resolve(features, nil)
+
+		Expect(result.FeatureStatus(&small).Phase).Should(Equal(PhaseProvisioned))
+		Expect(result.FeatureStatus(&large).Phase).Should(Equal(PhasePending))
+	})
+})
+
+func node(name string, arch string, labels ...string) *corev1.Node {
+	result := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   name,
+		Labels: map[string]string{"kubernetes.io/arch": arch, "kubernetes.io/hostname": name},
+	}}
+	for i := 0; i+1 < len(labels); i += 2 {
+		result.Labels[labels[i]] = labels[i+1]
+	}
+
+	return result
+}
@@ -26,12 +26,16 @@ import (
 )
 
 type OcpClient interface {
+	CreateInstalledFeature(ctx context.Context, instance *v1alpha1.InstalledFeature) error
+	DeleteInstalledFeature(ctx context.Context, lookup types.NamespacedName) error
 	LoadInstalledFeature(ctx context.Context, lookup types.NamespacedName) (*v1alpha1.InstalledFeature, error)
+	ListInstalledFeatures(ctx context.Context) ([]v1alpha1.InstalledFeature, error)
 	SaveInstalledFeature(ctx context.Context, instance *v1alpha1.InstalledFeature) error
 	GetInstalledFeaturePatchBase(instance *v1alpha1.InstalledFeature) client.Patch
 	PatchInstalledFeatureStatus(ctx context.Context, instance *v1alpha1.InstalledFeature, patch client.Patch) error
 
 	LoadInstalledFeatureGroup(ctx context.Context, lookup types.NamespacedName) (*v1alpha1.InstalledFeatureGroup, error)
+	ListInstalledFeatureGroups(ctx context.Context) ([]v1alpha1.InstalledFeatureGroup, error)
 	SaveInstalledFeatureGroup(ctx context.Context, instance *v1alpha1.InstalledFeatureGroup) error
 	GetInstalledFeatureGroupPatchBase(instance *v1alpha1.InstalledFeatureGroup) client.Patch
 	PatchInstalledFeatureGroupStatus(ctx context.Context, instance *v1alpha1.InstalledFeatureGroup, patch client.Patch) error
@@ -43,6 +47,18 @@ type OcpClientProd struct {
 	Client client.Client
 }
 
+func (o OcpClientProd) CreateInstalledFeature(ctx context.Context, instance *v1alpha1.InstalledFeature) error {
+	return o.Client.Create(ctx, instance)
+}
+
+func (o OcpClientProd) DeleteInstalledFeature(ctx context.Context, lookup types.NamespacedName) error {
+	instance := &v1alpha1.InstalledFeature{}
+	instance.Namespace = lookup.Namespace
+	instance.Name = lookup.Name
+
+	return o.Client.Delete(ctx, instance)
+}
+
 func (o OcpClientProd) LoadInstalledFeature(ctx context.Context, lookup types.NamespacedName) (*v1alpha1.InstalledFeature, error) {
 	instance := &v1alpha1.InstalledFeature{}
 
@@ -54,12 +70,23 @@ func (o OcpClientProd) LoadInstalledFeature(ctx context.Context, lookup types.Na
 	return instance, nil
 }
 
+func (o OcpClientProd) ListInstalledFeatures(ctx context.Context) ([]v1alpha1.InstalledFeature, error) {
+	list := &v1alpha1.InstalledFeatureList{}
+
+	err := o.Client.List(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list.Items, nil
+}
+
 func (o OcpClientProd) SaveInstalledFeature(ctx context.Context, instance *v1alpha1.InstalledFeature) error {
 	return o.Client.Update(ctx, instance)
 }
 
 func (o OcpClientProd) GetInstalledFeaturePatchBase(instance *v1alpha1.InstalledFeature) client.Patch {
-	return client.MergeFrom(instance.DeepCopy())
+	return client.MergeFromWithOptions(instance.DeepCopy(), client.MergeFromWithOptimisticLock{})
 }
 
 func (o OcpClientProd) PatchInstalledFeatureStatus(ctx context.Context, instance *v1alpha1.InstalledFeature, patch client.Patch) error {
@@ -77,12 +104,23 @@ func (o OcpClientProd) LoadInstalledFeatureGroup(ctx context.Context, lookup typ
 	return instance, nil
 }
 
+func (o OcpClientProd) ListInstalledFeatureGroups(ctx context.Context) ([]v1alpha1.InstalledFeatureGroup, error) {
+	list := &v1alpha1.InstalledFeatureGroupList{}
+
+	err := o.Client.List(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list.Items, nil
+}
+
 func (o OcpClientProd) SaveInstalledFeatureGroup(ctx context.Context, instance *v1alpha1.InstalledFeatureGroup) error {
 	return o.Client.Update(ctx, instance)
 }
 
 func (o OcpClientProd) GetInstalledFeatureGroupPatchBase(instance *v1alpha1.InstalledFeatureGroup) client.Patch {
-	return client.MergeFrom(instance.DeepCopy())
+	return client.MergeFromWithOptions(instance.DeepCopy(), client.MergeFromWithOptimisticLock{})
 }
 
 func (o OcpClientProd) PatchInstalledFeatureGroupStatus(ctx context.Context, instance *v1alpha1.InstalledFeatureGroup, patch client.Patch) error {
@@ -0,0 +1,459 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/google/uuid"
+	"github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	resourceFeatures = "installedfeatures"
+	resourceGroups   = "installedfeaturegroups"
+)
+
+var _ OcpClient = &OcpClientMemory{}
+
+// OcpClientMemory keeps the features and groups in memory. It behaves like the API server with status subresources:
+// updates don't change the status and patches of the status change nothing else, stale resource versions lead to
+// conflicts and objects with finalizers are only marked as deleted until the last finalizer is removed. It is used to
+// run the reconcilers without a cluster, e.g. in tests.
+type OcpClientMemory struct {
+	mutex    sync.RWMutex
+	revision int64
+	objects  map[string]map[types.NamespacedName]*unstructured.Unstructured
+}
+
+// NewOcpClientMemory creates an empty in-memory client.
+func NewOcpClientMemory() *OcpClientMemory {
+	return &OcpClientMemory{
+		objects: map[string]map[types.NamespacedName]*unstructured.Unstructured{
+			resourceFeatures: make(map[types.NamespacedName]*unstructured.Unstructured),
+			resourceGroups:   make(map[types.NamespacedName]*unstructured.Unstructured),
+		},
+	}
+}
+
+// Revision is increased with every change of the stored objects. It can be used to detect if anything changed.
+func (o *OcpClientMemory) Revision() int64 {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	return o.revision
+}
+
+// AddInstalledFeature stores a copy of the feature including its status. An existing feature is replaced. Missing
+// metadata like the resource version is set.
+func (o *OcpClientMemory) AddInstalledFeature(instance *v1alpha1.InstalledFeature) {
+	if err := o.add(resourceFeatures, instance); err != nil {
+		panic(err)
+	}
+}
+
+// AddInstalledFeatureGroup stores a copy of the group including its status. An existing group is replaced. Missing
+// metadata like the resource version is set.
+func (o *OcpClientMemory) AddInstalledFeatureGroup(instance *v1alpha1.InstalledFeatureGroup) {
+	if err := o.add(resourceGroups, instance); err != nil {
+		panic(err)
+	}
+}
+
+// InstalledFeatures returns copies of all stored features sorted by namespace and name.
+func (o *OcpClientMemory) InstalledFeatures() []v1alpha1.InstalledFeature {
+	objects := o.list(resourceFeatures)
+
+	result := make([]v1alpha1.InstalledFeature, len(objects))
+	for i, object := range objects {
+		if err := fromUnstructured(object, &result[i]); err != nil {
+			panic(err)
+		}
+	}
+
+	return result
+}
+
+// InstalledFeatureGroups returns copies of all stored groups sorted by namespace and name.
+func (o *OcpClientMemory) InstalledFeatureGroups() []v1alpha1.InstalledFeatureGroup {
+	objects := o.list(resourceGroups)
+
+	result := make([]v1alpha1.InstalledFeatureGroup, len(objects))
+	for i, object := range objects {
+		if err := fromUnstructured(object, &result[i]); err != nil {
+			panic(err)
+		}
+	}
+
+	return result
+}
+
+// CreateInstalledFeature creates the feature like the API server: the status is dropped and the metadata is set.
+func (o *OcpClientMemory) CreateInstalledFeature(_ context.Context, instance *v1alpha1.InstalledFeature) error {
+	return o.create(resourceFeatures, instance)
+}
+
+// DeleteInstalledFeature deletes the feature. A feature with finalizers is only marked as deleted.
+func (o *OcpClientMemory) DeleteInstalledFeature(_ context.Context, lookup types.NamespacedName) error {
+	return o.delete(resourceFeatures, lookup)
+}
+
+func (o *OcpClientMemory) LoadInstalledFeature(_ context.Context, lookup types.NamespacedName) (*v1alpha1.InstalledFeature, error) {
+	instance := &v1alpha1.InstalledFeature{}
+
+	err := o.load(resourceFeatures, lookup, instance)
+	if err != nil {
+		return nil, err
+	}
+
+	return instance, nil
+}
+
+func (o *OcpClientMemory) ListInstalledFeatures(_ context.Context) ([]v1alpha1.InstalledFeature, error) {
+	return o.InstalledFeatures(), nil
+}
+
+// SaveInstalledFeature updates the stored feature. As with the status subresource of the cluster, the
+// status is not changed.
+func (o *OcpClientMemory) SaveInstalledFeature(_ context.Context, instance *v1alpha1.InstalledFeature) error {
+	return o.update(resourceFeatures, instance)
+}
+
+func (o *OcpClientMemory) GetInstalledFeaturePatchBase(instance *v1alpha1.InstalledFeature) client.Patch {
+	return client.MergeFromWithOptions(instance.DeepCopy(), client.MergeFromWithOptimisticLock{})
+}
+
+// PatchInstalledFeatureStatus applies the patch to the stored feature. Only the status is changed.
+func (o *OcpClientMemory) PatchInstalledFeatureStatus(_ context.Context, instance *v1alpha1.InstalledFeature, patch client.Patch) error {
+	return o.patchStatus(resourceFeatures, instance, patch)
+}
+
+// CreateInstalledFeatureGroup creates the group like the API server: the status is dropped and the metadata is set.
+func (o *OcpClientMemory) CreateInstalledFeatureGroup(_ context.Context, instance *v1alpha1.InstalledFeatureGroup) error {
+	return o.create(resourceGroups, instance)
+}
+
+// DeleteInstalledFeatureGroup deletes the group. A group with finalizers is only marked as deleted.
+func (o *OcpClientMemory) DeleteInstalledFeatureGroup(_ context.Context, lookup types.NamespacedName) error {
+	return o.delete(resourceGroups, lookup)
+}
+
+func (o *OcpClientMemory) LoadInstalledFeatureGroup(_ context.Context, lookup types.NamespacedName) (*v1alpha1.InstalledFeatureGroup, error) {
+	instance := &v1alpha1.InstalledFeatureGroup{}
+
+	err := o.load(resourceGroups, lookup, instance)
+	if err != nil {
+		return nil, err
+	}
+
+	return instance, nil
+}
+
+func (o *OcpClientMemory) ListInstalledFeatureGroups(_ context.Context) ([]v1alpha1.InstalledFeatureGroup, error) {
+	return o.InstalledFeatureGroups(), nil
+}
+
+// SaveInstalledFeatureGroup updates the stored group. As with the status subresource of the cluster, the
+// status is not changed.
+func (o *OcpClientMemory) SaveInstalledFeatureGroup(_ context.Context, instance *v1alpha1.InstalledFeatureGroup) error {
+	return o.update(resourceGroups, instance)
+}
+
+func (o *OcpClientMemory) GetInstalledFeatureGroupPatchBase(instance *v1alpha1.InstalledFeatureGroup) client.Patch {
+	return client.MergeFromWithOptions(instance.DeepCopy(), client.MergeFromWithOptimisticLock{})
+}
+
+// PatchInstalledFeatureGroupStatus applies the patch to the stored group. Only the status is changed.
+func (o *OcpClientMemory) PatchInstalledFeatureGroupStatus(_ context.Context, instance *v1alpha1.InstalledFeatureGroup, patch client.Patch) error {
+	return o.patchStatus(resourceGroups, instance, patch)
+}
+
+// memoryObject is a typed object handled by the in-memory client.
+type memoryObject interface {
+	runtime.Object
+	metav1.Object
+}
+
+func (o *OcpClientMemory) add(resource string, instance memoryObject) error {
+	object, err := toUnstructured(instance)
+	if err != nil {
+		return err
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if object.GetUID() == "" {
+		object.SetUID(types.UID(uuid.New().String()))
+	}
+	if creation := object.GetCreationTimestamp(); creation.IsZero() {
+		object.SetCreationTimestamp(metav1.Now())
+	}
+	o.store(resource, object)
+
+	return nil
+}
+
+func (o *OcpClientMemory) list(resource string) []*unstructured.Unstructured {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	result := make([]*unstructured.Unstructured, 0, len(o.objects[resource]))
+	for _, object := range o.objects[resource] {
+		result = append(result, object.DeepCopy())
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].GetNamespace() != result[j].GetNamespace() {
+			return result[i].GetNamespace() < result[j].GetNamespace()
+		}
+		return result[i].GetName() < result[j].GetName()
+	})
+
+	return result
+}
+
+func (o *OcpClientMemory) load(resource string, lookup types.NamespacedName, instance memoryObject) error {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	stored, err := o.get(resource, lookup)
+	if err != nil {
+		return err
+	}
+
+	return fromUnstructured(stored, instance)
+}
+
+func (o *OcpClientMemory) create(resource string, instance memoryObject) error {
+	if instance.GetName() == "" {
+		return errors.NewBadRequest("the name of the object is missing")
+	}
+
+	object, err := toUnstructured(instance)
+	if err != nil {
+		return err
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	lookup := types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}
+	if _, ok := o.objects[resource][lookup]; ok {
+		return errors.NewAlreadyExists(v1alpha1.GroupVersion.WithResource(resource).GroupResource(), lookup.Name)
+	}
+
+	unstructured.RemoveNestedField(object.Object, "status")
+	object.SetUID(types.UID(uuid.New().String()))
+	object.SetCreationTimestamp(metav1.Now())
+	object.SetDeletionTimestamp(nil)
+	object.SetDeletionGracePeriodSeconds(nil)
+	object.SetGeneration(1)
+	o.store(resource, object)
+
+	return fromUnstructured(object, instance)
+}
+
+func (o *OcpClientMemory) update(resource string, instance memoryObject) error {
+	object, err := toUnstructured(instance)
+	if err != nil {
+		return err
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	lookup := types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}
+	stored, err := o.get(resource, lookup)
+	if err != nil {
+		return err
+	}
+
+	if object.GetResourceVersion() != "" && object.GetResourceVersion() != stored.GetResourceVersion() {
+		return conflict(resource, lookup)
+	}
+
+	// the status, the deletion and the identity of the object can't be changed by an update.
+	unstructured.RemoveNestedField(object.Object, "status")
+	if status, ok := stored.Object["status"]; ok {
+		object.Object["status"] = runtime.DeepCopyJSONValue(status)
+	}
+	object.SetUID(stored.GetUID())
+	object.SetCreationTimestamp(stored.GetCreationTimestamp())
+	object.SetDeletionTimestamp(stored.GetDeletionTimestamp())
+	object.SetDeletionGracePeriodSeconds(stored.GetDeletionGracePeriodSeconds())
+	object.SetResourceVersion(stored.GetResourceVersion())
+	object.SetGeneration(stored.GetGeneration())
+	if !reflect.DeepEqual(object.Object["spec"], stored.Object["spec"]) {
+		object.SetGeneration(stored.GetGeneration() + 1)
+	}
+
+	if object.GetDeletionTimestamp() != nil && len(object.GetFinalizers()) == 0 {
+		delete(o.objects[resource], lookup)
+		o.revision++
+
+		return fromUnstructured(object, instance)
+	}
+
+	if !reflect.DeepEqual(object.Object, stored.Object) {
+		o.store(resource, object)
+	}
+
+	return fromUnstructured(o.objects[resource][lookup], instance)
+}
+
+func (o *OcpClientMemory) patchStatus(resource string, instance memoryObject, patch client.Patch) error {
+	data, err := patch.Data(instance)
+	if err != nil {
+		return err
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	lookup := types.NamespacedName{Namespace: instance.GetNamespace(), Name: instance.GetName()}
+	stored, err := o.get(resource, lookup)
+	if err != nil {
+		return err
+	}
+
+	current, err := json.Marshal(stored.Object)
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	switch patch.Type() {
+	case types.MergePatchType:
+		patched, err = jsonpatch.MergePatch(current, data)
+	case types.JSONPatchType:
+		var operations jsonpatch.Patch
+		operations, err = jsonpatch.DecodePatch(data)
+		if err == nil {
+			patched, err = operations.Apply(current)
+		}
+	default:
+		return errors.NewBadRequest(fmt.Sprintf("patch type %s is not supported", patch.Type()))
+	}
+	if err != nil {
+		return errors.NewBadRequest(fmt.Sprintf("the patch can not be applied: %v", err))
+	}
+
+	result := &unstructured.Unstructured{}
+	if err := json.Unmarshal(patched, &result.Object); err != nil {
+		return err
+	}
+
+	// a patch containing the resource version is only applied to this version.
+	if result.GetResourceVersion() != stored.GetResourceVersion() {
+		return conflict(resource, lookup)
+	}
+
+	object := stored.DeepCopy()
+	if status, ok := result.Object["status"]; ok {
+		object.Object["status"] = status
+	} else {
+		unstructured.RemoveNestedField(object.Object, "status")
+	}
+
+	if !reflect.DeepEqual(object.Object, stored.Object) {
+		o.store(resource, object)
+	}
+
+	return fromUnstructured(o.objects[resource][lookup], instance)
+}
+
+func (o *OcpClientMemory) delete(resource string, lookup types.NamespacedName) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	stored, err := o.get(resource, lookup)
+	if err != nil {
+		return err
+	}
+
+	if len(stored.GetFinalizers()) == 0 {
+		delete(o.objects[resource], lookup)
+		o.revision++
+
+		return nil
+	}
+
+	if stored.GetDeletionTimestamp() == nil {
+		object := stored.DeepCopy()
+		now := metav1.Now()
+		gracePeriod := int64(0)
+		object.SetDeletionTimestamp(&now)
+		object.SetDeletionGracePeriodSeconds(&gracePeriod)
+		o.store(resource, object)
+	}
+
+	return nil
+}
+
+// get returns the stored object. The caller has to hold the lock and must not change the object.
+func (o *OcpClientMemory) get(resource string, lookup types.NamespacedName) (*unstructured.Unstructured, error) {
+	stored, ok := o.objects[resource][lookup]
+	if !ok {
+		return nil, errors.NewNotFound(v1alpha1.GroupVersion.WithResource(resource).GroupResource(), lookup.Name)
+	}
+
+	return stored, nil
+}
+
+// store saves the object with a new resource version. The caller has to hold the write lock.
+func (o *OcpClientMemory) store(resource string, object *unstructured.Unstructured) {
+	o.revision++
+	object.SetResourceVersion(strconv.FormatInt(o.revision, 10))
+
+	o.objects[resource][types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}] = object
+}
+
+func conflict(resource string, lookup types.NamespacedName) error {
+	return errors.NewConflict(v1alpha1.GroupVersion.WithResource(resource).GroupResource(), lookup.Name,
+		fmt.Errorf("the object has been modified; please apply your changes to the latest version and try again"))
+}
+
+func toUnstructured(instance memoryObject) (*unstructured.Unstructured, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	return &unstructured.Unstructured{Object: object}, nil
+}
+
+// fromUnstructured overwrites the instance with a copy of the object.
+func fromUnstructured(object *unstructured.Unstructured, instance memoryObject) error {
+	target := reflect.ValueOf(instance).Elem()
+	target.Set(reflect.Zero(target.Type()))
+
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(object.DeepCopy().Object, instance)
+}
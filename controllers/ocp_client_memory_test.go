@@ -0,0 +1,312 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("In-memory OcpClient", func() {
+	const finalizer = "features.kaiserpfalz-edv.de/test"
+
+	var (
+		ctx    = context.Background()
+		lookup = types.NamespacedName{Namespace: "default", Name: "basic-feature"}
+
+		store *OcpClientMemory
+	)
+
+	BeforeEach(func() {
+		store = NewOcpClientMemory()
+	})
+
+	createFeature := func(finalizers ...string) *InstalledFeature {
+		feature := &InstalledFeature{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:  lookup.Namespace,
+				Name:       lookup.Name,
+				Finalizers: finalizers,
+			},
+			Spec: InstalledFeatureSpec{
+				Kind:    lookup.Name,
+				Version: "1.0.0",
+			},
+			Status: InstalledFeatureStatus{Phase: PhaseProvisioned},
+		}
+
+		Expect(store.CreateInstalledFeature(ctx, feature)).Should(Succeed())
+		return feature
+	}
+
+	Context("Loading", func() {
+		It("should return NotFound for unknown features", func() {
+			_, err := store.LoadInstalledFeature(ctx, lookup)
+
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("should return NotFound for unknown groups", func() {
+			_, err := store.LoadInstalledFeatureGroup(ctx, lookup)
+
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("should return copies of the stored feature", func() {
+			createFeature()
+
+			loaded, err := store.LoadInstalledFeature(ctx, lookup)
+			Expect(err).ShouldNot(HaveOccurred())
+			loaded.Spec.Version = "2.0.0"
+
+			loaded, err = store.LoadInstalledFeature(ctx, lookup)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(loaded.Spec.Version).Should(Equal("1.0.0"))
+		})
+
+		It("should list the features sorted by namespace and name", func() {
+			for _, name := range []string{"zeta", "alpha"} {
+				Expect(store.CreateInstalledFeature(ctx, &InstalledFeature{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+				})).Should(Succeed())
+			}
+
+			features, err := store.ListInstalledFeatures(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(features).Should(HaveLen(2))
+			Expect(features[0].Name).Should(Equal("alpha"))
+			Expect(features[1].Name).Should(Equal("zeta"))
+		})
+	})
+
+	Context("Creating", func() {
+		It("should drop the status and set the metadata", func() {
+			feature := createFeature()
+
+			Expect(feature.Status).Should(Equal(InstalledFeatureStatus{}))
+			Expect(feature.ResourceVersion).ShouldNot(BeEmpty())
+			Expect(feature.UID).ShouldNot(BeEmpty())
+			Expect(feature.Generation).Should(Equal(int64(1)))
+			Expect(feature.CreationTimestamp.IsZero()).Should(BeFalse())
+		})
+
+		It("should reject existing features", func() {
+			createFeature()
+
+			err := store.CreateInstalledFeature(ctx, &InstalledFeature{
+				ObjectMeta: metav1.ObjectMeta{Namespace: lookup.Namespace, Name: lookup.Name},
+			})
+			Expect(errors.IsAlreadyExists(err)).Should(BeTrue())
+		})
+
+		It("should keep the status of added features", func() {
+			store.AddInstalledFeature(&InstalledFeature{
+				ObjectMeta: metav1.ObjectMeta{Namespace: lookup.Namespace, Name: lookup.Name},
+				Status:     InstalledFeatureStatus{Phase: PhasePending},
+			})
+
+			loaded, err := store.LoadInstalledFeature(ctx, lookup)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(loaded.Status.Phase).Should(Equal(PhasePending))
+		})
+	})
+
+	Context("Saving", func() {
+		It("should return NotFound for unknown features", func() {
+			err := store.SaveInstalledFeature(ctx, &InstalledFeature{
+				ObjectMeta: metav1.ObjectMeta{Namespace: lookup.Namespace, Name: lookup.Name},
+			})
+
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("should not change the status", func() {
+			feature := createFeature()
+			feature.Status.Phase = PhaseFailed
+			feature.Labels = map[string]string{"label": "value"}
+
+			Expect(store.SaveInstalledFeature(ctx, feature)).Should(Succeed())
+			Expect(feature.Status.Phase).Should(BeEmpty())
+			Expect(feature.Labels).Should(HaveKeyWithValue("label", "value"))
+		})
+
+		It("should increase the generation when the spec changes", func() {
+			feature := createFeature()
+			feature.Spec.Version = "2.0.0"
+
+			Expect(store.SaveInstalledFeature(ctx, feature)).Should(Succeed())
+			Expect(feature.Generation).Should(Equal(int64(2)))
+		})
+
+		It("should not change anything when nothing changed", func() {
+			feature := createFeature()
+			revision := store.Revision()
+
+			Expect(store.SaveInstalledFeature(ctx, feature)).Should(Succeed())
+			Expect(store.Revision()).Should(Equal(revision))
+		})
+
+		It("should reject stale resource versions", func() {
+			feature := createFeature()
+			stale := feature.DeepCopy()
+
+			feature.Spec.Version = "2.0.0"
+			Expect(store.SaveInstalledFeature(ctx, feature)).Should(Succeed())
+
+			stale.Spec.Version = "3.0.0"
+			Expect(errors.IsConflict(store.SaveInstalledFeature(ctx, stale))).Should(BeTrue())
+		})
+	})
+
+	Context("Patching the status", func() {
+		It("should merge the status", func() {
+			feature := createFeature()
+
+			patch := store.GetInstalledFeaturePatchBase(feature)
+			feature.Status.Phase = PhasePending
+			feature.Status.MissingDependencies = []InstalledFeatureRef{{Namespace: "default", Name: "other"}}
+			Expect(store.PatchInstalledFeatureStatus(ctx, feature, patch)).Should(Succeed())
+
+			loaded, err := store.LoadInstalledFeature(ctx, lookup)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(loaded.Status).Should(Equal(feature.Status))
+			Expect(loaded.ResourceVersion).Should(Equal(feature.ResourceVersion))
+		})
+
+		It("should not change anything but the status", func() {
+			feature := createFeature()
+
+			patch := store.GetInstalledFeaturePatchBase(feature)
+			feature.Spec.Version = "2.0.0"
+			feature.Status.Phase = PhasePending
+			Expect(store.PatchInstalledFeatureStatus(ctx, feature, patch)).Should(Succeed())
+
+			Expect(feature.Spec.Version).Should(Equal("1.0.0"))
+			Expect(feature.Status.Phase).Should(Equal(PhasePending))
+		})
+
+		It("should apply the patch to the stored status", func() {
+			feature := createFeature()
+			other := feature.DeepCopy()
+
+			patch := k8sclient.MergeFrom(feature.DeepCopy())
+			feature.Status.Phase = PhasePending
+			Expect(store.PatchInstalledFeatureStatus(ctx, feature, patch)).Should(Succeed())
+
+			patch = k8sclient.MergeFrom(other.DeepCopy())
+			other.Status.Message = "some message"
+			Expect(store.PatchInstalledFeatureStatus(ctx, other, patch)).Should(Succeed())
+
+			Expect(other.Status).Should(Equal(InstalledFeatureStatus{Phase: PhasePending, Message: "some message"}))
+		})
+
+		It("should reject stale resource versions with optimistic locking", func() {
+			feature := createFeature()
+			stale := feature.DeepCopy()
+
+			patch := store.GetInstalledFeaturePatchBase(feature)
+			feature.Status.Phase = PhasePending
+			Expect(store.PatchInstalledFeatureStatus(ctx, feature, patch)).Should(Succeed())
+
+			patch = store.GetInstalledFeaturePatchBase(stale)
+			stale.Status.Phase = PhaseFailed
+			Expect(errors.IsConflict(store.PatchInstalledFeatureStatus(ctx, stale, patch))).Should(BeTrue())
+		})
+
+		It("should return NotFound for unknown features", func() {
+			feature := &InstalledFeature{ObjectMeta: metav1.ObjectMeta{Namespace: lookup.Namespace, Name: lookup.Name, ResourceVersion: "1"}}
+
+			patch := store.GetInstalledFeaturePatchBase(feature)
+			feature.Status.Phase = PhasePending
+			Expect(errors.IsNotFound(store.PatchInstalledFeatureStatus(ctx, feature, patch))).Should(BeTrue())
+		})
+
+		It("should reject unsupported patch types", func() {
+			feature := createFeature()
+
+			err := store.PatchInstalledFeatureStatus(ctx, feature, k8sclient.ConstantPatch(types.StrategicMergePatchType, []byte("{}")))
+			Expect(errors.IsBadRequest(err)).Should(BeTrue())
+		})
+
+		It("should apply JSON patches", func() {
+			feature := createFeature()
+
+			err := store.PatchInstalledFeatureStatus(ctx, feature, k8sclient.ConstantPatch(types.JSONPatchType,
+				[]byte(`[{"op":"add","path":"/status","value":{"phase":"failed"}}]`)))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(feature.Status.Phase).Should(Equal(PhaseFailed))
+		})
+	})
+
+	Context("Deleting", func() {
+		It("should remove features without finalizers", func() {
+			createFeature()
+
+			Expect(store.DeleteInstalledFeature(ctx, lookup)).Should(Succeed())
+
+			_, err := store.LoadInstalledFeature(ctx, lookup)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("should mark features with finalizers as deleted", func() {
+			createFeature(finalizer)
+
+			Expect(store.DeleteInstalledFeature(ctx, lookup)).Should(Succeed())
+
+			loaded, err := store.LoadInstalledFeature(ctx, lookup)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(loaded.DeletionTimestamp).ShouldNot(BeNil())
+		})
+
+		It("should remove the feature with the last finalizer", func() {
+			createFeature(finalizer)
+			Expect(store.DeleteInstalledFeature(ctx, lookup)).Should(Succeed())
+
+			loaded, err := store.LoadInstalledFeature(ctx, lookup)
+			Expect(err).ShouldNot(HaveOccurred())
+			loaded.Finalizers = nil
+			Expect(store.SaveInstalledFeature(ctx, loaded)).Should(Succeed())
+
+			_, err = store.LoadInstalledFeature(ctx, lookup)
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("should not remove the deletion timestamp on updates", func() {
+			createFeature(finalizer)
+			Expect(store.DeleteInstalledFeature(ctx, lookup)).Should(Succeed())
+
+			loaded, err := store.LoadInstalledFeature(ctx, lookup)
+			Expect(err).ShouldNot(HaveOccurred())
+			loaded.DeletionTimestamp = nil
+			Expect(store.SaveInstalledFeature(ctx, loaded)).Should(Succeed())
+
+			Expect(loaded.DeletionTimestamp).ShouldNot(BeNil())
+		})
+
+		It("should return NotFound for unknown groups", func() {
+			Expect(errors.IsNotFound(store.DeleteInstalledFeatureGroup(ctx, lookup))).Should(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,299 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package olmdiscovery is the discovery source of the operators installed by the Operator Lifecycle Manager. Every
+// ClusterServiceVersion is turned into a feature in the namespace of the CSV. The CSVs are read as unstructured
+// objects, so the OLM API is not needed to build or run the operator.
+package olmdiscovery
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// Source is the name of the discovery source and the value of the discovered-by label of its features.
+	Source = "olm"
+
+	// LabelCopiedFrom marks the copies of a CSV OLM places into every namespace watched by the operator.
+	LabelCopiedFrom = "olm.copiedFrom"
+
+	// AnnotationLinks lists all links of the CSV, one "<name>: <url>" per line. The first one is the URI of the feature.
+	AnnotationLinks = "features.kaiserpfalz-edv.de/links"
+)
+
+// The phases of a ClusterServiceVersion mapped to the phases of features.
+const (
+	csvPhaseSucceeded = "Succeeded"
+	csvPhaseFailed    = "Failed"
+)
+
+var (
+	_ discovery.Discoverer = &Discoverer{}
+	_ discovery.Watcher    = &Discoverer{}
+
+	// ClusterServiceVersionKind is the kind of the OLM ClusterServiceVersions.
+	ClusterServiceVersionKind = schema.GroupVersionKind{
+		Group:   "operators.coreos.com",
+		Version: "v1alpha1",
+		Kind:    "ClusterServiceVersion",
+	}
+)
+
+// Discoverer emits a feature for every ClusterServiceVersion.
+type Discoverer struct {
+	Reader client.Reader
+}
+
+// New is the factory of the source.
+func New(mgr ctrl.Manager) (discovery.Discoverer, error) {
+	return &Discoverer{Reader: mgr.GetClient()}, nil
+}
+
+// +kubebuilder:rbac:groups=operators.coreos.com,resources=clusterserviceversions,verbs=get;list;watch
+
+func (d *Discoverer) Name() string {
+	return Source
+}
+
+// Watch triggers a sync on every change of a CSV.
+func (d *Discoverer) Watch(mgr ctrl.Manager, trigger func()) error {
+	csv := &unstructured.Unstructured{}
+	csv.SetGroupVersionKind(ClusterServiceVersionKind)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("olm-discovery").
+		For(csv).
+		Complete(reconcile.Func(func(ctrl.Request) (ctrl.Result, error) {
+			trigger()
+
+			return ctrl.Result{}, nil
+		}))
+}
+
+// Discover returns a feature for every CSV. Copies of CSVs and CSVs being deleted are ignored. The required CRDs and
+// API services of a CSV become dependencies on the CSV owning them or, if no CSV owns them, on the feature of their
+// API group discovered from the CRDs.
+func (d *Discoverer) Discover(ctx context.Context) ([]featuresv1alpha1.InstalledFeature, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(ClusterServiceVersionKind.GroupVersion().WithKind(ClusterServiceVersionKind.Kind + "List"))
+	if err := d.Reader.List(ctx, list); err != nil {
+		return nil, err
+	}
+
+	var csvs []*unstructured.Unstructured
+	for i := range list.Items {
+		csv := &list.Items[i]
+		if csv.GetDeletionTimestamp() != nil || csv.GetLabels()[LabelCopiedFrom] != "" || status(csv, "reason") == "Copied" {
+			continue
+		}
+
+		csvs = append(csvs, csv)
+	}
+
+	owners := make(map[string]featuresv1alpha1.InstalledFeatureRef)
+	for _, csv := range csvs {
+		owner := featuresv1alpha1.InstalledFeatureRef{Namespace: csv.GetNamespace(), Name: FeatureName(csv)}
+
+		for _, crd := range descriptions(csv, "customresourcedefinitions", "owned") {
+			owners[crdKey(crd)] = owner
+		}
+		for _, api := range descriptions(csv, "apiservicedefinitions", "owned") {
+			owners[apiKey(api)] = owner
+		}
+	}
+
+	result := make([]featuresv1alpha1.InstalledFeature, 0, len(csvs))
+	for _, csv := range csvs {
+		result = append(result, *Feature(csv, owners))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// FeatureName is the name of the CSV without the version suffix, e.g. "etcdoperator" for "etcdoperator.v0.9.4".
+func FeatureName(csv *unstructured.Unstructured) string {
+	version := spec(csv, "version")
+	if version != "" {
+		if name := strings.TrimSuffix(csv.GetName(), ".v"+version); name != csv.GetName() {
+			return name
+		}
+	}
+
+	return csv.GetName()
+}
+
+// Feature returns the feature of the CSV. The owners map the required CRDs and API services to the features owning
+// them. A feature has a single URI, so the first link of the CSV is used and all links are kept in AnnotationLinks.
+func Feature(csv *unstructured.Unstructured, owners map[string]featuresv1alpha1.InstalledFeatureRef) *featuresv1alpha1.InstalledFeature {
+	name := FeatureName(csv)
+
+	description := csv.GetAnnotations()["description"]
+	if description == "" {
+		description = spec(csv, "displayName")
+	}
+
+	provider, _, _ := unstructured.NestedString(csv.Object, "spec", "provider", "name")
+
+	var uri string
+	var links []string
+	csvLinks, _, _ := unstructured.NestedSlice(csv.Object, "spec", "links")
+	for _, link := range csvLinks {
+		if l, ok := link.(map[string]interface{}); ok {
+			if url, ok := l["url"].(string); ok && url != "" {
+				if uri == "" {
+					uri = url
+				}
+
+				if name, _ := l["name"].(string); name != "" {
+					url = name + ": " + url
+				}
+				links = append(links, url)
+			}
+		}
+	}
+
+	dependencies := make(map[featuresv1alpha1.InstalledFeatureRef]bool)
+	for _, crd := range descriptions(csv, "customresourcedefinitions", "required") {
+		dependency, ok := owners[crdKey(crd)]
+		if !ok {
+			crdName, _ := crd["name"].(string)
+			dependency = featuresv1alpha1.InstalledFeatureRef{Name: groupOf(crdName)}
+		}
+		dependencies[dependency] = true
+	}
+	for _, api := range descriptions(csv, "apiservicedefinitions", "required") {
+		dependency, ok := owners[apiKey(api)]
+		if !ok {
+			group, _ := api["group"].(string)
+			dependency = featuresv1alpha1.InstalledFeatureRef{Name: group}
+		}
+		dependencies[dependency] = true
+	}
+	delete(dependencies, featuresv1alpha1.InstalledFeatureRef{Namespace: csv.GetNamespace(), Name: name})
+
+	var dependsOn []featuresv1alpha1.InstalledFeatureRef
+	for dependency := range dependencies {
+		if dependency.Name != "" {
+			dependsOn = append(dependsOn, dependency)
+		}
+	}
+	sort.Slice(dependsOn, func(i, j int) bool {
+		return dependsOn[i].String() < dependsOn[j].String()
+	})
+
+	annotations := map[string]string{
+		featuresv1alpha1.AnnotationDiscoveredFrom: csv.GetName(),
+		featuresv1alpha1.AnnotationSourcePhase:    Phase(status(csv, "phase")),
+		featuresv1alpha1.AnnotationSourceMessage:  status(csv, "message"),
+	}
+	if len(links) > 0 {
+		annotations[AnnotationLinks] = strings.Join(links, "\n")
+	}
+
+	return &featuresv1alpha1.InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   csv.GetNamespace(),
+			Name:        name,
+			Annotations: annotations,
+		},
+		Spec: featuresv1alpha1.InstalledFeatureSpec{
+			Kind:        name,
+			Version:     spec(csv, "version"),
+			Provider:    provider,
+			Description: description,
+			Uri:         uri,
+			DependsOn:   dependsOn,
+		},
+	}
+}
+
+// Phase maps the phase of a CSV to the phase of a feature. A succeeded CSV is provisioned, a failed one failed and all
+// other phases (pending, installing, replacing, ...) are initializing.
+func Phase(csvPhase string) string {
+	switch csvPhase {
+	case csvPhaseSucceeded:
+		return featuresv1alpha1.PhaseProvisioned
+	case csvPhaseFailed:
+		return featuresv1alpha1.PhaseFailed
+	default:
+		return featuresv1alpha1.PhaseInitializing
+	}
+}
+
+func spec(csv *unstructured.Unstructured, field string) string {
+	value, _, _ := unstructured.NestedString(csv.Object, "spec", field)
+	return value
+}
+
+func status(csv *unstructured.Unstructured, field string) string {
+	value, _, _ := unstructured.NestedString(csv.Object, "status", field)
+	return value
+}
+
+// descriptions returns the owned or required CRD or API service descriptions of the CSV.
+func descriptions(csv *unstructured.Unstructured, definitions string, kind string) []map[string]interface{} {
+	values, _, _ := unstructured.NestedSlice(csv.Object, "spec", definitions, kind)
+
+	result := make([]map[string]interface{}, 0, len(values))
+	for _, value := range values {
+		if description, ok := value.(map[string]interface{}); ok {
+			result = append(result, description)
+		}
+	}
+
+	return result
+}
+
+func crdKey(crd map[string]interface{}) string {
+	name, _ := crd["name"].(string)
+	return "crd:" + name
+}
+
+func apiKey(api map[string]interface{}) string {
+	group, _ := api["group"].(string)
+	version, _ := api["version"].(string)
+	kind, _ := api["kind"].(string)
+
+	return "api:" + group + "/" + version + "/" + kind
+}
+
+// groupOf returns the API group of a CRD name. CRDs are named "<plural>.<group>".
+func groupOf(crdName string) string {
+	parts := strings.SplitN(crdName, ".", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+
+	return parts[1]
+}
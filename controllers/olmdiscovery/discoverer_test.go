@@ -0,0 +1,164 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package olmdiscovery_test
+
+import (
+	"context"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/olmdiscovery"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("OLM discovery source", func() {
+	discover := func(csvs ...runtime.Object) []InstalledFeature {
+		sut := &olmdiscovery.Discoverer{Reader: fake.NewFakeClientWithScheme(scheme, csvs...)}
+
+		features, err := sut.Discover(context.Background())
+		Expect(err).ShouldNot(HaveOccurred())
+
+		return features
+	}
+
+	It("should copy version, provider, description and links from the CSV", func() {
+		features := discover(csv("operators", "etcdoperator.v0.9.4", "0.9.4", "Succeeded"))
+
+		Expect(features).Should(HaveLen(1))
+		Expect(features[0].Namespace).Should(Equal("operators"))
+		Expect(features[0].Name).Should(Equal("etcdoperator"))
+		Expect(features[0].Spec).Should(Equal(InstalledFeatureSpec{
+			Kind:        "etcdoperator",
+			Version:     "0.9.4",
+			Provider:    "CNCF",
+			Description: "Create and maintain highly-available etcd clusters on Kubernetes",
+			Uri:         "https://coreos.com/etcd",
+		}))
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(olmdiscovery.AnnotationLinks,
+			"Blog: https://coreos.com/etcd\nDocumentation: https://coreos.com/operators/etcd/docs/latest/"))
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationDiscoveredFrom, "etcdoperator.v0.9.4"))
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationSourcePhase, PhaseProvisioned))
+	})
+
+	It("should keep the name of CSVs without version suffix", func() {
+		features := discover(csv("operators", "etcdoperator", "0.9.4", "Succeeded"))
+
+		Expect(features[0].Name).Should(Equal("etcdoperator"))
+	})
+
+	It("should depend on the CSVs owning the required CRDs and APIs", func() {
+		etcd := csv("operators", "etcdoperator.v0.9.4", "0.9.4", "Succeeded")
+		owns(etcd, "customresourcedefinitions", map[string]interface{}{"name": "etcdclusters.etcd.database.coreos.com", "version": "v1beta2", "kind": "EtcdCluster"})
+		metrics := csv("monitoring", "metrics-server.v1.0.0", "1.0.0", "Succeeded")
+		owns(metrics, "apiservicedefinitions", map[string]interface{}{"group": "metrics.k8s.io", "version": "v1beta1", "kind": "PodMetrics", "name": "pods"})
+
+		app := csv("apps", "app-operator.v2.0.0", "2.0.0", "Succeeded")
+		requires(app, "customresourcedefinitions", map[string]interface{}{"name": "etcdclusters.etcd.database.coreos.com", "version": "v1beta2", "kind": "EtcdCluster"})
+		requires(app, "customresourcedefinitions", map[string]interface{}{"name": "certificates.cert-manager.io", "version": "v1", "kind": "Certificate"})
+		requires(app, "apiservicedefinitions", map[string]interface{}{"group": "metrics.k8s.io", "version": "v1beta1", "kind": "PodMetrics"})
+
+		features := discover(etcd, metrics, app)
+
+		Expect(features).Should(HaveLen(3))
+		Expect(features[0].Name).Should(Equal("app-operator"))
+		Expect(features[0].Spec.DependsOn).Should(Equal([]InstalledFeatureRef{
+			{Name: "cert-manager.io"},
+			{Namespace: "monitoring", Name: "metrics-server"},
+			{Namespace: "operators", Name: "etcdoperator"},
+		}))
+	})
+
+	It("should not depend on the own CRDs", func() {
+		etcd := csv("operators", "etcdoperator.v0.9.4", "0.9.4", "Succeeded")
+		owns(etcd, "customresourcedefinitions", map[string]interface{}{"name": "etcdclusters.etcd.database.coreos.com"})
+		requires(etcd, "customresourcedefinitions", map[string]interface{}{"name": "etcdclusters.etcd.database.coreos.com"})
+
+		features := discover(etcd)
+
+		Expect(features[0].Spec.DependsOn).Should(BeEmpty())
+	})
+
+	It("should ignore the copies of CSVs", func() {
+		copied := csv("apps", "etcdoperator.v0.9.4", "0.9.4", "Succeeded")
+		copied.SetLabels(map[string]string{olmdiscovery.LabelCopiedFrom: "operators"})
+		Expect(unstructured.SetNestedField(copied.Object, "Copied", "status", "reason")).Should(Succeed())
+
+		features := discover(csv("operators", "etcdoperator.v0.9.4", "0.9.4", "Succeeded"), copied)
+
+		Expect(features).Should(HaveLen(1))
+		Expect(features[0].Namespace).Should(Equal("operators"))
+	})
+
+	It("should report the phase and message of the CSV", func() {
+		failed := csv("operators", "etcdoperator.v0.9.4", "0.9.4", "Failed")
+		Expect(unstructured.SetNestedField(failed.Object, "install strategy failed", "status", "message")).Should(Succeed())
+
+		features := discover(failed)
+
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationSourcePhase, PhaseFailed))
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationSourceMessage, "install strategy failed"))
+	})
+
+	It("should map the phases of CSVs", func() {
+		Expect(olmdiscovery.Phase("Succeeded")).Should(Equal(PhaseProvisioned))
+		Expect(olmdiscovery.Phase("Failed")).Should(Equal(PhaseFailed))
+		Expect(olmdiscovery.Phase("Installing")).Should(Equal(PhaseInitializing))
+		Expect(olmdiscovery.Phase("Replacing")).Should(Equal(PhaseInitializing))
+		Expect(olmdiscovery.Phase("")).Should(Equal(PhaseInitializing))
+	})
+})
+
+func csv(namespace string, name string, version string, phase string) *unstructured.Unstructured {
+	result := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"displayName": "etcd",
+			"version":     version,
+			"provider":    map[string]interface{}{"name": "CNCF"},
+			"links": []interface{}{
+				map[string]interface{}{"name": "Blog", "url": "https://coreos.com/etcd"},
+				map[string]interface{}{"name": "Documentation", "url": "https://coreos.com/operators/etcd/docs/latest/"},
+			},
+		},
+		"status": map[string]interface{}{
+			"phase": phase,
+		},
+	}}
+	result.SetGroupVersionKind(olmdiscovery.ClusterServiceVersionKind)
+	result.SetNamespace(namespace)
+	result.SetName(name)
+	result.SetAnnotations(map[string]string{"description": "Create and maintain highly-available etcd clusters on Kubernetes"})
+	result.SetCreationTimestamp(metav1.Now())
+
+	return result
+}
+
+func owns(csv *unstructured.Unstructured, definitions string, description map[string]interface{}) {
+	add(csv, definitions, "owned", description)
+}
+
+func requires(csv *unstructured.Unstructured, definitions string, description map[string]interface{}) {
+	add(csv, definitions, "required", description)
+}
+
+func add(csv *unstructured.Unstructured, definitions string, kind string, description map[string]interface{}) {
+	descriptions, _, _ := unstructured.NestedSlice(csv.Object, "spec", definitions, kind)
+	Expect(unstructured.SetNestedSlice(csv.Object, append(descriptions, description), "spec", definitions, kind)).Should(Succeed())
+}
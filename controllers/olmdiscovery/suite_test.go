@@ -0,0 +1,86 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package olmdiscovery_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/olmdiscovery"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+var (
+	scheme *runtime.Scheme
+
+	// cfg is only set when the envtest binaries are installed (see "make test").
+	cfg     *rest.Config
+	testEnv *envtest.Environment
+)
+
+func TestOlmDiscovery(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecsWithDefaultAndCustomReporters(t,
+		"OLM Discovery Suite",
+		[]Reporter{printer.NewlineReporter{}})
+}
+
+var _ = BeforeSuite(func() {
+	logf.SetLogger(zap.New(zap.UseDevMode(true), zap.WriteTo(GinkgoWriter)))
+
+	scheme = runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).Should(Succeed())
+	Expect(featuresv1alpha1.AddToScheme(scheme)).Should(Succeed())
+
+	// the fake client only handles the CSVs when their kinds are known to the scheme.
+	gv := olmdiscovery.ClusterServiceVersionKind.GroupVersion()
+	scheme.AddKnownTypeWithName(olmdiscovery.ClusterServiceVersionKind, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(gv.WithKind(olmdiscovery.ClusterServiceVersionKind.Kind+"List"), &unstructured.UnstructuredList{})
+
+	By("bootstrapping test environment with the CSV CRD")
+	testEnv = &envtest.Environment{
+		CRDDirectoryPaths: []string{
+			filepath.Join("..", "..", "config", "crd", "bases"),
+			"testdata",
+		},
+	}
+
+	var err error
+	cfg, err = testEnv.Start()
+	if err != nil {
+		logf.Log.Info("envtest is not available, skipping the envtest specs", "error", err.Error())
+		cfg = nil
+	}
+}, 60)
+
+var _ = AfterSuite(func() {
+	if cfg != nil {
+		By("tearing down the test environment")
+		Expect(testEnv.Stop()).Should(Succeed())
+	}
+})
@@ -0,0 +1,356 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package platformdiscovery is the discovery source of the capabilities of the cluster itself. The storage, ingress,
+// runtime, priority and volume snapshot classes and the served API versions are published as features, so
+// applications can depend on them like on any other feature, e.g. on "default-storageclass" or "ingressclass-nginx".
+// The classes are read as unstructured objects in the version served by the cluster.
+package platformdiscovery
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sdiscovery "k8s.io/client-go/discovery"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// Source is the name of the discovery source and the value of the discovered-by label of its features.
+	Source = "platform"
+
+	// KindAPI is the kind of the features of the served API versions.
+	KindAPI = "API"
+	// CoreGroup is the name used for the core API group in the names of the API features, e.g. "v1.core".
+	CoreGroup = "core"
+	// LabelDefault marks the features of the default classes with "true", the other classes of kinds with a default
+	// class with "false".
+	LabelDefault = "features.kaiserpfalz-edv.de/default-class"
+)
+
+// Class describes a kind of cluster scoped classes published as features.
+type Class struct {
+	Group string
+	// Versions are the versions of the class in order of preference. The first version served by the cluster is read.
+	Versions []string
+	Kind     string
+	// Provider is the path of the field naming the implementation of the class, e.g. the provisioner.
+	Provider []string
+	// IsDefault checks if the class is the default class of its kind.
+	IsDefault func(class *unstructured.Unstructured) bool
+}
+
+var (
+	_ discovery.Discoverer = &Discoverer{}
+	_ discovery.Watcher    = &Discoverer{}
+
+	// Classes are all classes published by the source.
+	Classes = []Class{
+		{
+			Group:     "storage.k8s.io",
+			Versions:  []string{"v1", "v1beta1"},
+			Kind:      "StorageClass",
+			Provider:  []string{"provisioner"},
+			IsDefault: annotatedDefault("storageclass.kubernetes.io/is-default-class", "storageclass.beta.kubernetes.io/is-default-class"),
+		},
+		{
+			Group:     "networking.k8s.io",
+			Versions:  []string{"v1", "v1beta1"},
+			Kind:      "IngressClass",
+			Provider:  []string{"spec", "controller"},
+			IsDefault: annotatedDefault("ingressclass.kubernetes.io/is-default-class"),
+		},
+		{
+			Group:    "node.k8s.io",
+			Versions: []string{"v1", "v1beta1"},
+			Kind:     "RuntimeClass",
+			Provider: []string{"handler"},
+		},
+		{
+			Group:    "scheduling.k8s.io",
+			Versions: []string{"v1", "v1beta1"},
+			Kind:     "PriorityClass",
+			IsDefault: func(class *unstructured.Unstructured) bool {
+				value, _, _ := unstructured.NestedBool(class.Object, "globalDefault")
+				return value
+			},
+		},
+		{
+			Group:     "snapshot.storage.k8s.io",
+			Versions:  []string{"v1", "v1beta1"},
+			Kind:      "VolumeSnapshotClass",
+			Provider:  []string{"driver"},
+			IsDefault: annotatedDefault("snapshot.storage.kubernetes.io/is-default-class"),
+		},
+	}
+)
+
+// Discoverer emits features for the classes and served API versions of the cluster.
+type Discoverer struct {
+	Reader    client.Reader
+	Discovery k8sdiscovery.ServerGroupsInterface
+}
+
+// New is the factory of the source.
+func New(mgr ctrl.Manager) (discovery.Discoverer, error) {
+	discoveryClient, err := k8sdiscovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Discoverer{Reader: mgr.GetClient(), Discovery: discoveryClient}, nil
+}
+
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingressclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=node.k8s.io,resources=runtimeclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=scheduling.k8s.io,resources=priorityclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshotclasses,verbs=get;list;watch
+
+func (d *Discoverer) Name() string {
+	return Source
+}
+
+// Watch triggers a sync on every change of a class served by the cluster when the operator starts. New API versions
+// are found by the periodic sync.
+func (d *Discoverer) Watch(mgr ctrl.Manager, trigger func()) error {
+	kinds, err := d.servedClasses()
+	if err != nil {
+		return err
+	}
+
+	var objects []*unstructured.Unstructured
+	for _, kind := range kinds {
+		object := &unstructured.Unstructured{}
+		object.SetGroupVersionKind(kind)
+		objects = append(objects, object)
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+
+	builder := ctrl.NewControllerManagedBy(mgr).
+		Named("platform-discovery").
+		For(objects[0])
+	for _, object := range objects[1:] {
+		builder = builder.Watches(&source.Kind{Type: object}, &handler.EnqueueRequestForObject{})
+	}
+
+	return builder.Complete(reconcile.Func(func(ctrl.Request) (ctrl.Result, error) {
+		trigger()
+
+		return ctrl.Result{}, nil
+	}))
+}
+
+// Discover returns a feature for every served API version and every class. The default class of a kind is published
+// a second time as "default-<kind>".
+func (d *Discoverer) Discover(ctx context.Context) ([]featuresv1alpha1.InstalledFeature, error) {
+	groups, err := d.Discovery.ServerGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]featuresv1alpha1.InstalledFeature, 0)
+	for _, group := range groups.Groups {
+		for _, version := range group.Versions {
+			result = append(result, *APIFeature(group.Name, version.Version))
+		}
+	}
+
+	for _, kind := range servedClasses(groups) {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(kind.GroupVersion().WithKind(kind.Kind + "List"))
+		if err := d.Reader.List(ctx, list); err != nil {
+			return nil, err
+		}
+
+		class := classOf(kind)
+		defaults := 0
+		for j := range list.Items {
+			item := &list.Items[j]
+			if item.GetDeletionTimestamp() != nil {
+				continue
+			}
+
+			feature := Feature(class, item)
+			result = append(result, *feature)
+
+			if class.IsDefault != nil && class.IsDefault(item) {
+				defaults++
+				result = append(result, *DefaultFeature(feature))
+			}
+		}
+
+		// with more than one default class, the cluster has no well defined default
+		if defaults > 1 {
+			result = withoutDefaults(result, DefaultName(kind.Kind))
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// APIFeature returns the feature of a served API version. It is named "<version>.<group>" like the APIServices of
+// the cluster, the core group is called "core".
+func APIFeature(group string, version string) *featuresv1alpha1.InstalledFeature {
+	if group == "" {
+		group = CoreGroup
+	}
+
+	return &featuresv1alpha1.InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: version + "." + group,
+			Annotations: map[string]string{
+				featuresv1alpha1.AnnotationDiscoveredFrom: "APIGroup/" + group,
+			},
+		},
+		Spec: featuresv1alpha1.InstalledFeatureSpec{
+			Kind:        KindAPI,
+			Version:     version,
+			Description: "API " + group + "/" + version + " is served",
+		},
+	}
+}
+
+// Feature returns the feature of a class. It is named "<kind>-<name>" in lower case, e.g. "storageclass-standard". The
+// version is the API version of the class, the provider the implementation of the class (provisioner, controller,
+// handler or driver).
+func Feature(class Class, object *unstructured.Unstructured) *featuresv1alpha1.InstalledFeature {
+	provider := ""
+	if class.Provider != nil {
+		provider, _, _ = unstructured.NestedString(object.Object, class.Provider...)
+	}
+
+	var labels map[string]string
+	if class.IsDefault != nil {
+		labels = map[string]string{LabelDefault: strconv.FormatBool(class.IsDefault(object))}
+	}
+
+	return &featuresv1alpha1.InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   strings.ToLower(class.Kind) + "-" + object.GetName(),
+			Labels: labels,
+			Annotations: map[string]string{
+				featuresv1alpha1.AnnotationDiscoveredFrom: class.Kind + "/" + object.GetName(),
+			},
+		},
+		Spec: featuresv1alpha1.InstalledFeatureSpec{
+			Kind:        class.Kind,
+			Version:     object.GroupVersionKind().Version,
+			Provider:    provider,
+			Description: class.Kind + " " + object.GetName(),
+		},
+	}
+}
+
+// DefaultName is the name of the feature of the default class of a kind, e.g. "default-storageclass".
+func DefaultName(kind string) string {
+	return "default-" + strings.ToLower(kind)
+}
+
+// DefaultFeature returns the feature of the default class of the kind of the given class feature.
+func DefaultFeature(classFeature *featuresv1alpha1.InstalledFeature) *featuresv1alpha1.InstalledFeature {
+	result := classFeature.DeepCopy()
+	result.Name = DefaultName(classFeature.Spec.Kind)
+	result.Labels = nil
+	result.Spec.Description = "Default " + classFeature.Spec.Description
+
+	return result
+}
+
+// servedClasses returns the kinds of the classes served by the cluster.
+func (d *Discoverer) servedClasses() ([]schema.GroupVersionKind, error) {
+	groups, err := d.Discovery.ServerGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	return servedClasses(groups), nil
+}
+
+// servedClasses returns the kinds of the classes served by the cluster with their preferred served version, in the
+// order of Classes.
+func servedClasses(groups *metav1.APIGroupList) []schema.GroupVersionKind {
+	served := make(map[string]bool)
+	for _, group := range groups.Groups {
+		for _, version := range group.Versions {
+			served[version.GroupVersion] = true
+		}
+	}
+
+	result := make([]schema.GroupVersionKind, 0, len(Classes))
+	for _, class := range Classes {
+		for _, version := range class.Versions {
+			gv := schema.GroupVersion{Group: class.Group, Version: version}
+			if served[gv.String()] {
+				result = append(result, gv.WithKind(class.Kind))
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+func classOf(kind schema.GroupVersionKind) Class {
+	for _, class := range Classes {
+		if class.Group == kind.Group && class.Kind == kind.Kind {
+			return class
+		}
+	}
+
+	return Class{Group: kind.Group, Kind: kind.Kind}
+}
+
+func withoutDefaults(features []featuresv1alpha1.InstalledFeature, name string) []featuresv1alpha1.InstalledFeature {
+	result := features[:0]
+	for _, feature := range features {
+		if feature.Name != name {
+			result = append(result, feature)
+		}
+	}
+
+	return result
+}
+
+// annotatedDefault checks the default class annotations with the value "true".
+func annotatedDefault(annotations ...string) func(class *unstructured.Unstructured) bool {
+	return func(class *unstructured.Unstructured) bool {
+		for _, annotation := range annotations {
+			if class.GetAnnotations()[annotation] == "true" {
+				return true
+			}
+		}
+
+		return false
+	}
+}
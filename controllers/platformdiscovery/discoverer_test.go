@@ -0,0 +1,192 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package platformdiscovery_test
+
+import (
+	"context"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/platformdiscovery"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("Platform discovery source", func() {
+	var (
+		ctx     context.Context
+		classes client.Client
+		served  *clienttesting.Fake
+		sut     *platformdiscovery.Discoverer
+	)
+
+	snapshotClass := schema.GroupVersionKind{Group: "snapshot.storage.k8s.io", Version: "v1beta1", Kind: "VolumeSnapshotClass"}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).Should(Succeed())
+		scheme.AddKnownTypeWithName(snapshotClass, &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(snapshotClass.GroupVersion().WithKind("VolumeSnapshotClassList"), &unstructured.UnstructuredList{})
+		classes = fake.NewFakeClientWithScheme(scheme)
+
+		served = &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+			{GroupVersion: "v1"},
+			{GroupVersion: "apps/v1"},
+			{GroupVersion: "storage.k8s.io/v1"},
+			{GroupVersion: "storage.k8s.io/v1beta1"},
+			{GroupVersion: "networking.k8s.io/v1beta1"},
+			{GroupVersion: "scheduling.k8s.io/v1"},
+		}}
+
+		sut = &platformdiscovery.Discoverer{Reader: classes, Discovery: &fakediscovery.FakeDiscovery{Fake: served}}
+	})
+
+	discover := func() map[string]InstalledFeature {
+		features, err := sut.Discover(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		result := make(map[string]InstalledFeature, len(features))
+		for _, feature := range features {
+			result[feature.Name] = feature
+		}
+
+		return result
+	}
+
+	It("should publish every served API version", func() {
+		features := discover()
+
+		Expect(features).Should(HaveLen(6))
+		Expect(features).Should(HaveKey("v1.core"))
+		Expect(features).Should(HaveKey("v1.apps"))
+		Expect(features).Should(HaveKey("v1beta1.storage.k8s.io"))
+		Expect(features["v1beta1.networking.k8s.io"].Spec).Should(Equal(InstalledFeatureSpec{
+			Kind:        platformdiscovery.KindAPI,
+			Version:     "v1beta1",
+			Description: "API networking.k8s.io/v1beta1 is served",
+		}))
+	})
+
+	It("should publish the classes and the default class", func() {
+		Expect(classes.Create(ctx, &storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "standard",
+				Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"},
+			},
+			Provisioner: "kubernetes.io/aws-ebs",
+		})).Should(Succeed())
+		Expect(classes.Create(ctx, &storagev1.StorageClass{
+			ObjectMeta:  metav1.ObjectMeta{Name: "fast"},
+			Provisioner: "kubernetes.io/aws-ebs",
+		})).Should(Succeed())
+		Expect(classes.Create(ctx, &networkingv1beta1.IngressClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "nginx"},
+			Spec:       networkingv1beta1.IngressClassSpec{Controller: "k8s.io/ingress-nginx"},
+		})).Should(Succeed())
+		Expect(classes.Create(ctx, &schedulingv1.PriorityClass{
+			ObjectMeta:    metav1.ObjectMeta{Name: "normal"},
+			Value:         1000,
+			GlobalDefault: true,
+		})).Should(Succeed())
+
+		features := discover()
+
+		Expect(features["storageclass-standard"].Spec).Should(Equal(InstalledFeatureSpec{
+			Kind:        "StorageClass",
+			Version:     "v1",
+			Provider:    "kubernetes.io/aws-ebs",
+			Description: "StorageClass standard",
+		}))
+		Expect(features["storageclass-standard"].Labels).Should(HaveKeyWithValue(platformdiscovery.LabelDefault, "true"))
+		Expect(features["storageclass-fast"].Labels).Should(HaveKeyWithValue(platformdiscovery.LabelDefault, "false"))
+		Expect(features["default-storageclass"].Spec.Description).Should(Equal("Default StorageClass standard"))
+		Expect(features["default-storageclass"].Annotations).Should(HaveKeyWithValue(AnnotationDiscoveredFrom, "StorageClass/standard"))
+
+		Expect(features["ingressclass-nginx"].Spec.Version).Should(Equal("v1beta1"))
+		Expect(features["ingressclass-nginx"].Spec.Provider).Should(Equal("k8s.io/ingress-nginx"))
+		Expect(features).ShouldNot(HaveKey("default-ingressclass"))
+
+		Expect(features).Should(HaveKey("priorityclass-normal"))
+		Expect(features).Should(HaveKey("default-priorityclass"))
+	})
+
+	It("should not publish a default class when several classes claim to be the default", func() {
+		for _, name := range []string{"standard", "fast"} {
+			Expect(classes.Create(ctx, &storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        name,
+					Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"},
+				},
+			})).Should(Succeed())
+		}
+
+		features := discover()
+
+		Expect(features).Should(HaveKey("storageclass-standard"))
+		Expect(features).Should(HaveKey("storageclass-fast"))
+		Expect(features).ShouldNot(HaveKey("default-storageclass"))
+	})
+
+	It("should read the classes of CRDs only when they are served", func() {
+		served.Resources = append(served.Resources, &metav1.APIResourceList{GroupVersion: "snapshot.storage.k8s.io/v1beta1"})
+		snapshots := &unstructured.Unstructured{Object: map[string]interface{}{"driver": "ebs.csi.aws.com"}}
+		snapshots.SetGroupVersionKind(snapshotClass)
+		snapshots.SetName("ebs")
+		snapshots.SetAnnotations(map[string]string{"snapshot.storage.kubernetes.io/is-default-class": "true"})
+		Expect(classes.Create(ctx, snapshots)).Should(Succeed())
+
+		features := discover()
+
+		Expect(features["volumesnapshotclass-ebs"].Spec.Provider).Should(Equal("ebs.csi.aws.com"))
+		Expect(features["default-volumesnapshotclass"].Spec.Version).Should(Equal("v1beta1"))
+	})
+
+	It("should publish the default storage class in the discovery namespace", func() {
+		store := controllers.NewOcpClientMemory()
+		engine := discovery.NewEngine(store, logf.NullLogger{}, "platform", 0)
+		engine.Add(sut)
+
+		Expect(classes.Create(ctx, &storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "standard",
+				Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"},
+			},
+		})).Should(Succeed())
+		Expect(engine.Sync(ctx, sut).Error).Should(BeEmpty())
+
+		feature, err := store.LoadInstalledFeature(ctx, types.NamespacedName{Namespace: "platform", Name: "default-storageclass"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(feature.Labels).Should(HaveKeyWithValue(LabelDiscoveredBy, platformdiscovery.Source))
+	})
+})
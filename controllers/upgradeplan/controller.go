@@ -0,0 +1,128 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package upgradeplan
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/planner"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// RequeueTime is the default requeuing time when the operator is running in problems
+const RequeueTime = 60 * time.Second
+
+// The default requeue for error handling
+var errorRequeue = ctrl.Result{RequeueAfter: RequeueTime}
+
+// Reconciler computes the steps of UpgradePlan objects. The plans are recomputed whenever an InstalledFeature changes.
+type Reconciler struct {
+	Client client.Client
+
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	// MaxConcurrentReconciles is the number of plans reconciled in parallel. Defaults to 1.
+	MaxConcurrentReconciles int
+}
+
+// +kubebuilder:rbac:groups=features.kaiserpfalz-edv.de,resources=upgradeplans,verbs=get;list;watch
+// +kubebuilder:rbac:groups=features.kaiserpfalz-edv.de,resources=upgradeplans/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=features.kaiserpfalz-edv.de,resources=installedfeatures,verbs=get;list;watch
+
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&featuresv1alpha1.UpgradePlan{}).
+		Watches(&source.Kind{Type: &featuresv1alpha1.InstalledFeature{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.allPlans)}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+// allPlans maps a feature to the requests of all plans, since every feature may change the order of every plan.
+func (r *Reconciler) allPlans(obj handler.MapObject) []reconcile.Request {
+	plans := &featuresv1alpha1.UpgradePlanList{}
+	if err := r.Client.List(context.Background(), plans); err != nil {
+		r.Log.Error(err, "could not list the upgradeplans", "feature", obj.Meta.GetName())
+		return nil
+	}
+
+	result := make([]reconcile.Request, len(plans.Items))
+	for i, plan := range plans.Items {
+		result[i] = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: plan.Namespace, Name: plan.Name}}
+	}
+
+	return result
+}
+
+func (r *Reconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	reqLogger := r.Log.WithValues("upgrade-plan", req.NamespacedName)
+	reqLogger.Info("working on", "ctx", ctx)
+
+	attempt := 0
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		attempt++
+		if attempt > 1 {
+			reqLogger.Info("retrying after conflict", "attempt", attempt)
+		}
+
+		plan := &featuresv1alpha1.UpgradePlan{}
+		if err := r.Client.Get(ctx, req.NamespacedName, plan); err != nil {
+			return err
+		}
+
+		features := &featuresv1alpha1.InstalledFeatureList{}
+		if err := r.Client.List(ctx, features); err != nil {
+			reqLogger.Info("could not list the installedfeatures")
+
+			return err
+		}
+
+		status := planner.Plan(features.Items, plan.Namespace, plan.Spec.Targets)
+		if equality.Semantic.DeepEqual(plan.Status, status) {
+			return nil
+		}
+
+		patch := client.MergeFromWithOptions(plan.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		plan.Status = status
+
+		return r.Client.Status().Patch(ctx, plan, patch)
+	})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{Requeue: false}, nil
+		}
+
+		return errorRequeue, err
+	}
+
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package upgradeplan_test
+
+import (
+	"context"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/controllers/upgradeplan"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("UpgradePlan controller", func() {
+	const (
+		name      = "upgrade"
+		namespace = "default"
+	)
+	var (
+		key     = types.NamespacedName{Namespace: namespace, Name: name}
+		request = reconcile.Request{NamespacedName: key}
+
+		k8s client.Client
+		sut Reconciler
+	)
+
+	setup := func(objs ...runtime.Object) {
+		scheme := runtime.NewScheme()
+		Expect(AddToScheme(scheme)).Should(Succeed())
+
+		k8s = fake.NewFakeClientWithScheme(scheme, objs...)
+		sut = Reconciler{Client: k8s, Log: logf.Log, Scheme: scheme}
+	}
+
+	feature := func(name, version string, depends ...InstalledFeatureRef) *InstalledFeature {
+		return &InstalledFeature{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       InstalledFeatureSpec{Kind: name, Version: version, DependsOn: depends},
+		}
+	}
+
+	plan := func(targets ...UpgradeTarget) *UpgradePlan {
+		return &UpgradePlan{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, ResourceVersion: "1"},
+			Spec:       UpgradePlanSpec{Targets: targets},
+		}
+	}
+
+	load := func() UpgradePlanStatus {
+		result := &UpgradePlan{}
+		Expect(k8s.Get(context.Background(), key, result)).Should(Succeed())
+
+		return result.Status
+	}
+
+	It("should write the planned steps to the status", func() {
+		setup(
+			feature("db", "1.0.0"),
+			feature("app", "1.0.0", InstalledFeatureRef{Namespace: namespace, Name: "db", Version: ">=1.0.0"}),
+			plan(
+				UpgradeTarget{Name: "app", Version: "2.0.0", DependsOn: []InstalledFeatureRef{{Namespace: namespace, Name: "db", Version: ">=2.0.0"}}},
+				UpgradeTarget{Name: "db", Version: "2.0.0"},
+			),
+		)
+
+		result, err := sut.Reconcile(request)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result).Should(Equal(reconcile.Result{}))
+		Expect(load()).Should(Equal(UpgradePlanStatus{
+			Phase:   PhasePlanned,
+			Message: "2 upgrades planned",
+			Steps: []UpgradeStep{
+				{Namespace: namespace, Name: "db", From: "1.0.0", To: "2.0.0"},
+				{Namespace: namespace, Name: "app", From: "1.0.0", To: "2.0.0"},
+			},
+		}))
+	})
+
+	It("should block targets of features not installed", func() {
+		setup(plan(UpgradeTarget{Name: "db", Version: "2.0.0"}))
+
+		_, err := sut.Reconcile(request)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		status := load()
+		Expect(status.Phase).Should(Equal(PhaseBlocked))
+		Expect(status.Blocked).Should(HaveLen(1))
+		Expect(status.Blocked[0].Name).Should(Equal("db"))
+	})
+
+	It("should ignore deleted plans", func() {
+		setup(feature("db", "1.0.0"))
+
+		result, err := sut.Reconcile(request)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result).Should(Equal(reconcile.Result{}))
+	})
+})
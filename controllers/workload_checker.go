@@ -0,0 +1,122 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controllers
+
+import (
+	"context"
+	"sort"
+
+	"github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/images"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WorkloadChecker checks the availability of the workloads running features.
+type WorkloadChecker interface {
+	// Workloads returns the availability of the workloads selected in the given namespace and the versions of the
+	// images of the feature they run.
+	Workloads(ctx context.Context, namespace string, workloads *v1alpha1.WorkloadSelector) (*v1alpha1.WorkloadStatus, error)
+}
+
+var _ WorkloadChecker = &WorkloadCheckerProd{}
+
+// WorkloadCheckerProd sums up the ready and desired replicas of the selected Deployments, StatefulSets and DaemonSets
+// and collects the versions of the images of the feature from their pod templates.
+type WorkloadCheckerProd struct {
+	Client client.Reader
+}
+
+func (w WorkloadCheckerProd) Workloads(ctx context.Context, namespace string, workloads *v1alpha1.WorkloadSelector) (*v1alpha1.WorkloadStatus, error) {
+	labelSelector, err := metav1.LabelSelectorAsSelector(&workloads.Selector)
+	if err != nil {
+		return nil, err
+	}
+	options := []client.ListOption{client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: labelSelector}}
+
+	result := &v1alpha1.WorkloadStatus{}
+	versions := make(map[string]bool)
+
+	deployments := &appsv1.DeploymentList{}
+	if err := w.Client.List(ctx, deployments, options...); err != nil {
+		return nil, err
+	}
+	for _, deployment := range deployments.Items {
+		result.Workloads++
+		result.ReadyReplicas += deployment.Status.ReadyReplicas
+		result.DesiredReplicas += desiredReplicas(deployment.Spec.Replicas)
+		imageVersions(versions, &deployment.Spec.Template.Spec, workloads.Images)
+	}
+
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := w.Client.List(ctx, statefulSets, options...); err != nil {
+		return nil, err
+	}
+	for _, statefulSet := range statefulSets.Items {
+		result.Workloads++
+		result.ReadyReplicas += statefulSet.Status.ReadyReplicas
+		result.DesiredReplicas += desiredReplicas(statefulSet.Spec.Replicas)
+		imageVersions(versions, &statefulSet.Spec.Template.Spec, workloads.Images)
+	}
+
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := w.Client.List(ctx, daemonSets, options...); err != nil {
+		return nil, err
+	}
+	for _, daemonSet := range daemonSets.Items {
+		result.Workloads++
+		result.ReadyReplicas += daemonSet.Status.NumberReady
+		result.DesiredReplicas += daemonSet.Status.DesiredNumberScheduled
+		imageVersions(versions, &daemonSet.Spec.Template.Spec, workloads.Images)
+	}
+
+	for version := range versions {
+		result.Versions = append(result.Versions, version)
+	}
+	sort.Strings(result.Versions)
+
+	return result, nil
+}
+
+// desiredReplicas returns the replicas of the spec, which default to 1.
+func desiredReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+
+	return *replicas
+}
+
+// imageVersions adds the versions of all containers of the pod running one of the repositories.
+func imageVersions(versions map[string]bool, pod *corev1.PodSpec, repositories []string) {
+	containers := append(append([]corev1.Container{}, pod.InitContainers...), pod.Containers...)
+	for _, container := range containers {
+		reference := images.Parse(container.Image)
+		version := reference.Version()
+		if version == "" {
+			continue
+		}
+
+		for _, repository := range repositories {
+			if repository == reference.Repository {
+				versions[version] = true
+			}
+		}
+	}
+}
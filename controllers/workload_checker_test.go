@@ -0,0 +1,121 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controllers_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Workload checker", func() {
+	var sut controllers.WorkloadCheckerProd
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(appsv1.AddToScheme(scheme)).Should(Succeed())
+
+		two := int32(2)
+		labels := map[string]string{"app.kubernetes.io/part-of": "cert-manager"}
+		sut = controllers.WorkloadCheckerProd{
+			Client: fake.NewFakeClientWithScheme(scheme,
+				&appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "cert-manager", Name: "cert-manager", Labels: labels},
+					Spec:       appsv1.DeploymentSpec{Replicas: &two, Template: pod("quay.io/jetstack/cert-manager-controller:v1.5.3", "busybox:1.32")},
+					Status:     appsv1.DeploymentStatus{ReadyReplicas: 1},
+				},
+				&appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "cert-manager", Name: "webhook", Labels: labels},
+					Spec:       appsv1.DeploymentSpec{Template: pod("quay.io/jetstack/cert-manager-webhook:v1.5.3")},
+					Status:     appsv1.DeploymentStatus{ReadyReplicas: 1},
+				},
+				&appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "cert-manager", Name: "cache", Labels: labels},
+					Spec:       appsv1.StatefulSetSpec{Replicas: &two, Template: pod("quay.io/jetstack/cert-manager-controller:v1.6.0@sha256:0123")},
+					Status:     appsv1.StatefulSetStatus{ReadyReplicas: 2},
+				},
+				&appsv1.DaemonSet{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "cert-manager", Name: "agent", Labels: labels},
+					Spec:       appsv1.DaemonSetSpec{Template: pod("quay.io/jetstack/cert-manager-webhook@sha256:0123")},
+					Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: 3, NumberReady: 3},
+				},
+				&appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "cert-manager", Name: "other"},
+					Status:     appsv1.DeploymentStatus{ReadyReplicas: 1},
+				},
+				&appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cert-manager", Labels: labels},
+					Status:     appsv1.DeploymentStatus{ReadyReplicas: 1},
+				},
+			),
+		}
+	})
+
+	It("should sum up the replicas of all selected workloads in the namespace", func() {
+		result, err := sut.Workloads(context.Background(), "cert-manager", &WorkloadSelector{
+			Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app.kubernetes.io/part-of": "cert-manager"}},
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result).Should(Equal(&WorkloadStatus{Workloads: 4, ReadyReplicas: 7, DesiredReplicas: 8}))
+	})
+
+	It("should collect the tags of the images of the feature and the digests of images without tag", func() {
+		result, err := sut.Workloads(context.Background(), "cert-manager", &WorkloadSelector{
+			Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app.kubernetes.io/part-of": "cert-manager"}},
+			Images:   []string{"quay.io/jetstack/cert-manager-controller", "quay.io/jetstack/cert-manager-webhook"},
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.Versions).Should(Equal([]string{"sha256:0123", "v1.5.3", "v1.6.0"}))
+	})
+
+	It("should return no workloads when nothing matches", func() {
+		result, err := sut.Workloads(context.Background(), "cert-manager", &WorkloadSelector{
+			Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "unknown"}},
+		})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result).Should(Equal(&WorkloadStatus{}))
+	})
+
+	It("should fail on an invalid selector", func() {
+		_, err := sut.Workloads(context.Background(), "cert-manager", &WorkloadSelector{
+			Selector: metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: "Unknown"}}},
+		})
+
+		Expect(err).Should(HaveOccurred())
+	})
+})
+
+func pod(images ...string) corev1.PodTemplateSpec {
+	result := corev1.PodTemplateSpec{}
+	for i, image := range images {
+		result.Spec.Containers = append(result.Spec.Containers, corev1.Container{Name: fmt.Sprintf("c%d", i), Image: image})
+	}
+
+	return result
+}
@@ -0,0 +1,257 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package workloaddiscovery is the discovery source of the features registered by annotations on Deployments,
+// StatefulSets and DaemonSets. Teams annotate their workloads instead of writing InstalledFeatures, the features are
+// owned by the workloads and garbage collected with them.
+package workloaddiscovery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/images"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/resolver"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// Source is the name of the discovery source and the value of the discovered-by label of its features.
+	Source = "workload"
+
+	// AnnotationKind registers the workload as feature of this kind. Workloads without it are ignored.
+	AnnotationKind = "features.kaiserpfalz-edv.de/kind"
+	// AnnotationName is the name of the feature. Defaults to the name of the workload.
+	AnnotationName = "features.kaiserpfalz-edv.de/name"
+	// AnnotationVersion is the version of the feature. Defaults to the app.kubernetes.io/version label or the image
+	// tag of the first container.
+	AnnotationVersion = "features.kaiserpfalz-edv.de/version"
+	// AnnotationGroup is the group of the feature as "[namespace/]name".
+	AnnotationGroup = "features.kaiserpfalz-edv.de/group"
+	// AnnotationDepends lists the dependencies as "[namespace/]name[@range]", separated by whitespace or ";" (see
+	// resolver.ParseRefs).
+	AnnotationDepends = "features.kaiserpfalz-edv.de/depends"
+	// AnnotationConflicts lists the conflicting features like AnnotationDepends.
+	AnnotationConflicts = "features.kaiserpfalz-edv.de/conflicts"
+	// AnnotationProvider is the provider of the feature.
+	AnnotationProvider = "features.kaiserpfalz-edv.de/provider"
+	// AnnotationDescription is the description of the feature.
+	AnnotationDescription = "features.kaiserpfalz-edv.de/description"
+	// AnnotationUri is the URI of the documentation of the feature.
+	AnnotationUri = "features.kaiserpfalz-edv.de/uri"
+
+	// LabelVersion is the label containing the version of the workload.
+	LabelVersion = "app.kubernetes.io/version"
+)
+
+var (
+	_ discovery.Discoverer = &Discoverer{}
+	_ discovery.Watcher    = &Discoverer{}
+)
+
+// Discoverer emits a feature for every annotated workload.
+type Discoverer struct {
+	Reader client.Reader
+}
+
+// New is the factory of the source.
+func New(mgr ctrl.Manager) (discovery.Discoverer, error) {
+	return &Discoverer{Reader: mgr.GetClient()}, nil
+}
+
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list;watch
+
+func (d *Discoverer) Name() string {
+	return Source
+}
+
+// Watch triggers a sync whenever an annotated workload changes or the annotation is added or removed.
+func (d *Discoverer) Watch(mgr ctrl.Manager, trigger func()) error {
+	annotated := predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return isAnnotated(e.Meta)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return isAnnotated(e.MetaOld) || isAnnotated(e.MetaNew)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return isAnnotated(e.Meta)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return isAnnotated(e.Meta)
+		},
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("workload-discovery").
+		For(&appsv1.Deployment{}).
+		Watches(&source.Kind{Type: &appsv1.StatefulSet{}}, &handler.EnqueueRequestForObject{}).
+		Watches(&source.Kind{Type: &appsv1.DaemonSet{}}, &handler.EnqueueRequestForObject{}).
+		WithEventFilter(annotated).
+		Complete(reconcile.Func(func(ctrl.Request) (ctrl.Result, error) {
+			trigger()
+
+			return ctrl.Result{}, nil
+		}))
+}
+
+// Discover returns a feature for every annotated Deployment, StatefulSet and DaemonSet.
+func (d *Discoverer) Discover(ctx context.Context) ([]featuresv1alpha1.InstalledFeature, error) {
+	deployments := &appsv1.DeploymentList{}
+	if err := d.Reader.List(ctx, deployments); err != nil {
+		return nil, err
+	}
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := d.Reader.List(ctx, statefulSets); err != nil {
+		return nil, err
+	}
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := d.Reader.List(ctx, daemonSets); err != nil {
+		return nil, err
+	}
+
+	var result []featuresv1alpha1.InstalledFeature
+	add := func(workload metav1.Object, kind string, template *corev1.PodTemplateSpec) {
+		if isAnnotated(workload) && workload.GetDeletionTimestamp() == nil {
+			result = append(result, *Feature(workload, kind, template))
+		}
+	}
+	for i := range deployments.Items {
+		add(&deployments.Items[i], "Deployment", &deployments.Items[i].Spec.Template)
+	}
+	for i := range statefulSets.Items {
+		add(&statefulSets.Items[i], "StatefulSet", &statefulSets.Items[i].Spec.Template)
+	}
+	for i := range daemonSets.Items {
+		add(&daemonSets.Items[i], "DaemonSet", &daemonSets.Items[i].Spec.Template)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// Feature returns the feature registered by the annotations of the workload of the given apps/v1 kind. The feature is created in the namespace
+// of the workload and owned by it. References without namespace point to the namespace of the workload.
+func Feature(workload metav1.Object, kind string, template *corev1.PodTemplateSpec) *featuresv1alpha1.InstalledFeature {
+	annotations := workload.GetAnnotations()
+	namespace := workload.GetNamespace()
+
+	name := annotations[AnnotationName]
+	if name == "" {
+		name = workload.GetName()
+	}
+
+	owner := true
+	result := &featuresv1alpha1.InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Annotations: map[string]string{
+				featuresv1alpha1.AnnotationDiscoveredFrom: kind + "/" + workload.GetName(),
+			},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: appsv1.SchemeGroupVersion.String(),
+				Kind:       kind,
+				Name:       workload.GetName(),
+				UID:        workload.GetUID(),
+				Controller: &owner,
+			}},
+		},
+		Spec: featuresv1alpha1.InstalledFeatureSpec{
+			Kind:        annotations[AnnotationKind],
+			Version:     version(workload, template),
+			Provider:    annotations[AnnotationProvider],
+			Description: annotations[AnnotationDescription],
+			Uri:         annotations[AnnotationUri],
+		},
+	}
+
+	var errs []error
+	var err error
+	result.Spec.DependsOn, err = resolver.ParseRefs(annotations[AnnotationDepends], namespace)
+	errs = append(errs, annotationError(AnnotationDepends, err))
+	result.Spec.Conflicts, err = resolver.ParseRefs(annotations[AnnotationConflicts], namespace)
+	errs = append(errs, annotationError(AnnotationConflicts, err))
+
+	if group := strings.TrimSpace(annotations[AnnotationGroup]); group != "" {
+		refs, err := resolver.ParseRefs(group, namespace)
+		if err == nil && len(refs) != 1 {
+			err = fmt.Errorf("exactly one group expected")
+		}
+		if err == nil {
+			result.Spec.Group = &featuresv1alpha1.InstalledFeatureRef{Namespace: refs[0].Namespace, Name: refs[0].Name}
+		}
+		errs = append(errs, annotationError(AnnotationGroup, err))
+	}
+
+	// invalid annotations fail the feature instead of the whole source
+	if err := utilerrors.NewAggregate(errs); err != nil {
+		result.Annotations[featuresv1alpha1.AnnotationSourcePhase] = featuresv1alpha1.PhaseFailed
+		result.Annotations[featuresv1alpha1.AnnotationSourceMessage] = err.Error()
+	}
+
+	return result
+}
+
+// annotationError names the annotation in the error of parsing it.
+func annotationError(annotation string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return fmt.Errorf("invalid annotation %s: %v", annotation, err)
+}
+
+// version returns the version annotation, the app.kubernetes.io/version label or the image tag of the first container.
+func version(workload metav1.Object, template *corev1.PodTemplateSpec) string {
+	if v := workload.GetAnnotations()[AnnotationVersion]; v != "" {
+		return v
+	}
+	if v := workload.GetLabels()[LabelVersion]; v != "" {
+		return v
+	}
+
+	if len(template.Spec.Containers) > 0 {
+		return images.Parse(template.Spec.Containers[0].Image).Tag
+	}
+
+	return ""
+}
+
+func isAnnotated(workload metav1.Object) bool {
+	return workload.GetAnnotations()[AnnotationKind] != ""
+}
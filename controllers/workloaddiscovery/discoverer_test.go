@@ -0,0 +1,193 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workloaddiscovery_test
+
+import (
+	"context"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/workloaddiscovery"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("Workload discovery source", func() {
+	const namespace = "team-a"
+
+	var (
+		ctx       context.Context
+		workloads client.Client
+		sut       *workloaddiscovery.Discoverer
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).Should(Succeed())
+		workloads = fake.NewFakeClientWithScheme(scheme)
+
+		sut = &workloaddiscovery.Discoverer{Reader: workloads}
+	})
+
+	discover := func() []InstalledFeature {
+		features, err := sut.Discover(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		return features
+	}
+
+	It("should register the feature of an annotated deployment owned by the deployment", func() {
+		Expect(workloads.Create(ctx, deployment(namespace, "billing", map[string]string{
+			workloaddiscovery.AnnotationKind:        "billing",
+			workloaddiscovery.AnnotationVersion:     "1.2.0",
+			workloaddiscovery.AnnotationGroup:       "platform/business",
+			workloaddiscovery.AnnotationDepends:     "postgres/postgres-operator@>=1.5.0,<2 ; accounts",
+			workloaddiscovery.AnnotationConflicts:   "legacy-billing",
+			workloaddiscovery.AnnotationProvider:    "Team A",
+			workloaddiscovery.AnnotationDescription: "Billing of the platform",
+			workloaddiscovery.AnnotationUri:         "https://wiki.example.com/billing",
+		}))).Should(Succeed())
+
+		features := discover()
+
+		Expect(features).Should(HaveLen(1))
+		Expect(features[0].Namespace).Should(Equal(namespace))
+		Expect(features[0].Name).Should(Equal("billing"))
+		Expect(features[0].Spec).Should(Equal(InstalledFeatureSpec{
+			Group:       &InstalledFeatureRef{Namespace: "platform", Name: "business"},
+			Kind:        "billing",
+			Version:     "1.2.0",
+			Provider:    "Team A",
+			Description: "Billing of the platform",
+			Uri:         "https://wiki.example.com/billing",
+			DependsOn: []InstalledFeatureRef{
+				{Namespace: "postgres", Name: "postgres-operator", Version: ">=1.5.0,<2"},
+				{Namespace: namespace, Name: "accounts"},
+			},
+			Conflicts: []InstalledFeatureRef{{Namespace: namespace, Name: "legacy-billing"}},
+		}))
+		Expect(features[0].OwnerReferences).Should(HaveLen(1))
+		Expect(features[0].OwnerReferences[0].APIVersion).Should(Equal("apps/v1"))
+		Expect(features[0].OwnerReferences[0].Kind).Should(Equal("Deployment"))
+		Expect(features[0].OwnerReferences[0].Name).Should(Equal("billing"))
+		Expect(*features[0].OwnerReferences[0].Controller).Should(BeTrue())
+	})
+
+	It("should register statefulsets and daemonsets and ignore workloads without kind annotation", func() {
+		Expect(workloads.Create(ctx, &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "db", Annotations: map[string]string{
+				workloaddiscovery.AnnotationKind: "database",
+				workloaddiscovery.AnnotationName: "billing-db",
+			}},
+		})).Should(Succeed())
+		Expect(workloads.Create(ctx, &appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "agent", Annotations: map[string]string{
+				workloaddiscovery.AnnotationKind: "agent",
+			}},
+		})).Should(Succeed())
+		Expect(workloads.Create(ctx, deployment(namespace, "plain", nil))).Should(Succeed())
+
+		features := discover()
+
+		Expect(features).Should(HaveLen(2))
+		Expect(features[0].Name).Should(Equal("agent"))
+		Expect(features[0].OwnerReferences[0].Kind).Should(Equal("DaemonSet"))
+		Expect(features[1].Name).Should(Equal("billing-db"))
+		Expect(features[1].OwnerReferences[0].Kind).Should(Equal("StatefulSet"))
+	})
+
+	It("should take the version from the version label or the image tag", func() {
+		labeled := deployment(namespace, "labeled", map[string]string{workloaddiscovery.AnnotationKind: "labeled"})
+		labeled.Labels = map[string]string{workloaddiscovery.LabelVersion: "3.0.0"}
+		Expect(workloads.Create(ctx, labeled)).Should(Succeed())
+		Expect(workloads.Create(ctx, deployment(namespace, "tagged", map[string]string{workloaddiscovery.AnnotationKind: "tagged"}))).Should(Succeed())
+
+		features := discover()
+
+		Expect(features[0].Spec.Version).Should(Equal("3.0.0"))
+		Expect(features[1].Spec.Version).Should(Equal("2.4.1"))
+	})
+
+	It("should keep the version of the feature in sync and delete it with the workload", func() {
+		store := controllers.NewOcpClientMemory()
+		engine := discovery.NewEngine(store, logf.NullLogger{}, "default", 0)
+		engine.Add(sut)
+		lookup := types.NamespacedName{Namespace: namespace, Name: "billing"}
+
+		billing := deployment(namespace, "billing", map[string]string{
+			workloaddiscovery.AnnotationKind:    "billing",
+			workloaddiscovery.AnnotationVersion: "1.2.0",
+		})
+		Expect(workloads.Create(ctx, billing)).Should(Succeed())
+		Expect(engine.Sync(ctx, sut).Created).Should(Equal(1))
+
+		billing.Annotations[workloaddiscovery.AnnotationVersion] = "1.3.0"
+		Expect(workloads.Update(ctx, billing)).Should(Succeed())
+		Expect(engine.Sync(ctx, sut).Updated).Should(Equal(1))
+
+		feature, err := store.LoadInstalledFeature(ctx, lookup)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(feature.Spec.Version).Should(Equal("1.3.0"))
+
+		Expect(workloads.Delete(ctx, billing)).Should(Succeed())
+		Expect(engine.Sync(ctx, sut).Deleted).Should(Equal(1))
+
+		_, err = store.LoadInstalledFeature(ctx, lookup)
+		Expect(errors.IsNotFound(err)).Should(BeTrue())
+	})
+
+	It("should fail the feature of a workload with invalid references", func() {
+		Expect(workloads.Create(ctx, deployment(namespace, "billing", map[string]string{
+			workloaddiscovery.AnnotationKind:    "billing",
+			workloaddiscovery.AnnotationVersion: "1.2.0",
+			workloaddiscovery.AnnotationDepends: "postgres-operator@>= 1.5.0 kind:database",
+		}))).Should(Succeed())
+
+		features := discover()
+
+		Expect(features).Should(HaveLen(1))
+		Expect(features[0].Spec.DependsOn).Should(BeEmpty())
+		Expect(features[0].Annotations).Should(HaveKeyWithValue(AnnotationSourcePhase, PhaseFailed))
+		Expect(features[0].Annotations[AnnotationSourceMessage]).Should(ContainSubstring(workloaddiscovery.AnnotationDepends))
+	})
+})
+
+func deployment(namespace string, name string, annotations map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Annotations: annotations},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: name, Image: "registry.example.com:5000/team-a/" + name + ":2.4.1"}},
+				},
+			},
+		},
+	}
+}
@@ -3,6 +3,7 @@ module github.com/klenkes74/k8s-installed-features-catalogue
 go 1.13
 
 require (
+	github.com/evanphx/json-patch v4.5.0+incompatible
 	github.com/go-logr/logr v0.1.0
 	github.com/golang/mock v1.4.4
 	github.com/google/uuid v1.2.0
@@ -10,7 +11,12 @@ require (
 	github.com/onsi/gomega v1.11.0
 	github.com/ory/go-acc v0.2.6 // indirect
 	github.com/pborman/uuid v1.2.1
+	github.com/prometheus/client_golang v1.0.0
+	github.com/prometheus/common v0.4.1
+	k8s.io/api v0.18.6
+	k8s.io/apiextensions-apiserver v0.18.6
 	k8s.io/apimachinery v0.18.6
 	k8s.io/client-go v0.18.6
 	sigs.k8s.io/controller-runtime v0.6.2
+	sigs.k8s.io/yaml v1.2.0
 )
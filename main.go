@@ -19,16 +19,39 @@ package main
 import (
 	"flag"
 	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/admission"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/apiserverdiscovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/argocddiscovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/controlplanediscovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/crddiscovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/discovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/featurerequirement"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/fluxdiscovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/helmdiscovery"
 	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/installedfeature"
 	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/installedfeaturegroup"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/nfddiscovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/olmdiscovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/platformdiscovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/upgradeplan"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/workloaddiscovery"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/catalogue"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/compatibility"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/consistency"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/versions"
 	"os"
+	"strings"
+	"time"
 
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	k8sdiscovery "k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
 	// +kubebuilder:scaffold:imports
@@ -37,26 +60,97 @@ import (
 var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
+
+	// discoverers contains all discovery sources, they are enabled with --discovery-sources.
+	discoverers = discovery.NewRegistry()
+	// apiserverMetricsURL is the URL the apiserver source reads the feature gates from, see --apiserver-metrics-url.
+	apiserverMetricsURL string
 )
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
 
 	utilruntime.Must(featuresv1alpha1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
+
+	utilruntime.Must(discoverers.Register(crddiscovery.Source, crddiscovery.New))
+	utilruntime.Must(discoverers.Register(olmdiscovery.Source, olmdiscovery.New))
+	utilruntime.Must(discoverers.Register(helmdiscovery.Source, helmdiscovery.New))
+	utilruntime.Must(discoverers.Register(workloaddiscovery.Source, workloaddiscovery.New))
+	utilruntime.Must(discoverers.Register(argocddiscovery.Source, argocddiscovery.New))
+	utilruntime.Must(discoverers.Register(fluxdiscovery.Source, fluxdiscovery.New))
+	utilruntime.Must(discoverers.Register(platformdiscovery.Source, platformdiscovery.New))
+	utilruntime.Must(discoverers.Register(controlplanediscovery.Source, controlplanediscovery.New))
+	utilruntime.Must(discoverers.Register(nfddiscovery.Source, nfddiscovery.New))
+	utilruntime.Must(discoverers.Register(apiserverdiscovery.Source, func(mgr ctrl.Manager) (discovery.Discoverer, error) {
+		return apiserverdiscovery.New(mgr, apiserverMetricsURL)
+	}))
 }
 
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var resyncInterval time.Duration
+	var resyncRepair bool
+	var maxConcurrentReconciles int
+	var discoverySources string
+	var discoveryNamespace string
+	var discoveryInterval time.Duration
+	var upgradeTarget string
+	var apiRemovals string
+	var requiresWebhook bool
+	var requiresDefaultPolicy string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&resyncInterval, "resync-interval", 10*time.Minute,
+		"The interval of the consistency check of the status of all features and groups. 0 disables the check.")
+	flag.BoolVar(&resyncRepair, "resync-repair", true,
+		"Repair the status drifts found by the consistency check instead of only reporting them.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"The number of features and groups reconciled in parallel by each controller.")
+	flag.StringVar(&discoverySources, "discovery-sources", "",
+		"Comma separated list of the enabled discovery sources (known: "+strings.Join(discoverers.Names(), ", ")+"). "+
+			"Empty disables the discovery.")
+	flag.StringVar(&discoveryNamespace, "discovery-namespace", "default",
+		"The namespace of the discovered features.")
+	flag.StringVar(&apiserverMetricsURL, "apiserver-metrics-url", "",
+		"The URL of the metrics the apiserver discovery source reads the feature gates from. "+
+			"A path (e.g. /metrics) is read from the API server. Empty disables the feature gates.")
+	flag.DurationVar(&discoveryInterval, "discovery-interval", 10*time.Minute,
+		"The interval of the sync of all discovery sources. 0 only syncs on start and on changes.")
+	flag.StringVar(&upgradeTarget, "upgrade-target-version", "",
+		"The Kubernetes version of the next upgrade. Features get the condition UpgradeReady. Empty disables the check.")
+	flag.StringVar(&apiRemovals, "api-removals", "",
+		"A YAML file with API removals added to the built-in table checked for the upgrade.")
+	flag.BoolVar(&requiresWebhook, "requires-webhook", false,
+		"Serve the webhook checking the features required by Pods and Deployments. Needs the webhook certificates.")
+	flag.StringVar(&requiresDefaultPolicy, "requires-default-policy", admission.PolicyWarn,
+		"The policy of namespaces without label "+featuresv1alpha1.LabelRequiresPolicy+": enforce, warn or off.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
 
+	if upgradeTarget != "" {
+		if _, err := versions.Parse(upgradeTarget); err != nil {
+			setupLog.Error(err, "invalid upgrade target version", "version", upgradeTarget)
+			os.Exit(1)
+		}
+	}
+	switch requiresDefaultPolicy {
+	case admission.PolicyEnforce, admission.PolicyWarn, admission.PolicyOff:
+	default:
+		setupLog.Error(nil, "invalid requires default policy", "policy", requiresDefaultPolicy)
+		os.Exit(1)
+	}
+	removals, err := compatibility.LoadRemovals(apiRemovals)
+	if err != nil {
+		setupLog.Error(err, "unable to load the API removals")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
@@ -73,20 +167,113 @@ func main() {
 		Client: &controllers.OcpClientProd{Client: mgr.GetClient()},
 		Log:    ctrl.Log.WithName("controllers").WithName("InstalledFeatureGroup"),
 		Scheme: mgr.GetScheme(),
+
+		MaxConcurrentReconciles: maxConcurrentReconciles,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "InstalledFeatureGroup")
 		os.Exit(1)
 	}
+	apis, err := k8sdiscovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create discovery client")
+		os.Exit(1)
+	}
 	if err = (&installedfeature.Reconciler{
 		Client: &controllers.OcpClientProd{Client: mgr.GetClient()},
 		Log:    ctrl.Log.WithName("controllers").WithName("InstalledFeature"),
 		Scheme: mgr.GetScheme(),
+		APIs:   controllers.APICheckerProd{Client: mgr.GetClient(), Discovery: apis},
+
+		Workloads:  controllers.WorkloadCheckerProd{Client: mgr.GetClient()},
+		Kubernetes: apis,
+
+		UpgradeTarget: upgradeTarget,
+		Removals:      removals,
+
+		MaxConcurrentReconciles: maxConcurrentReconciles,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "InstalledFeatures")
 		os.Exit(1)
 	}
+	if err = (&upgradeplan.Reconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("UpgradePlan"),
+		Scheme: mgr.GetScheme(),
+
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "UpgradePlan")
+		os.Exit(1)
+	}
+	if err = (&featurerequirement.Reconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("FeatureRequirement"),
+		Scheme: mgr.GetScheme(),
+
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "FeatureRequirement")
+		os.Exit(1)
+	}
+	if requiresWebhook {
+		mgr.GetWebhookServer().Register(admission.Path, &webhook.Admission{Handler: &admission.RequiresValidator{
+			Client:        mgr.GetClient(),
+			Log:           ctrl.Log.WithName("webhooks").WithName("Requires"),
+			DefaultPolicy: requiresDefaultPolicy,
+		}})
+	}
 	// +kubebuilder:scaffold:builder
 
+	checker := &consistency.Checker{
+		Client: &controllers.OcpClientProd{Client: mgr.GetClient()},
+		Log:    ctrl.Log.WithName("consistency"),
+	}
+	if err = mgr.AddMetricsExtraHandler("/consistency", consistency.Handler(checker, mgr.Elected())); err != nil {
+		setupLog.Error(err, "unable to add the consistency endpoint")
+		os.Exit(1)
+	}
+	if err = mgr.AddMetricsExtraHandler("/order", catalogue.OrderHandler(mgr.GetClient())); err != nil {
+		setupLog.Error(err, "unable to add the order endpoint")
+		os.Exit(1)
+	}
+	if resyncInterval > 0 {
+		if err = mgr.Add(&consistency.Job{Checker: checker, Interval: resyncInterval, Repair: resyncRepair}); err != nil {
+			setupLog.Error(err, "unable to add the consistency check")
+			os.Exit(1)
+		}
+	}
+
+	if discoverySources != "" {
+		engine := discovery.NewEngine(&controllers.OcpClientProd{Client: mgr.GetClient()}, ctrl.Log.WithName("discovery"),
+			discoveryNamespace, discoveryInterval)
+
+		for _, name := range strings.Split(discoverySources, ",") {
+			discoverer, err := discoverers.New(strings.TrimSpace(name), mgr)
+			if err != nil {
+				setupLog.Error(err, "unable to create discovery source", "source", name)
+				os.Exit(1)
+			}
+			engine.Add(discoverer)
+
+			if watcher, ok := discoverer.(discovery.Watcher); ok {
+				source := discoverer.Name()
+				if err = watcher.Watch(mgr, func() { engine.Trigger(source) }); err != nil {
+					setupLog.Error(err, "unable to watch discovery source", "source", source)
+					os.Exit(1)
+				}
+			}
+		}
+
+		if err = mgr.Add(engine); err != nil {
+			setupLog.Error(err, "unable to add the discovery")
+			os.Exit(1)
+		}
+		if err = mgr.AddMetricsExtraHandler("/discovery", discovery.Handler(engine)); err != nil {
+			setupLog.Error(err, "unable to add the discovery endpoint")
+			os.Exit(1)
+		}
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
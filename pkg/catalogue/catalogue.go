@@ -0,0 +1,277 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package catalogue provides a read-only view of all installed features and feature groups of a cluster. It indexes
+// the dependencies between the features so tools can walk the dependency graph in both directions.
+package catalogue
+
+import (
+	"context"
+	"sort"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Catalogue is an index of features and groups.
+type Catalogue struct {
+	features   map[types.NamespacedName]*featuresv1alpha1.InstalledFeature
+	groups     map[types.NamespacedName]*featuresv1alpha1.InstalledFeatureGroup
+	dependents map[types.NamespacedName][]featuresv1alpha1.InstalledFeatureRef
+}
+
+// Node is a single feature within a dependency tree.
+type Node struct {
+	Ref featuresv1alpha1.InstalledFeatureRef
+	// Feature is the installed feature or nil if the feature is not installed.
+	Feature *featuresv1alpha1.InstalledFeature
+	// Cycle is set when the feature is already an ancestor of this node. The children are not expanded then.
+	Cycle bool
+	// Children are the dependencies of this feature.
+	Children []*Node
+}
+
+// New creates the catalogue for the given features and groups.
+func New(features []featuresv1alpha1.InstalledFeature, groups []featuresv1alpha1.InstalledFeatureGroup) *Catalogue {
+	result := &Catalogue{
+		features:   make(map[types.NamespacedName]*featuresv1alpha1.InstalledFeature, len(features)),
+		groups:     make(map[types.NamespacedName]*featuresv1alpha1.InstalledFeatureGroup, len(groups)),
+		dependents: make(map[types.NamespacedName][]featuresv1alpha1.InstalledFeatureRef),
+	}
+
+	for i := range features {
+		feature := &features[i]
+		result.features[types.NamespacedName{Namespace: feature.Namespace, Name: feature.Name}] = feature
+	}
+
+	for i := range groups {
+		group := &groups[i]
+		result.groups[types.NamespacedName{Namespace: group.Namespace, Name: group.Name}] = group
+	}
+
+	for _, feature := range result.Features() {
+		for _, dependency := range feature.Spec.DependsOn {
+			key := Key(dependency)
+			result.dependents[key] = append(result.dependents[key], RefOf(feature))
+		}
+	}
+
+	return result
+}
+
+// Load reads all features and groups via the client and creates the catalogue of them.
+func Load(ctx context.Context, reader client.Reader, opts ...client.ListOption) (*Catalogue, error) {
+	features := &featuresv1alpha1.InstalledFeatureList{}
+	if err := reader.List(ctx, features, opts...); err != nil {
+		return nil, err
+	}
+
+	groups := &featuresv1alpha1.InstalledFeatureGroupList{}
+	if err := reader.List(ctx, groups, opts...); err != nil {
+		return nil, err
+	}
+
+	return New(features.Items, groups.Items), nil
+}
+
+// Key returns the lookup key of a reference.
+func Key(ref featuresv1alpha1.InstalledFeatureRef) types.NamespacedName {
+	return types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
+}
+
+// RefOf returns the reference pointing to the given feature.
+func RefOf(feature *featuresv1alpha1.InstalledFeature) featuresv1alpha1.InstalledFeatureRef {
+	return featuresv1alpha1.InstalledFeatureRef{Namespace: feature.Namespace, Name: feature.Name}
+}
+
+// Feature returns the referenced feature or nil if it is not installed.
+func (c *Catalogue) Feature(ref featuresv1alpha1.InstalledFeatureRef) *featuresv1alpha1.InstalledFeature {
+	return c.features[Key(ref)]
+}
+
+// Group returns the referenced group or nil if it is not installed.
+func (c *Catalogue) Group(ref featuresv1alpha1.InstalledFeatureRef) *featuresv1alpha1.InstalledFeatureGroup {
+	return c.groups[Key(ref)]
+}
+
+// Features returns all features sorted by namespace and name.
+func (c *Catalogue) Features() []*featuresv1alpha1.InstalledFeature {
+	result := make([]*featuresv1alpha1.InstalledFeature, 0, len(c.features))
+	for _, feature := range c.features {
+		result = append(result, feature)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return Less(RefOf(result[i]), RefOf(result[j]))
+	})
+
+	return result
+}
+
+// Groups returns all groups sorted by namespace and name.
+func (c *Catalogue) Groups() []*featuresv1alpha1.InstalledFeatureGroup {
+	result := make([]*featuresv1alpha1.InstalledFeatureGroup, 0, len(c.groups))
+	for _, group := range c.groups {
+		result = append(result, group)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+// Dependencies returns the features the referenced feature depends on. It returns nil if the feature is unknown.
+func (c *Catalogue) Dependencies(ref featuresv1alpha1.InstalledFeatureRef) []featuresv1alpha1.InstalledFeatureRef {
+	feature := c.Feature(ref)
+	if feature == nil {
+		return nil
+	}
+
+	return feature.Spec.DependsOn
+}
+
+// Dependents returns all features that declare a dependency on the referenced feature. The dependents are computed
+// from the specs of all features and not from the status of the referenced feature.
+func (c *Catalogue) Dependents(ref featuresv1alpha1.InstalledFeatureRef) []featuresv1alpha1.InstalledFeatureRef {
+	return c.dependents[Key(ref)]
+}
+
+// Tree returns the dependency tree of the referenced feature. Cycles are detected and not expanded.
+func (c *Catalogue) Tree(ref featuresv1alpha1.InstalledFeatureRef) *Node {
+	return c.tree(ref, make(map[types.NamespacedName]bool))
+}
+
+func (c *Catalogue) tree(ref featuresv1alpha1.InstalledFeatureRef, ancestors map[types.NamespacedName]bool) *Node {
+	key := Key(ref)
+	result := &Node{
+		Ref:     ref,
+		Feature: c.features[key],
+	}
+
+	if ancestors[key] {
+		result.Cycle = true
+		return result
+	}
+
+	ancestors[key] = true
+	for _, dependency := range c.Dependencies(ref) {
+		result.Children = append(result.Children, c.tree(dependency, ancestors))
+	}
+	delete(ancestors, key)
+
+	return result
+}
+
+// Less orders references by namespace and name.
+func Less(a, b featuresv1alpha1.InstalledFeatureRef) bool {
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+
+	return a.Name < b.Name
+}
+
+// Cycles returns all dependency cycles of the catalogue. Every cycle is returned once as the features forming it,
+// sorted by namespace and name. Dependencies on features not in the catalogue are ignored.
+func (c *Catalogue) Cycles() [][]featuresv1alpha1.InstalledFeatureRef {
+	s := &sccSearch{
+		catalogue: c,
+		index:     make(map[types.NamespacedName]int),
+		lowlink:   make(map[types.NamespacedName]int),
+		onStack:   make(map[types.NamespacedName]bool),
+	}
+
+	for _, feature := range c.Features() {
+		if _, visited := s.index[Key(RefOf(feature))]; !visited {
+			s.connect(RefOf(feature))
+		}
+	}
+
+	sort.Slice(s.cycles, func(i, j int) bool {
+		return Less(s.cycles[i][0], s.cycles[j][0])
+	})
+
+	return s.cycles
+}
+
+// sccSearch finds the strongly connected components of the dependency graph (Tarjan's algorithm).
+type sccSearch struct {
+	catalogue *Catalogue
+	counter   int
+	index     map[types.NamespacedName]int
+	lowlink   map[types.NamespacedName]int
+	onStack   map[types.NamespacedName]bool
+	stack     []featuresv1alpha1.InstalledFeatureRef
+	cycles    [][]featuresv1alpha1.InstalledFeatureRef
+}
+
+func (s *sccSearch) connect(ref featuresv1alpha1.InstalledFeatureRef) {
+	key := Key(ref)
+	s.index[key] = s.counter
+	s.lowlink[key] = s.counter
+	s.counter++
+	s.stack = append(s.stack, ref)
+	s.onStack[key] = true
+
+	selfReference := false
+	for _, dependency := range s.catalogue.Dependencies(ref) {
+		depKey := Key(dependency)
+		if s.catalogue.features[depKey] == nil {
+			continue
+		}
+		if depKey == key {
+			selfReference = true
+		}
+
+		if _, visited := s.index[depKey]; !visited {
+			s.connect(RefOf(s.catalogue.features[depKey]))
+			if s.lowlink[depKey] < s.lowlink[key] {
+				s.lowlink[key] = s.lowlink[depKey]
+			}
+		} else if s.onStack[depKey] && s.index[depKey] < s.lowlink[key] {
+			s.lowlink[key] = s.index[depKey]
+		}
+	}
+
+	if s.lowlink[key] != s.index[key] {
+		return
+	}
+
+	component := make([]featuresv1alpha1.InstalledFeatureRef, 0)
+	for {
+		member := s.stack[len(s.stack)-1]
+		s.stack = s.stack[:len(s.stack)-1]
+		s.onStack[Key(member)] = false
+		component = append(component, member)
+
+		if Key(member) == key {
+			break
+		}
+	}
+
+	if len(component) > 1 || selfReference {
+		sort.Slice(component, func(i, j int) bool {
+			return Less(component[i], component[j])
+		})
+		s.cycles = append(s.cycles, component)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalogue_test
+
+import (
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/pkg/catalogue"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const namespace = "default"
+
+func createIFT(name string, dependsOn ...string) featuresv1alpha1.InstalledFeature {
+	result := featuresv1alpha1.InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: featuresv1alpha1.InstalledFeatureSpec{
+			Kind:    name,
+			Version: "1.0.0",
+		},
+	}
+
+	for _, dependency := range dependsOn {
+		result.Spec.DependsOn = append(result.Spec.DependsOn, ref(dependency))
+	}
+
+	return result
+}
+
+func ref(name string) featuresv1alpha1.InstalledFeatureRef {
+	return featuresv1alpha1.InstalledFeatureRef{Namespace: namespace, Name: name}
+}
+
+var _ = Describe("Catalogue", func() {
+	var sut *Catalogue
+
+	BeforeEach(func() {
+		sut = New([]featuresv1alpha1.InstalledFeature{
+			createIFT("app", "database", "cert-manager"),
+			createIFT("database", "storage"),
+			createIFT("cert-manager"),
+			createIFT("monitoring", "database"),
+			createIFT("cycle-a", "cycle-b"),
+			createIFT("cycle-b", "cycle-a"),
+			createIFT("self", "self"),
+		}, nil)
+	})
+
+	It("should list the features sorted", func() {
+		features := sut.Features()
+
+		Expect(features).Should(HaveLen(7))
+		Expect(features[0].Name).Should(Equal("app"))
+		Expect(features[6].Name).Should(Equal("self"))
+	})
+
+	It("should return nil for unknown features", func() {
+		Expect(sut.Feature(ref("storage"))).Should(BeNil())
+		Expect(sut.Dependencies(ref("storage"))).Should(BeNil())
+	})
+
+	It("should list the dependents of a feature", func() {
+		Expect(sut.Dependents(ref("database"))).Should(Equal([]featuresv1alpha1.InstalledFeatureRef{
+			ref("app"), ref("monitoring"),
+		}))
+		Expect(sut.Dependents(ref("storage"))).Should(Equal([]featuresv1alpha1.InstalledFeatureRef{
+			ref("database"),
+		}))
+		Expect(sut.Dependents(ref("app"))).Should(BeEmpty())
+	})
+
+	It("should build the dependency tree including missing features", func() {
+		tree := sut.Tree(ref("app"))
+
+		Expect(tree.Feature).ShouldNot(BeNil())
+		Expect(tree.Children).Should(HaveLen(2))
+		Expect(tree.Children[0].Ref).Should(Equal(ref("database")))
+		Expect(tree.Children[0].Children).Should(HaveLen(1))
+		Expect(tree.Children[0].Children[0].Feature).Should(BeNil())
+		Expect(tree.Children[1].Ref).Should(Equal(ref("cert-manager")))
+		Expect(tree.Children[1].Children).Should(BeEmpty())
+	})
+
+	It("should not expand cycles in the dependency tree", func() {
+		tree := sut.Tree(ref("cycle-a"))
+
+		Expect(tree.Cycle).Should(BeFalse())
+		Expect(tree.Children).Should(HaveLen(1))
+		Expect(tree.Children[0].Children).Should(HaveLen(1))
+		Expect(tree.Children[0].Children[0].Ref).Should(Equal(ref("cycle-a")))
+		Expect(tree.Children[0].Children[0].Cycle).Should(BeTrue())
+		Expect(tree.Children[0].Children[0].Children).Should(BeEmpty())
+	})
+
+	It("should find all dependency cycles", func() {
+		Expect(sut.Cycles()).Should(Equal([][]featuresv1alpha1.InstalledFeatureRef{
+			{ref("cycle-a"), ref("cycle-b")},
+			{ref("self")},
+		}))
+	})
+})
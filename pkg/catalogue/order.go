@@ -0,0 +1,248 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalogue
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Order is the order to install or tear down the features and groups of a catalogue in.
+type Order struct {
+	// Teardown is set when the waves are in the order to remove the features.
+	Teardown bool `json:"teardown,omitempty"`
+	// Waves are applied one after another. The features of a wave only depend on features of earlier waves when
+	// installing, so they may be applied in parallel.
+	Waves []Wave `json:"waves"`
+	// Cycles are the dependency cycles of the catalogue. Their features are not part of any wave.
+	Cycles [][]featuresv1alpha1.InstalledFeatureRef `json:"cycles,omitempty"`
+	// Blocked are the features depending on a cycle. They are not part of any wave either.
+	Blocked []featuresv1alpha1.InstalledFeatureRef `json:"blocked,omitempty"`
+}
+
+// Wave is a set of features and groups that may be applied in parallel.
+type Wave struct {
+	Features []featuresv1alpha1.InstalledFeatureRef `json:"features,omitempty"`
+	// Groups are the groups whose first member is installed in this wave. Groups without members are in the first
+	// wave.
+	Groups []featuresv1alpha1.InstalledFeatureRef `json:"groups,omitempty"`
+}
+
+// InstallOrder sorts the features topologically by their dependencies. Every feature is put into the first wave after
+// all its dependencies. Dependencies on features not in the catalogue are ignored.
+func (c *Catalogue) InstallOrder() Order {
+	result := Order{Cycles: c.Cycles()}
+
+	inCycle := make(map[types.NamespacedName]bool)
+	for _, cycle := range result.Cycles {
+		for _, ref := range cycle {
+			inCycle[Key(ref)] = true
+		}
+	}
+
+	wave := make(map[types.NamespacedName]int, len(c.features))
+	var place func(key types.NamespacedName) int
+	place = func(key types.NamespacedName) int {
+		if w, done := wave[key]; done {
+			return w
+		}
+		if inCycle[key] {
+			wave[key] = -1
+			return -1
+		}
+
+		w := 0
+		for _, dependency := range c.features[key].Spec.DependsOn {
+			depKey := Key(dependency)
+			if c.features[depKey] == nil {
+				continue
+			}
+
+			d := place(depKey)
+			if d < 0 {
+				w = -1
+				break
+			}
+			if d+1 > w {
+				w = d + 1
+			}
+		}
+
+		wave[key] = w
+		return w
+	}
+
+	first := make(map[types.NamespacedName]int)
+	for _, feature := range c.Features() {
+		ref := RefOf(feature)
+		w := place(Key(ref))
+		if w < 0 {
+			if !inCycle[Key(ref)] {
+				result.Blocked = append(result.Blocked, ref)
+			}
+			continue
+		}
+
+		for len(result.Waves) <= w {
+			result.Waves = append(result.Waves, Wave{})
+		}
+		result.Waves[w].Features = append(result.Waves[w].Features, ref)
+
+		if feature.Spec.Group != nil {
+			if f, found := first[Key(*feature.Spec.Group)]; !found || w < f {
+				first[Key(*feature.Spec.Group)] = w
+			}
+		}
+	}
+
+	for _, group := range c.Groups() {
+		if len(result.Waves) == 0 {
+			result.Waves = append(result.Waves, Wave{})
+		}
+
+		w := first[types.NamespacedName{Namespace: group.Namespace, Name: group.Name}]
+		result.Waves[w].Groups = append(result.Waves[w].Groups,
+			featuresv1alpha1.InstalledFeatureRef{Namespace: group.Namespace, Name: group.Name})
+	}
+
+	return result
+}
+
+// TeardownOrder is the install order with the waves reversed. The features of a wave are only depended on by features
+// of earlier waves, so they may be removed in parallel.
+func (c *Catalogue) TeardownOrder() Order {
+	result := c.InstallOrder()
+	result.Teardown = true
+
+	for i, j := 0, len(result.Waves)-1; i < j; i, j = i+1, j-1 {
+		result.Waves[i], result.Waves[j] = result.Waves[j], result.Waves[i]
+	}
+
+	return result
+}
+
+// The formats an order can be written in.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// Write writes the order in the given format.
+func (o Order) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatText:
+		return o.writeText(w)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(o)
+	case FormatYAML:
+		data, err := yaml.Marshal(o)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(data)
+		return err
+	default:
+		return fmt.Errorf("unknown format '%s', expected one of %s, %s or %s", format, FormatText, FormatJSON, FormatYAML)
+	}
+}
+
+// writeText writes the order as plain text, one line per wave.
+func (o Order) writeText(w io.Writer) error {
+	for i, wave := range o.Waves {
+		refs := make([]string, 0, len(wave.Features)+len(wave.Groups))
+		for _, ref := range wave.Features {
+			refs = append(refs, ref.String())
+		}
+		for _, ref := range wave.Groups {
+			refs = append(refs, fmt.Sprintf("group %s", ref))
+		}
+
+		if _, err := fmt.Fprintf(w, "wave %d: %s\n", i+1, strings.Join(refs, ", ")); err != nil {
+			return err
+		}
+	}
+
+	for _, cycle := range o.Cycles {
+		refs := make([]string, len(cycle))
+		for i, ref := range cycle {
+			refs[i] = ref.String()
+		}
+
+		if _, err := fmt.Fprintf(w, "cycle: %s\n", strings.Join(refs, ", ")); err != nil {
+			return err
+		}
+	}
+
+	for _, ref := range o.Blocked {
+		if _, err := fmt.Fprintf(w, "blocked by a cycle: %s\n", ref); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// OrderHandler serves the install order of the catalogue read via the reader. The query parameter teardown=true
+// selects the teardown order, format selects text, json (default) or yaml.
+func OrderHandler(reader client.Reader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "only GET is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		format := req.URL.Query().Get("format")
+		switch format {
+		case "", FormatJSON:
+			format = FormatJSON
+			w.Header().Set("Content-Type", "application/json")
+		case FormatYAML:
+			w.Header().Set("Content-Type", "application/yaml")
+		case FormatText:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		default:
+			http.Error(w, fmt.Sprintf("unknown format '%s'", format), http.StatusBadRequest)
+			return
+		}
+
+		c, err := Load(req.Context(), reader)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		order := c.InstallOrder()
+		if req.URL.Query().Get("teardown") == "true" {
+			order = c.TeardownOrder()
+		}
+
+		_ = order.Write(w, format)
+	})
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalogue_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/pkg/catalogue"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+)
+
+func member(feature featuresv1alpha1.InstalledFeature, group string) featuresv1alpha1.InstalledFeature {
+	feature.Spec.Group = &featuresv1alpha1.InstalledFeatureRef{Namespace: namespace, Name: group}
+	return feature
+}
+
+func createIFTG(name string) featuresv1alpha1.InstalledFeatureGroup {
+	return featuresv1alpha1.InstalledFeatureGroup{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+}
+
+var _ = Describe("Install order", func() {
+	var sut *Catalogue
+
+	BeforeEach(func() {
+		sut = New([]featuresv1alpha1.InstalledFeature{
+			member(createIFT("app", "database", "cert-manager"), "apps"),
+			createIFT("database", "storage"),
+			member(createIFT("cert-manager"), "platform"),
+			member(createIFT("monitoring", "database"), "platform"),
+			createIFT("cycle-a", "cycle-b"),
+			createIFT("cycle-b", "cycle-a"),
+			createIFT("on-cycle", "cycle-a"),
+			createIFT("on-blocked", "on-cycle", "database"),
+		}, []featuresv1alpha1.InstalledFeatureGroup{
+			createIFTG("apps"),
+			createIFTG("empty"),
+			createIFTG("platform"),
+		})
+	})
+
+	It("should put independent features into parallel waves", func() {
+		order := sut.InstallOrder()
+
+		Expect(order.Teardown).Should(BeFalse())
+		Expect(order.Waves).Should(Equal([]Wave{
+			{
+				Features: []featuresv1alpha1.InstalledFeatureRef{ref("cert-manager"), ref("database")},
+				Groups:   []featuresv1alpha1.InstalledFeatureRef{ref("empty"), ref("platform")},
+			},
+			{
+				Features: []featuresv1alpha1.InstalledFeatureRef{ref("app"), ref("monitoring")},
+				Groups:   []featuresv1alpha1.InstalledFeatureRef{ref("apps")},
+			},
+		}))
+	})
+
+	It("should report cycles and the features depending on them", func() {
+		order := sut.InstallOrder()
+
+		Expect(order.Cycles).Should(Equal([][]featuresv1alpha1.InstalledFeatureRef{{ref("cycle-a"), ref("cycle-b")}}))
+		Expect(order.Blocked).Should(Equal([]featuresv1alpha1.InstalledFeatureRef{ref("on-blocked"), ref("on-cycle")}))
+	})
+
+	It("should reverse the waves for the teardown", func() {
+		order := sut.TeardownOrder()
+
+		Expect(order.Teardown).Should(BeTrue())
+		Expect(order.Waves).Should(HaveLen(2))
+		Expect(order.Waves[0].Features).Should(Equal([]featuresv1alpha1.InstalledFeatureRef{ref("app"), ref("monitoring")}))
+		Expect(order.Waves[1].Features).Should(Equal([]featuresv1alpha1.InstalledFeatureRef{ref("cert-manager"), ref("database")}))
+	})
+
+	It("should write the order as text", func() {
+		out := &bytes.Buffer{}
+
+		Expect(sut.InstallOrder().Write(out, FormatText)).Should(Succeed())
+		Expect(out.String()).Should(Equal(`wave 1: default/cert-manager, default/database, group default/empty, group default/platform
+wave 2: default/app, default/monitoring, group default/apps
+cycle: default/cycle-a, default/cycle-b
+blocked by a cycle: default/on-blocked
+blocked by a cycle: default/on-cycle
+`))
+	})
+
+	It("should write the order as YAML", func() {
+		out := &bytes.Buffer{}
+
+		Expect(sut.InstallOrder().Write(out, FormatYAML)).Should(Succeed())
+		order := Order{}
+		Expect(yaml.Unmarshal(out.Bytes(), &order)).Should(Succeed())
+		Expect(order).Should(Equal(sut.InstallOrder()))
+	})
+
+	It("should reject unknown formats", func() {
+		Expect(sut.InstallOrder().Write(&bytes.Buffer{}, "xml")).ShouldNot(Succeed())
+	})
+
+	It("should serve the teardown order", func() {
+		scheme := runtime.NewScheme()
+		Expect(featuresv1alpha1.AddToScheme(scheme)).Should(Succeed())
+		app, database := createIFT("app", "database"), createIFT("database")
+		reader := fake.NewFakeClientWithScheme(scheme, &app, &database)
+
+		recorder := httptest.NewRecorder()
+		OrderHandler(reader).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/order?teardown=true", nil))
+
+		Expect(recorder.Code).Should(Equal(http.StatusOK))
+		order := Order{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &order)).Should(Succeed())
+		Expect(order).Should(Equal(Order{Teardown: true, Waves: []Wave{
+			{Features: []featuresv1alpha1.InstalledFeatureRef{ref("app")}},
+			{Features: []featuresv1alpha1.InstalledFeatureRef{ref("database")}},
+		}}))
+	})
+
+	It("should reject unknown formats when serving", func() {
+		recorder := httptest.NewRecorder()
+		OrderHandler(nil).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/order?format=xml", nil))
+
+		Expect(recorder.Code).Should(Equal(http.StatusBadRequest))
+	})
+})
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package compatibility checks features against a Kubernetes version, e.g. the version of the cluster or the target
+// version of an upgrade.
+package compatibility
+
+import (
+	"fmt"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/versions"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// HasKubernetesBounds checks if the feature restricts the Kubernetes versions it works with.
+func HasKubernetesBounds(feature *featuresv1alpha1.InstalledFeature) bool {
+	return feature.Spec.MinKubernetesVersion != "" || feature.Spec.MaxKubernetesVersion != ""
+}
+
+// Kubernetes checks the Kubernetes version against the bounds of the feature. It returns why the feature does not
+// work with the version or "" if it does. Invalid bounds make the feature incompatible, only an invalid Kubernetes
+// version is an error.
+func Kubernetes(feature *featuresv1alpha1.InstalledFeature, kubernetes string) (string, error) {
+	parsed, err := versions.Parse(kubernetes)
+	if err != nil {
+		return "", fmt.Errorf("invalid kubernetes version '%s': %v", kubernetes, err)
+	}
+
+	if bound := feature.Spec.MinKubernetesVersion; bound != "" {
+		cmp, err := compare(parsed, bound)
+		if err != nil {
+			return fmt.Sprintf("invalid min-kubernetes-version '%s'", bound), nil
+		}
+		if cmp < 0 {
+			return fmt.Sprintf("requires Kubernetes %s or newer, got %s", bound, kubernetes), nil
+		}
+	}
+
+	if bound := feature.Spec.MaxKubernetesVersion; bound != "" {
+		cmp, err := compare(parsed, bound)
+		if err != nil {
+			return fmt.Sprintf("invalid max-kubernetes-version '%s'", bound), nil
+		}
+		if cmp > 0 {
+			return fmt.Sprintf("supports Kubernetes up to %s, got %s", bound, kubernetes), nil
+		}
+	}
+
+	return "", nil
+}
+
+// compare compares the version with the bound only in the components given by the bound, e.g. "1.21.5" equals the
+// bound "1.21". It returns -1, 0 or 1 like strings.Compare.
+func compare(v *version.Version, bound string) (int, error) {
+	parsed, err := versions.Parse(bound)
+	if err != nil {
+		return 0, err
+	}
+
+	components := len(strings.Split(strings.TrimPrefix(strings.TrimSpace(bound), "v"), "."))
+	actual := []uint{v.Major(), v.Minor(), v.Patch()}
+	expected := []uint{parsed.Major(), parsed.Minor(), parsed.Patch()}
+	for i := 0; i < components && i < len(actual); i++ {
+		if actual[i] < expected[i] {
+			return -1, nil
+		}
+		if actual[i] > expected[i] {
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
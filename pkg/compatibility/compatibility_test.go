@@ -0,0 +1,64 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compatibility_test
+
+import (
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/pkg/compatibility"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Kubernetes compatibility", func() {
+	table.DescribeTable("checking the kubernetes version against the bounds",
+		func(min string, max string, kubernetes string, expected string) {
+			feature := &featuresv1alpha1.InstalledFeature{
+				Spec: featuresv1alpha1.InstalledFeatureSpec{MinKubernetesVersion: min, MaxKubernetesVersion: max},
+			}
+
+			reason, err := Kubernetes(feature, kubernetes)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(reason).Should(Equal(expected))
+		},
+		table.Entry("no bounds", "", "", "1.20.4", ""),
+		table.Entry("within bounds", "1.19", "1.21", "v1.20.4", ""),
+		table.Entry("lower bound", "1.19", "", "1.19.0", ""),
+		table.Entry("pre-release of the lower bound", "1.22", "", "v1.22.0-rc.0", ""),
+		table.Entry("too old", "1.19", "", "1.18.9", "requires Kubernetes 1.19 or newer, got 1.18.9"),
+		table.Entry("patch of the upper bound", "", "1.21", "v1.21.5+k3s1", ""),
+		table.Entry("too new", "", "1.21", "1.22.0", "supports Kubernetes up to 1.21, got 1.22.0"),
+		table.Entry("exact upper bound", "", "1.21.2", "1.21.3", "supports Kubernetes up to 1.21.2, got 1.21.3"),
+		table.Entry("invalid lower bound", "latest", "", "1.21.3", "invalid min-kubernetes-version 'latest'"),
+		table.Entry("invalid upper bound", "", "x", "1.21.3", "invalid max-kubernetes-version 'x'"),
+	)
+
+	It("should reject an invalid kubernetes version", func() {
+		_, err := Kubernetes(&featuresv1alpha1.InstalledFeature{}, "unknown")
+
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should tell if a feature has bounds", func() {
+		feature := &featuresv1alpha1.InstalledFeature{}
+		Expect(HasKubernetesBounds(feature)).Should(BeFalse())
+
+		feature.Spec.MaxKubernetesVersion = "1.21"
+		Expect(HasKubernetesBounds(feature)).Should(BeTrue())
+	})
+})
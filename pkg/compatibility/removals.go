@@ -0,0 +1,182 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compatibility
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/versions"
+	"sigs.k8s.io/yaml"
+)
+
+// Removal is the deprecation and removal of an API version of a kind by Kubernetes.
+type Removal struct {
+	featuresv1alpha1.ProvidedAPI `json:",inline"`
+	// Deprecated is the Kubernetes version deprecating the API, empty if unknown.
+	Deprecated string `json:"deprecated,omitempty"`
+	// Removed is the Kubernetes version no longer serving the API.
+	Removed string `json:"removed"`
+	// Replacement is the API to migrate to, empty if there is none.
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// Removals is a table of API removals.
+type Removals []Removal
+
+// ParseRemovals parses a table of API removals in the YAML format of the built-in table.
+func ParseRemovals(data []byte) (Removals, error) {
+	var result Removals
+	if err := yaml.UnmarshalStrict(data, &result); err != nil {
+		return nil, err
+	}
+
+	for _, removal := range result {
+		if _, err := versions.Parse(removal.Removed); err != nil {
+			return nil, fmt.Errorf("invalid removal of %s: %v", removal.ProvidedAPI, err)
+		}
+		if removal.Deprecated != "" {
+			if _, err := versions.Parse(removal.Deprecated); err != nil {
+				return nil, fmt.Errorf("invalid deprecation of %s: %v", removal.ProvidedAPI, err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// LoadRemovals reads the removals of the file and adds them to the built-in table. Entries of the file replace the
+// built-in entries of the same API. An empty filename returns the built-in table.
+func LoadRemovals(filename string) (Removals, error) {
+	if filename == "" {
+		return DefaultRemovals(), nil
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	updates, err := ParseRemovals(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	return DefaultRemovals().With(updates), nil
+}
+
+// DefaultRemovals returns the built-in table of API removals.
+func DefaultRemovals() Removals {
+	result, err := ParseRemovals([]byte(defaultRemovals))
+	if err != nil {
+		panic(err)
+	}
+
+	return result
+}
+
+// With returns the removals with the updates added. Updates replace the removals of the same API.
+func (r Removals) With(updates Removals) Removals {
+	index := make(map[featuresv1alpha1.ProvidedAPI]int, len(r))
+	result := append(Removals{}, r...)
+	for i, removal := range result {
+		index[removal.ProvidedAPI] = i
+	}
+
+	for _, update := range updates {
+		if i, ok := index[update.ProvidedAPI]; ok {
+			result[i] = update
+			continue
+		}
+
+		index[update.ProvidedAPI] = len(result)
+		result = append(result, update)
+	}
+
+	return result
+}
+
+// Find returns the removal of the API or nil.
+func (r Removals) Find(api featuresv1alpha1.ProvidedAPI) *Removal {
+	for i := range r {
+		if r[i].ProvidedAPI == api {
+			return &r[i]
+		}
+	}
+
+	return nil
+}
+
+// ConsumedAPIs checks the APIs consumed by the feature against the Kubernetes version. It returns the APIs removed in
+// the version and the APIs deprecated but still served, both sorted.
+func (r Removals) ConsumedAPIs(feature *featuresv1alpha1.InstalledFeature, kubernetes string) ([]string, []string, error) {
+	parsed, err := versions.Parse(kubernetes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid kubernetes version '%s': %v", kubernetes, err)
+	}
+
+	var removed, deprecated []string
+	for _, api := range feature.Spec.ConsumedAPIs {
+		removal := r.Find(api)
+		if removal == nil {
+			continue
+		}
+
+		if cmp, _ := compare(parsed, removal.Removed); cmp >= 0 {
+			removed = append(removed, fmt.Sprintf("%s is removed in Kubernetes %s%s", api, removal.Removed,
+				replacement(removal)))
+		} else if removal.Deprecated != "" {
+			if cmp, _ := compare(parsed, removal.Deprecated); cmp >= 0 {
+				deprecated = append(deprecated, fmt.Sprintf("%s is deprecated since Kubernetes %s and removed in %s%s",
+					api, removal.Deprecated, removal.Removed, replacement(removal)))
+			}
+		}
+	}
+
+	sort.Strings(removed)
+	sort.Strings(deprecated)
+	return removed, deprecated, nil
+}
+
+func replacement(removal *Removal) string {
+	if removal.Replacement == "" {
+		return ""
+	}
+
+	return ", use " + removal.Replacement
+}
+
+// Upgrade returns all reasons why the feature breaks when the cluster is upgraded to the Kubernetes version: the
+// version is out of the bounds of the feature or a consumed API is removed.
+func Upgrade(feature *featuresv1alpha1.InstalledFeature, kubernetes string, removals Removals) ([]string, error) {
+	reason, err := Kubernetes(feature, kubernetes)
+	if err != nil {
+		return nil, err
+	}
+
+	removed, _, err := removals.ConsumedAPIs(feature, kubernetes)
+	if err != nil {
+		return nil, err
+	}
+
+	if reason != "" {
+		return append([]string{reason}, removed...), nil
+	}
+	return removed, nil
+}
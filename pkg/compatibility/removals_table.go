@@ -0,0 +1,83 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compatibility
+
+// defaultRemovals is the built-in table of the API versions removed by Kubernetes. It is taken from the deprecated API
+// migration guide of Kubernetes, newer removals may be added with LoadRemovals.
+const defaultRemovals = `
+# Kubernetes 1.16
+- {group: extensions, version: v1beta1, kind: DaemonSet, deprecated: "1.9", removed: "1.16", replacement: apps/v1 DaemonSet}
+- {group: extensions, version: v1beta1, kind: Deployment, deprecated: "1.9", removed: "1.16", replacement: apps/v1 Deployment}
+- {group: extensions, version: v1beta1, kind: ReplicaSet, deprecated: "1.9", removed: "1.16", replacement: apps/v1 ReplicaSet}
+- {group: extensions, version: v1beta1, kind: NetworkPolicy, deprecated: "1.9", removed: "1.16", replacement: networking.k8s.io/v1 NetworkPolicy}
+- {group: extensions, version: v1beta1, kind: PodSecurityPolicy, deprecated: "1.11", removed: "1.16", replacement: policy/v1beta1 PodSecurityPolicy}
+- {group: apps, version: v1beta1, kind: Deployment, deprecated: "1.9", removed: "1.16", replacement: apps/v1 Deployment}
+- {group: apps, version: v1beta1, kind: StatefulSet, deprecated: "1.9", removed: "1.16", replacement: apps/v1 StatefulSet}
+- {group: apps, version: v1beta2, kind: DaemonSet, deprecated: "1.9", removed: "1.16", replacement: apps/v1 DaemonSet}
+- {group: apps, version: v1beta2, kind: Deployment, deprecated: "1.9", removed: "1.16", replacement: apps/v1 Deployment}
+- {group: apps, version: v1beta2, kind: ReplicaSet, deprecated: "1.9", removed: "1.16", replacement: apps/v1 ReplicaSet}
+- {group: apps, version: v1beta2, kind: StatefulSet, deprecated: "1.9", removed: "1.16", replacement: apps/v1 StatefulSet}
+
+# Kubernetes 1.22
+- {group: admissionregistration.k8s.io, version: v1beta1, kind: MutatingWebhookConfiguration, deprecated: "1.16", removed: "1.22", replacement: admissionregistration.k8s.io/v1 MutatingWebhookConfiguration}
+- {group: admissionregistration.k8s.io, version: v1beta1, kind: ValidatingWebhookConfiguration, deprecated: "1.16", removed: "1.22", replacement: admissionregistration.k8s.io/v1 ValidatingWebhookConfiguration}
+- {group: apiextensions.k8s.io, version: v1beta1, kind: CustomResourceDefinition, deprecated: "1.16", removed: "1.22", replacement: apiextensions.k8s.io/v1 CustomResourceDefinition}
+- {group: apiregistration.k8s.io, version: v1beta1, kind: APIService, deprecated: "1.19", removed: "1.22", replacement: apiregistration.k8s.io/v1 APIService}
+- {group: authentication.k8s.io, version: v1beta1, kind: TokenReview, deprecated: "1.19", removed: "1.22", replacement: authentication.k8s.io/v1 TokenReview}
+- {group: authorization.k8s.io, version: v1beta1, kind: LocalSubjectAccessReview, deprecated: "1.19", removed: "1.22", replacement: authorization.k8s.io/v1 LocalSubjectAccessReview}
+- {group: authorization.k8s.io, version: v1beta1, kind: SelfSubjectAccessReview, deprecated: "1.19", removed: "1.22", replacement: authorization.k8s.io/v1 SelfSubjectAccessReview}
+- {group: authorization.k8s.io, version: v1beta1, kind: SubjectAccessReview, deprecated: "1.19", removed: "1.22", replacement: authorization.k8s.io/v1 SubjectAccessReview}
+- {group: certificates.k8s.io, version: v1beta1, kind: CertificateSigningRequest, deprecated: "1.19", removed: "1.22", replacement: certificates.k8s.io/v1 CertificateSigningRequest}
+- {group: coordination.k8s.io, version: v1beta1, kind: Lease, deprecated: "1.19", removed: "1.22", replacement: coordination.k8s.io/v1 Lease}
+- {group: extensions, version: v1beta1, kind: Ingress, deprecated: "1.14", removed: "1.22", replacement: networking.k8s.io/v1 Ingress}
+- {group: networking.k8s.io, version: v1beta1, kind: Ingress, deprecated: "1.19", removed: "1.22", replacement: networking.k8s.io/v1 Ingress}
+- {group: networking.k8s.io, version: v1beta1, kind: IngressClass, deprecated: "1.19", removed: "1.22", replacement: networking.k8s.io/v1 IngressClass}
+- {group: rbac.authorization.k8s.io, version: v1beta1, kind: ClusterRole, deprecated: "1.17", removed: "1.22", replacement: rbac.authorization.k8s.io/v1 ClusterRole}
+- {group: rbac.authorization.k8s.io, version: v1beta1, kind: ClusterRoleBinding, deprecated: "1.17", removed: "1.22", replacement: rbac.authorization.k8s.io/v1 ClusterRoleBinding}
+- {group: rbac.authorization.k8s.io, version: v1beta1, kind: Role, deprecated: "1.17", removed: "1.22", replacement: rbac.authorization.k8s.io/v1 Role}
+- {group: rbac.authorization.k8s.io, version: v1beta1, kind: RoleBinding, deprecated: "1.17", removed: "1.22", replacement: rbac.authorization.k8s.io/v1 RoleBinding}
+- {group: scheduling.k8s.io, version: v1beta1, kind: PriorityClass, deprecated: "1.14", removed: "1.22", replacement: scheduling.k8s.io/v1 PriorityClass}
+- {group: storage.k8s.io, version: v1beta1, kind: CSIDriver, deprecated: "1.19", removed: "1.22", replacement: storage.k8s.io/v1 CSIDriver}
+- {group: storage.k8s.io, version: v1beta1, kind: CSINode, deprecated: "1.17", removed: "1.22", replacement: storage.k8s.io/v1 CSINode}
+- {group: storage.k8s.io, version: v1beta1, kind: StorageClass, deprecated: "1.19", removed: "1.22", replacement: storage.k8s.io/v1 StorageClass}
+- {group: storage.k8s.io, version: v1beta1, kind: VolumeAttachment, deprecated: "1.19", removed: "1.22", replacement: storage.k8s.io/v1 VolumeAttachment}
+
+# Kubernetes 1.25
+- {group: batch, version: v1beta1, kind: CronJob, deprecated: "1.21", removed: "1.25", replacement: batch/v1 CronJob}
+- {group: discovery.k8s.io, version: v1beta1, kind: EndpointSlice, deprecated: "1.21", removed: "1.25", replacement: discovery.k8s.io/v1 EndpointSlice}
+- {group: events.k8s.io, version: v1beta1, kind: Event, deprecated: "1.19", removed: "1.25", replacement: events.k8s.io/v1 Event}
+- {group: autoscaling, version: v2beta1, kind: HorizontalPodAutoscaler, deprecated: "1.22", removed: "1.25", replacement: autoscaling/v2 HorizontalPodAutoscaler}
+- {group: policy, version: v1beta1, kind: PodDisruptionBudget, deprecated: "1.21", removed: "1.25", replacement: policy/v1 PodDisruptionBudget}
+- {group: policy, version: v1beta1, kind: PodSecurityPolicy, deprecated: "1.21", removed: "1.25"}
+- {group: node.k8s.io, version: v1beta1, kind: RuntimeClass, deprecated: "1.20", removed: "1.25", replacement: node.k8s.io/v1 RuntimeClass}
+
+# Kubernetes 1.26
+- {group: flowcontrol.apiserver.k8s.io, version: v1beta1, kind: FlowSchema, deprecated: "1.23", removed: "1.26", replacement: flowcontrol.apiserver.k8s.io/v1beta3 FlowSchema}
+- {group: flowcontrol.apiserver.k8s.io, version: v1beta1, kind: PriorityLevelConfiguration, deprecated: "1.23", removed: "1.26", replacement: flowcontrol.apiserver.k8s.io/v1beta3 PriorityLevelConfiguration}
+- {group: autoscaling, version: v2beta2, kind: HorizontalPodAutoscaler, deprecated: "1.23", removed: "1.26", replacement: autoscaling/v2 HorizontalPodAutoscaler}
+
+# Kubernetes 1.27
+- {group: storage.k8s.io, version: v1beta1, kind: CSIStorageCapacity, deprecated: "1.24", removed: "1.27", replacement: storage.k8s.io/v1 CSIStorageCapacity}
+
+# Kubernetes 1.29
+- {group: flowcontrol.apiserver.k8s.io, version: v1beta2, kind: FlowSchema, deprecated: "1.26", removed: "1.29", replacement: flowcontrol.apiserver.k8s.io/v1 FlowSchema}
+- {group: flowcontrol.apiserver.k8s.io, version: v1beta2, kind: PriorityLevelConfiguration, deprecated: "1.26", removed: "1.29", replacement: flowcontrol.apiserver.k8s.io/v1 PriorityLevelConfiguration}
+
+# Kubernetes 1.32
+- {group: flowcontrol.apiserver.k8s.io, version: v1beta3, kind: FlowSchema, deprecated: "1.29", removed: "1.32", replacement: flowcontrol.apiserver.k8s.io/v1 FlowSchema}
+- {group: flowcontrol.apiserver.k8s.io, version: v1beta3, kind: PriorityLevelConfiguration, deprecated: "1.29", removed: "1.32", replacement: flowcontrol.apiserver.k8s.io/v1 PriorityLevelConfiguration}
+`
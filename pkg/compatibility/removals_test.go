@@ -0,0 +1,111 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compatibility_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/pkg/compatibility"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("API removals", func() {
+	ingress := featuresv1alpha1.ProvidedAPI{Group: "networking.k8s.io", Version: "v1beta1", Kind: "Ingress"}
+	cronJob := featuresv1alpha1.ProvidedAPI{Group: "batch", Version: "v1beta1", Kind: "CronJob"}
+	certificate := featuresv1alpha1.ProvidedAPI{Group: "cert-manager.io", Version: "v1alpha2", Kind: "Certificate"}
+
+	consuming := func(apis ...featuresv1alpha1.ProvidedAPI) *featuresv1alpha1.InstalledFeature {
+		return &featuresv1alpha1.InstalledFeature{Spec: featuresv1alpha1.InstalledFeatureSpec{ConsumedAPIs: apis}}
+	}
+
+	It("should parse the built-in table", func() {
+		removals := DefaultRemovals()
+
+		Expect(removals).ShouldNot(BeEmpty())
+		Expect(removals.Find(ingress)).Should(Equal(&Removal{
+			ProvidedAPI: ingress,
+			Deprecated:  "1.19",
+			Removed:     "1.22",
+			Replacement: "networking.k8s.io/v1 Ingress",
+		}))
+	})
+
+	It("should report removed and deprecated APIs", func() {
+		removed, deprecated, err := DefaultRemovals().ConsumedAPIs(consuming(ingress, cronJob, certificate), "v1.22.3")
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(removed).Should(Equal([]string{
+			"networking.k8s.io/v1beta1 Ingress is removed in Kubernetes 1.22, use networking.k8s.io/v1 Ingress",
+		}))
+		Expect(deprecated).Should(Equal([]string{
+			"batch/v1beta1 CronJob is deprecated since Kubernetes 1.21 and removed in 1.25, use batch/v1 CronJob",
+		}))
+	})
+
+	It("should not report APIs before their deprecation", func() {
+		removed, deprecated, err := DefaultRemovals().ConsumedAPIs(consuming(ingress, cronJob), "1.18")
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(removed).Should(BeEmpty())
+		Expect(deprecated).Should(BeEmpty())
+	})
+
+	It("should combine the Kubernetes bounds and the removed APIs for an upgrade", func() {
+		feature := consuming(ingress, cronJob)
+		feature.Spec.MaxKubernetesVersion = "1.24"
+
+		reasons, err := Upgrade(feature, "1.25", DefaultRemovals())
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(reasons).Should(Equal([]string{
+			"supports Kubernetes up to 1.24, got 1.25",
+			"batch/v1beta1 CronJob is removed in Kubernetes 1.25, use batch/v1 CronJob",
+			"networking.k8s.io/v1beta1 Ingress is removed in Kubernetes 1.22, use networking.k8s.io/v1 Ingress",
+		}))
+	})
+
+	It("should add and replace removals from a file", func() {
+		dir, err := ioutil.TempDir("", "removals")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		filename := filepath.Join(dir, "removals.yaml")
+		Expect(ioutil.WriteFile(filename, []byte(`
+- {group: cert-manager.io, version: v1alpha2, kind: Certificate, removed: "1.0"}
+- {group: networking.k8s.io, version: v1beta1, kind: Ingress, removed: "1.23"}
+`), 0600)).Should(Succeed())
+
+		removals, err := LoadRemovals(filename)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(removals).Should(HaveLen(len(DefaultRemovals()) + 1))
+		Expect(removals.Find(certificate).Removed).Should(Equal("1.0"))
+		Expect(removals.Find(ingress).Removed).Should(Equal("1.23"))
+	})
+
+	It("should reject invalid tables", func() {
+		_, err := ParseRemovals([]byte(`- {group: batch, version: v1beta1, kind: CronJob, removed: soon}`))
+		Expect(err).Should(HaveOccurred())
+
+		_, err = ParseRemovals([]byte(`- {group: batch, version: v1beta1, kind: CronJob, removed: "1.25", unknown: x}`))
+		Expect(err).Should(HaveOccurred())
+	})
+})
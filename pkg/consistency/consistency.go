@@ -0,0 +1,223 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package consistency checks the status of all features and groups against the status computed from their specs.
+// The relationships in the status (depending features, group members, missing dependencies and conflicts) are
+// denormalized: they are written by the reconciles of other objects. A lost patch or a downtime of the operator leaves
+// them wrong until the next change. The checker finds these drifts and optionally repairs them.
+package consistency
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/go-logr/logr"
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/resolver"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+const (
+	// KindFeature is the kind of drifts of InstalledFeatures.
+	KindFeature = "InstalledFeature"
+	// KindGroup is the kind of drifts of InstalledFeatureGroups.
+	KindGroup = "InstalledFeatureGroup"
+)
+
+// Drift is a status field differing from the field computed from the specs.
+type Drift struct {
+	Kind   string                               `json:"kind"`
+	Object featuresv1alpha1.InstalledFeatureRef `json:"object"`
+	// Field is the JSON name of the status field.
+	Field    string `json:"field"`
+	Actual   string `json:"actual"`
+	Expected string `json:"expected"`
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s %s: %s is %s, expected %s", d.Kind, d.Object, d.Field, d.Actual, d.Expected)
+}
+
+// Report is the result of a single consistency check.
+type Report struct {
+	Features int     `json:"features"`
+	Groups   int     `json:"groups"`
+	Drifts   []Drift `json:"drifts"`
+	// Repaired is the number of objects whose status has been repaired.
+	Repaired int `json:"repaired"`
+}
+
+// Check compares the status of all features and groups with the status computed from their specs. The drifts are
+// sorted by kind, namespace, name and field.
+func Check(features []featuresv1alpha1.InstalledFeature, groups []featuresv1alpha1.InstalledFeatureGroup) []Drift {
+	result := resolver.Resolve(features, groups)
+	drifts := make([]Drift, 0)
+
+	for i := range features {
+		actual, expected := features[i].Status, result.FeatureStatus(&features[i])
+		ref := featuresv1alpha1.InstalledFeatureRef{Namespace: features[i].Namespace, Name: features[i].Name}
+
+		drifts = compare(drifts, KindFeature, ref, "phase", actual.Phase, expected.Phase)
+		drifts = compare(drifts, KindFeature, ref, "message", actual.Message, expected.Message)
+		drifts = compare(drifts, KindFeature, ref, "missing-dependencies", actual.MissingDependencies, expected.MissingDependencies)
+		drifts = compare(drifts, KindFeature, ref, "conflicting-features", actual.ConflictingFeatures, expected.ConflictingFeatures)
+		drifts = compare(drifts, KindFeature, ref, "depending-features", actual.DependingFeatures, expected.DependingFeatures)
+	}
+
+	for i := range groups {
+		actual, expected := groups[i].Status, result.GroupStatus(&groups[i])
+		ref := featuresv1alpha1.InstalledFeatureRef{Namespace: groups[i].Namespace, Name: groups[i].Name}
+
+		drifts = compare(drifts, KindGroup, ref, "phase", actual.Phase, expected.Phase)
+		drifts = compare(drifts, KindGroup, ref, "message", actual.Message, expected.Message)
+		drifts = compare(drifts, KindGroup, ref, "features", actual.Features, expected.Features)
+	}
+
+	sort.SliceStable(drifts, func(i, j int) bool {
+		if drifts[i].Kind != drifts[j].Kind {
+			return drifts[i].Kind < drifts[j].Kind
+		}
+		if drifts[i].Object.Namespace != drifts[j].Object.Namespace {
+			return drifts[i].Object.Namespace < drifts[j].Object.Namespace
+		}
+		return drifts[i].Object.Name < drifts[j].Object.Name
+	})
+
+	return drifts
+}
+
+func compare(drifts []Drift, kind string, ref featuresv1alpha1.InstalledFeatureRef, field string, actual interface{}, expected interface{}) []Drift {
+	if equality.Semantic.DeepEqual(actual, expected) {
+		return drifts
+	}
+
+	return append(drifts, Drift{
+		Kind:     kind,
+		Object:   ref,
+		Field:    field,
+		Actual:   printable(actual),
+		Expected: printable(expected),
+	})
+}
+
+func printable(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil || string(data) == "null" {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return string(data)
+}
+
+// Checker loads all features and groups of the cluster and checks their status.
+type Checker struct {
+	Client controllers.OcpClient
+	Log    logr.Logger
+
+	// repairing serializes the repairs of the Job and the Handler.
+	repairing sync.Mutex
+}
+
+// Run checks the status of all features and groups. If repair is set, every object with drifts gets the computed
+// status. Objects deleted in the meantime are skipped.
+func (c *Checker) Run(ctx context.Context, repair bool) (*Report, error) {
+	if repair {
+		c.repairing.Lock()
+		defer c.repairing.Unlock()
+	}
+
+	features, err := c.Client.ListInstalledFeatures(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := c.Client.ListInstalledFeatureGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Features: len(features),
+		Groups:   len(groups),
+		Drifts:   Check(features, groups),
+	}
+
+	for _, drift := range report.Drifts {
+		c.Log.Info("status drift", "kind", drift.Kind, "object", drift.Object.String(), "field", drift.Field,
+			"actual", drift.Actual, "expected", drift.Expected)
+	}
+
+	if !repair || len(report.Drifts) == 0 {
+		return report, nil
+	}
+
+	result := resolver.Resolve(features, groups)
+	drifted := make(map[string]bool, len(report.Drifts))
+	for _, drift := range report.Drifts {
+		drifted[drift.Kind+" "+drift.Object.String()] = true
+	}
+
+	// Objects changed or deleted since listing are skipped, they are reconciled anyway and checked again by the next
+	// run.
+	var errs []error
+	for i := range features {
+		feature := &features[i]
+		ref := featuresv1alpha1.InstalledFeatureRef{Namespace: feature.Namespace, Name: feature.Name}
+		if !drifted[KindFeature+" "+ref.String()] {
+			continue
+		}
+
+		patch := c.Client.GetInstalledFeaturePatchBase(feature)
+		feature.Status = result.FeatureStatus(feature)
+		if err := c.Client.PatchInstalledFeatureStatus(ctx, feature, patch); err != nil {
+			if !errors.IsNotFound(err) && !errors.IsConflict(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		c.Log.Info("repaired status", "kind", KindFeature, "object", ref.String())
+		report.Repaired++
+	}
+
+	for i := range groups {
+		group := &groups[i]
+		ref := featuresv1alpha1.InstalledFeatureRef{Namespace: group.Namespace, Name: group.Name}
+		if !drifted[KindGroup+" "+ref.String()] {
+			continue
+		}
+
+		patch := c.Client.GetInstalledFeatureGroupPatchBase(group)
+		group.Status = result.GroupStatus(group)
+		if err := c.Client.PatchInstalledFeatureGroupStatus(ctx, group, patch); err != nil {
+			if !errors.IsNotFound(err) && !errors.IsConflict(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		c.Log.Info("repaired status", "kind", KindGroup, "object", ref.String())
+		report.Repaired++
+	}
+
+	return report, utilerrors.NewAggregate(errs)
+}
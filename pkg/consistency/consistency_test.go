@@ -0,0 +1,213 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package consistency_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/pkg/consistency"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/harness"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("Consistency checks", func() {
+	var (
+		ctx = context.Background()
+
+		store   *controllers.OcpClientMemory
+		checker *Checker
+		// elected is closed like the channel of the leading manager.
+		elected chan struct{}
+	)
+
+	BeforeEach(func() {
+		store = controllers.NewOcpClientMemory()
+		checker = &Checker{Client: store, Log: logf.NullLogger{}}
+		elected = make(chan struct{})
+		close(elected)
+
+		store.AddInstalledFeatureGroup(group("library"))
+		store.AddInstalledFeature(feature("basic", "library", "other"))
+		store.AddInstalledFeature(feature("other", "library"))
+		Expect(harness.Reconcile(store, logf.NullLogger{})).Should(Succeed())
+	})
+
+	loseDependent := func() {
+		other, err := store.LoadInstalledFeature(ctx, types.NamespacedName{Namespace: "default", Name: "other"})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		other.Status.DependingFeatures = nil
+		store.AddInstalledFeature(other)
+	}
+
+	It("should not find drifts in a reconciled cluster", func() {
+		report, err := checker.Run(ctx, false)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Drifts).Should(BeEmpty())
+		Expect(report.Features).Should(Equal(2))
+		Expect(report.Groups).Should(Equal(1))
+	})
+
+	It("should report a lost depending feature", func() {
+		loseDependent()
+
+		report, err := checker.Run(ctx, false)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Drifts).Should(Equal([]Drift{{
+			Kind:     KindFeature,
+			Object:   InstalledFeatureRef{Namespace: "default", Name: "other"},
+			Field:    "depending-features",
+			Actual:   "[]",
+			Expected: `[{"namespace":"default","name":"basic"}]`,
+		}}))
+		Expect(report.Repaired).Should(BeZero())
+	})
+
+	It("should report a stale group member", func() {
+		library, err := store.LoadInstalledFeatureGroup(ctx, types.NamespacedName{Namespace: "default", Name: "library"})
+		Expect(err).ShouldNot(HaveOccurred())
+		library.Status.Features = append(library.Status.Features, InstalledFeatureGroupListedFeature{Namespace: "default", Name: "removed"})
+		store.AddInstalledFeatureGroup(library)
+
+		report, err := checker.Run(ctx, false)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Drifts).Should(HaveLen(1))
+		Expect(report.Drifts[0].Kind).Should(Equal(KindGroup))
+		Expect(report.Drifts[0].Field).Should(Equal("features"))
+	})
+
+	It("should report a stale phase", func() {
+		basic, err := store.LoadInstalledFeature(ctx, types.NamespacedName{Namespace: "default", Name: "basic"})
+		Expect(err).ShouldNot(HaveOccurred())
+		basic.Status.Phase = PhasePending
+		basic.Status.MissingDependencies = []InstalledFeatureRef{{Namespace: "default", Name: "other"}}
+		store.AddInstalledFeature(basic)
+
+		report, err := checker.Run(ctx, false)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Drifts).Should(HaveLen(2))
+		Expect(report.Drifts[0].Field).Should(Equal("phase"))
+		Expect(report.Drifts[1].Field).Should(Equal("missing-dependencies"))
+	})
+
+	It("should repair the drifts", func() {
+		loseDependent()
+
+		report, err := checker.Run(ctx, true)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Drifts).Should(HaveLen(1))
+		Expect(report.Repaired).Should(Equal(1))
+
+		report, err = checker.Run(ctx, false)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Drifts).Should(BeEmpty())
+	})
+
+	It("should run periodically until stopped", func() {
+		loseDependent()
+
+		stop := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- (&Job{Checker: checker, Interval: 10 * time.Millisecond, Repair: true}).Start(stop)
+		}()
+
+		Eventually(func() []Drift {
+			return Check(store.InstalledFeatures(), store.InstalledFeatureGroups())
+		}).Should(BeEmpty())
+
+		close(stop)
+		Eventually(done).Should(Receive(BeNil()))
+	})
+
+	It("should serve the report", func() {
+		loseDependent()
+
+		recorder := httptest.NewRecorder()
+		Handler(checker, elected).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/consistency", nil))
+
+		Expect(recorder.Code).Should(Equal(http.StatusOK))
+		report := &Report{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), report)).Should(Succeed())
+		Expect(report.Drifts).Should(HaveLen(1))
+		Expect(report.Repaired).Should(BeZero())
+	})
+
+	It("should repair on POST", func() {
+		loseDependent()
+
+		recorder := httptest.NewRecorder()
+		Handler(checker, elected).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/consistency", nil))
+
+		Expect(recorder.Code).Should(Equal(http.StatusOK))
+		Expect(Check(store.InstalledFeatures(), store.InstalledFeatureGroups())).Should(BeEmpty())
+	})
+
+	It("should refuse to repair on managers not being the leader", func() {
+		loseDependent()
+
+		recorder := httptest.NewRecorder()
+		Handler(checker, make(chan struct{})).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/consistency", nil))
+
+		Expect(recorder.Code).Should(Equal(http.StatusServiceUnavailable))
+		Expect(Check(store.InstalledFeatures(), store.InstalledFeatureGroups())).Should(HaveLen(1))
+	})
+
+	It("should reject other methods", func() {
+		recorder := httptest.NewRecorder()
+		Handler(checker, elected).ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/consistency", nil))
+
+		Expect(recorder.Code).Should(Equal(http.StatusMethodNotAllowed))
+	})
+})
+
+func feature(name string, group string, dependencies ...string) *InstalledFeature {
+	result := &InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: InstalledFeatureSpec{
+			Kind:    name,
+			Version: "1.0.0",
+			Group:   &InstalledFeatureRef{Namespace: "default", Name: group},
+		},
+	}
+
+	for _, dependency := range dependencies {
+		result.Spec.DependsOn = append(result.Spec.DependsOn, InstalledFeatureRef{Namespace: "default", Name: dependency})
+	}
+
+	return result
+}
+
+func group(name string) *InstalledFeatureGroup {
+	return &InstalledFeatureGroup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package consistency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var (
+	_ manager.Runnable               = &Job{}
+	_ manager.LeaderElectionRunnable = &Job{}
+)
+
+// ErrNotLeader is returned to repairs requested from managers not being the leader.
+var ErrNotLeader = errors.New("repairs are only done by the leading manager")
+
+// Job runs the checker periodically as part of the manager. On startup the reconcilers handle every object anyway,
+// so the first check is run after the first interval.
+type Job struct {
+	Checker  *Checker
+	Interval time.Duration
+	// Repair repairs the drifts found instead of only reporting them.
+	Repair bool
+}
+
+// Start runs the checks until the stop channel is closed.
+func (j *Job) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			j.run()
+		}
+	}
+}
+
+// NeedLeaderElection makes sure only the leading manager repairs the status.
+func (j *Job) NeedLeaderElection() bool {
+	return true
+}
+
+func (j *Job) run() {
+	ctx, cancel := context.WithTimeout(context.Background(), j.Interval)
+	defer cancel()
+
+	report, err := j.Checker.Run(ctx, j.Repair)
+	if err != nil {
+		j.Checker.Log.Error(err, "consistency check failed")
+		return
+	}
+
+	j.Checker.Log.Info("consistency checked", "features", report.Features, "groups", report.Groups,
+		"drifts", len(report.Drifts), "repaired", report.Repaired)
+}
+
+// Handler serves the report of a consistency check as JSON. GET only reports the drifts, POST repairs them. Repairs
+// are refused until the elected channel is closed, so only the leading manager repairs the status like the Job.
+func Handler(checker *Checker, elected <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var repair bool
+		switch req.Method {
+		case http.MethodGet:
+			repair = false
+		case http.MethodPost:
+			select {
+			case <-elected:
+			default:
+				http.Error(w, ErrNotLeader.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			repair = true
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "only GET and POST are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		report, err := checker.Run(req.Context(), repair)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
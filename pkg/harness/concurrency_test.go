@@ -0,0 +1,154 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package harness_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/installedfeature"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/installedfeaturegroup"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// The concurrency tests run the reconcilers in parallel like the manager does with MaxConcurrentReconciles > 1. Every
+// worker creates its own feature and reconciles it until it succeeds. No further reconciliation is done afterwards, so
+// the status of the shared dependency and group has to list all features without anything being lost in between.
+var _ = Describe("Concurrent reconciliation", func() {
+	const (
+		namespace = "default"
+		workers   = 16
+		attempts  = 100
+	)
+
+	var (
+		ctx   context.Context
+		store *slowListingClient
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		store = &slowListingClient{OcpClientMemory: controllers.NewOcpClientMemory()}
+
+		Expect(store.CreateInstalledFeature(ctx, &InstalledFeature{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "base"},
+			Spec:       InstalledFeatureSpec{Kind: "base", Version: "1.0.0"},
+		})).Should(Succeed())
+		Expect(store.CreateInstalledFeatureGroup(ctx, &InstalledFeatureGroup{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "shared"},
+		})).Should(Succeed())
+	})
+
+	reconcile := func(reconciler reconcileFunc, name string) error {
+		var err error
+		for i := 0; i < attempts; i++ {
+			if _, err = reconciler(ctrl.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}); err == nil {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("%s could not be reconciled: %v", name, err)
+	}
+
+	It("should not lose depending features or group members", func() {
+		features := &installedfeature.Reconciler{Client: store, Log: logf.NullLogger{}}
+		groups := &installedfeaturegroup.Reconciler{Client: store, Log: logf.NullLogger{}}
+
+		errs := make(chan error, 2*workers)
+		wg := sync.WaitGroup{}
+		for i := 0; i < workers; i++ {
+			wg.Add(2)
+
+			name := fmt.Sprintf("feature-%02d", i)
+			go func() {
+				defer wg.Done()
+
+				err := store.CreateInstalledFeature(ctx, &InstalledFeature{
+					ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+					Spec: InstalledFeatureSpec{
+						Kind:      name,
+						Version:   "1.0.0",
+						Group:     &InstalledFeatureRef{Namespace: namespace, Name: "shared"},
+						DependsOn: []InstalledFeatureRef{{Namespace: namespace, Name: "base"}},
+					},
+				})
+				if err == nil {
+					err = reconcile(features.Reconcile, name)
+				}
+				if err != nil {
+					errs <- err
+				}
+			}()
+
+			go func() {
+				defer wg.Done()
+
+				if err := reconcile(groups.Reconcile, "shared"); err != nil {
+					errs <- err
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+
+		expectedDependents := make([]InstalledFeatureRef, workers)
+		expectedMembers := make([]InstalledFeatureGroupListedFeature, workers)
+		for i := 0; i < workers; i++ {
+			name := fmt.Sprintf("feature-%02d", i)
+			expectedDependents[i] = InstalledFeatureRef{Namespace: namespace, Name: name}
+			expectedMembers[i] = InstalledFeatureGroupListedFeature{Namespace: namespace, Name: name}
+		}
+
+		base, err := store.LoadInstalledFeature(ctx, types.NamespacedName{Namespace: namespace, Name: "base"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(base.Status.DependingFeatures).Should(Equal(expectedDependents))
+
+		group, err := store.LoadInstalledFeatureGroup(ctx, types.NamespacedName{Namespace: namespace, Name: "shared"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(group.Status.Features).Should(Equal(expectedMembers))
+	})
+})
+
+type reconcileFunc func(ctrl.Request) (ctrl.Result, error)
+
+// slowListingClient delays every listing of features, so other workers change the listed objects before the status
+// computed from the list is written back.
+type slowListingClient struct {
+	*controllers.OcpClientMemory
+}
+
+func (c *slowListingClient) ListInstalledFeatures(ctx context.Context) ([]InstalledFeature, error) {
+	features, err := c.OcpClientMemory.ListInstalledFeatures(ctx)
+
+	time.Sleep(time.Duration(rand.Intn(1000)) * time.Microsecond)
+
+	return features, err
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package harness runs the reconcilers of the operator against the in-memory OcpClient. It is used to test the
+// behaviour of the operator without a cluster: either programmatically with Reconcile or with YAML scenarios
+// describing the applied objects and the expected status after every step.
+package harness
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/installedfeature"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers/installedfeaturegroup"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Reconcile runs both reconcilers on every object of the store until the store does not change any more. Failed
+// reconciliations are ignored, since they are requeued by the operator anyway and show up in the status of the
+// objects. An error is returned if the store does not settle.
+func Reconcile(store *controllers.OcpClientMemory, log logr.Logger) error {
+	features := &installedfeature.Reconciler{Client: store, Log: log.WithName("InstalledFeature")}
+	groups := &installedfeaturegroup.Reconciler{Client: store, Log: log.WithName("InstalledFeatureGroup")}
+
+	rounds := 2*(len(store.InstalledFeatures())+len(store.InstalledFeatureGroups())) + 2
+	for i := 0; i < rounds; i++ {
+		revision := store.Revision()
+
+		for _, group := range store.InstalledFeatureGroups() {
+			_, _ = groups.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Namespace: group.Namespace, Name: group.Name}})
+		}
+		for _, feature := range store.InstalledFeatures() {
+			_, _ = features.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Namespace: feature.Namespace, Name: feature.Name}})
+		}
+
+		if store.Revision() == revision {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("the reconciliation did not settle after %d rounds", rounds)
+}
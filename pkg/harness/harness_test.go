@@ -0,0 +1,102 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package harness_test
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/pkg/harness"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("Scenarios", func() {
+	scenarios, err := LoadScenarios("testdata")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, scenario := range scenarios {
+		scenario := scenario
+
+		It(scenario.Name, func() {
+			_, err := scenario.Run(logf.NullLogger{})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	}
+
+	It("should report the differences to the expected status", func() {
+		scenario := &Scenario{
+			Name: "wrong expectation",
+			Steps: []Step{{
+				Apply: []json.RawMessage{
+					json.RawMessage(`{"apiVersion":"features.kaiserpfalz-edv.de/v1alpha1","kind":"InstalledFeature","metadata":{"name":"basic"},"spec":{"kind":"basic","version":"1.0.0"}}`),
+				},
+				Expect: Expectations{
+					Features: []FeatureExpectation{
+						{Name: "basic", Status: InstalledFeatureStatus{Phase: PhasePending}},
+						{Name: "missing", Status: InstalledFeatureStatus{Phase: PhaseProvisioned}},
+					},
+				},
+			}},
+		}
+
+		_, err := scenario.Run(logf.NullLogger{})
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("feature default/basic has status"))
+		Expect(err.Error()).Should(ContainSubstring("\"missing\" not found"))
+	})
+
+	It("should reject unknown kinds", func() {
+		scenario := &Scenario{
+			Steps: []Step{{
+				Apply: []json.RawMessage{json.RawMessage(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"basic"}}`)},
+			}},
+		}
+
+		_, err := scenario.Run(logf.NullLogger{})
+		Expect(err).Should(HaveOccurred())
+	})
+})
+
+var _ = Describe("Reconciling the in-memory cluster", func() {
+	It("should resolve features added with their status", func() {
+		store := controllers.NewOcpClientMemory()
+		store.AddInstalledFeature(&InstalledFeature{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "basic"},
+			Spec: InstalledFeatureSpec{
+				Kind:      "basic",
+				Version:   "1.0.0",
+				DependsOn: []InstalledFeatureRef{{Namespace: "default", Name: "other"}},
+			},
+			Status: InstalledFeatureStatus{Phase: PhaseProvisioned},
+		})
+
+		Expect(Reconcile(store, logf.NullLogger{})).Should(Succeed())
+
+		feature, err := store.LoadInstalledFeature(context.Background(), types.NamespacedName{Namespace: "default", Name: "basic"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(feature.Status.Phase).Should(Equal(PhasePending))
+		Expect(feature.Finalizers).Should(ConsistOf("features.kaiserpfalz-edv.de/installedfeature-controller"))
+	})
+})
@@ -0,0 +1,308 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package harness
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-logr/logr"
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	kindFeature = "InstalledFeature"
+	kindGroup   = "InstalledFeatureGroup"
+
+	// DefaultNamespace is used for all objects without namespace when the scenario doesn't define one.
+	DefaultNamespace = "default"
+)
+
+// Scenario is a sequence of steps run against an empty in-memory cluster. Every step applies and deletes objects,
+// runs the reconcilers until nothing changes any more and checks the expected status of the objects.
+type Scenario struct {
+	// Name describes the scenario.
+	Name string `json:"name"`
+	// Namespace is used for all objects and expectations without namespace. Defaults to "default".
+	Namespace string `json:"namespace,omitempty"`
+	// Steps are run in the given order on the same cluster.
+	Steps []Step `json:"steps"`
+}
+
+// Step is a single change of the cluster.
+type Step struct {
+	// Name describes the step.
+	Name string `json:"name,omitempty"`
+	// Apply contains InstalledFeature and InstalledFeatureGroup manifests. Missing objects are created, the metadata
+	// and spec of existing objects are updated.
+	Apply []json.RawMessage `json:"apply,omitempty"`
+	// Delete contains the objects to delete. Objects with finalizers are only marked as deleted.
+	Delete []Object `json:"delete,omitempty"`
+	// Expect contains the expected state after the reconcilers settled.
+	Expect Expectations `json:"expect,omitempty"`
+}
+
+// Object references a feature or group.
+type Object struct {
+	// Kind is either InstalledFeature or InstalledFeatureGroup.
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// Expectations list the expected features and groups. Objects not listed are not checked.
+type Expectations struct {
+	Features []FeatureExpectation `json:"features,omitempty"`
+	Groups   []GroupExpectation   `json:"groups,omitempty"`
+}
+
+// FeatureExpectation is the expected state of a single feature.
+type FeatureExpectation struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Absent expects the feature to be removed from the cluster. The status is not checked then.
+	Absent bool `json:"absent,omitempty"`
+	// Status is the complete expected status of the feature.
+	Status featuresv1alpha1.InstalledFeatureStatus `json:"status,omitempty"`
+}
+
+// GroupExpectation is the expected state of a single group.
+type GroupExpectation struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Absent expects the group to be removed from the cluster. The status is not checked then.
+	Absent bool `json:"absent,omitempty"`
+	// Status is the complete expected status of the group.
+	Status featuresv1alpha1.InstalledFeatureGroupStatus `json:"status,omitempty"`
+}
+
+// LoadScenario reads a scenario from a YAML or JSON file.
+func LoadScenario(file string) (*Scenario, error) {
+	reader, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	result := &Scenario{}
+	if err := yaml.NewYAMLOrJSONDecoder(reader, 4096).Decode(result); err != nil {
+		return nil, fmt.Errorf("can not read %s: %v", file, err)
+	}
+
+	if result.Name == "" {
+		result.Name = filepath.Base(file)
+	}
+
+	return result, nil
+}
+
+// LoadScenarios reads all scenarios in the directory ending in .yaml, .yml or .json sorted by the file name.
+func LoadScenarios(dir string) ([]*Scenario, error) {
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	result := make([]*Scenario, len(files))
+	for i, file := range files {
+		scenario, err := LoadScenario(file)
+		if err != nil {
+			return nil, err
+		}
+
+		result[i] = scenario
+	}
+
+	return result, nil
+}
+
+// Run runs all steps of the scenario against a new in-memory cluster. It stops at the first step not meeting the
+// expectations and returns all differences found in this step. The cluster is returned for further checks.
+func (s *Scenario) Run(log logr.Logger) (*controllers.OcpClientMemory, error) {
+	ctx := context.Background()
+	store := controllers.NewOcpClientMemory()
+
+	for i, step := range s.Steps {
+		if err := s.run(ctx, store, step, log); err != nil {
+			return store, fmt.Errorf("step %d (%s): %v", i+1, step.Name, err)
+		}
+	}
+
+	return store, nil
+}
+
+func (s *Scenario) run(ctx context.Context, store *controllers.OcpClientMemory, step Step, log logr.Logger) error {
+	for _, manifest := range step.Apply {
+		if err := s.apply(ctx, store, manifest); err != nil {
+			return err
+		}
+	}
+
+	for _, object := range step.Delete {
+		lookup := s.lookup(object.Namespace, object.Name)
+
+		var err error
+		switch object.Kind {
+		case kindFeature:
+			err = store.DeleteInstalledFeature(ctx, lookup)
+		case kindGroup:
+			err = store.DeleteInstalledFeatureGroup(ctx, lookup)
+		default:
+			err = fmt.Errorf("can not delete %s %s: unknown kind", object.Kind, lookup)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := Reconcile(store, log); err != nil {
+		return err
+	}
+
+	return s.check(ctx, store, step.Expect)
+}
+
+func (s *Scenario) apply(ctx context.Context, store *controllers.OcpClientMemory, manifest json.RawMessage) error {
+	object := Object{}
+	if err := json.Unmarshal(manifest, &struct {
+		Kind     *string `json:"kind"`
+		Metadata *Object `json:"metadata"`
+	}{Kind: &object.Kind, Metadata: &object}); err != nil {
+		return err
+	}
+
+	switch object.Kind {
+	case kindFeature:
+		feature := &featuresv1alpha1.InstalledFeature{}
+		if err := json.Unmarshal(manifest, feature); err != nil {
+			return err
+		}
+		if feature.Namespace == "" {
+			feature.Namespace = s.namespace()
+		}
+
+		existing, err := store.LoadInstalledFeature(ctx, s.lookup(feature.Namespace, feature.Name))
+		if errors.IsNotFound(err) {
+			return store.CreateInstalledFeature(ctx, feature)
+		} else if err != nil {
+			return err
+		}
+
+		existing.Labels, existing.Annotations, existing.Spec = feature.Labels, feature.Annotations, feature.Spec
+		return store.SaveInstalledFeature(ctx, existing)
+
+	case kindGroup:
+		group := &featuresv1alpha1.InstalledFeatureGroup{}
+		if err := json.Unmarshal(manifest, group); err != nil {
+			return err
+		}
+		if group.Namespace == "" {
+			group.Namespace = s.namespace()
+		}
+
+		existing, err := store.LoadInstalledFeatureGroup(ctx, s.lookup(group.Namespace, group.Name))
+		if errors.IsNotFound(err) {
+			return store.CreateInstalledFeatureGroup(ctx, group)
+		} else if err != nil {
+			return err
+		}
+
+		existing.Labels, existing.Annotations, existing.Spec = group.Labels, group.Annotations, group.Spec
+		return store.SaveInstalledFeatureGroup(ctx, existing)
+
+	default:
+		return fmt.Errorf("can not apply %s %s: unknown kind", object.Kind, object.Name)
+	}
+}
+
+func (s *Scenario) check(ctx context.Context, store *controllers.OcpClientMemory, expect Expectations) error {
+	var result []error
+
+	for _, expected := range expect.Features {
+		lookup := s.lookup(expected.Namespace, expected.Name)
+
+		feature, err := store.LoadInstalledFeature(ctx, lookup)
+		switch {
+		case expected.Absent && errors.IsNotFound(err):
+		case expected.Absent && err == nil:
+			result = append(result, fmt.Errorf("feature %s should be absent", lookup))
+		case err != nil:
+			result = append(result, fmt.Errorf("feature %s: %v", lookup, err))
+		case !equality.Semantic.DeepEqual(feature.Status, expected.Status):
+			result = append(result, fmt.Errorf("feature %s has status %s, expected %s",
+				lookup, printable(feature.Status), printable(expected.Status)))
+		}
+	}
+
+	for _, expected := range expect.Groups {
+		lookup := s.lookup(expected.Namespace, expected.Name)
+
+		group, err := store.LoadInstalledFeatureGroup(ctx, lookup)
+		switch {
+		case expected.Absent && errors.IsNotFound(err):
+		case expected.Absent && err == nil:
+			result = append(result, fmt.Errorf("group %s should be absent", lookup))
+		case err != nil:
+			result = append(result, fmt.Errorf("group %s: %v", lookup, err))
+		case !equality.Semantic.DeepEqual(group.Status, expected.Status):
+			result = append(result, fmt.Errorf("group %s has status %s, expected %s",
+				lookup, printable(group.Status), printable(expected.Status)))
+		}
+	}
+
+	return utilerrors.NewAggregate(result)
+}
+
+func (s *Scenario) lookup(namespace string, name string) types.NamespacedName {
+	if namespace == "" {
+		namespace = s.namespace()
+	}
+
+	return types.NamespacedName{Namespace: namespace, Name: name}
+}
+
+func (s *Scenario) namespace() string {
+	if s.Namespace == "" {
+		return DefaultNamespace
+	}
+
+	return s.Namespace
+}
+
+func printable(status interface{}) string {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Sprintf("%v", status)
+	}
+
+	return string(data)
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package images parses the container image references of workloads, e.g. "k8s.gcr.io/coredns:1.7.0" or
+// "quay.io/org/app@sha256:...".
+package images
+
+import "strings"
+
+// Reference is a parsed container image reference.
+type Reference struct {
+	// Repository is the image without tag and digest, e.g. "k8s.gcr.io/coredns".
+	Repository string
+	// Tag is the tag of the image, e.g. "1.7.0". Empty when not given.
+	Tag string
+	// Digest is the digest of the image, e.g. "sha256:...". Empty when not given.
+	Digest string
+}
+
+// Parse splits an image reference into repository, tag and digest. A colon before the last "/" is the port of the
+// registry and not a tag.
+func Parse(image string) Reference {
+	var result Reference
+	if i := strings.IndexRune(image, '@'); i >= 0 {
+		image, result.Digest = image[:i], image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i >= 0 && !strings.Contains(image[i:], "/") {
+		image, result.Tag = image[:i], image[i+1:]
+	}
+	result.Repository = image
+
+	return result
+}
+
+// Version is the tag of the image if given, otherwise its digest. The tag is compared with the declared version of
+// the feature, the digest only identifies images without tag.
+func (r Reference) Version() string {
+	if r.Tag != "" {
+		return r.Tag
+	}
+
+	return r.Digest
+}
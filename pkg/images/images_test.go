@@ -0,0 +1,46 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package images_test
+
+import (
+	. "github.com/klenkes74/k8s-installed-features-catalogue/pkg/images"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Parsing image references", func() {
+	table.DescribeTable("splitting into repository, tag and digest",
+		func(image string, expected Reference, version string) {
+			reference := Parse(image)
+
+			Expect(reference).Should(Equal(expected))
+			Expect(reference.Version()).Should(Equal(version))
+		},
+		table.Entry("tag", "k8s.gcr.io/coredns:1.7.0",
+			Reference{Repository: "k8s.gcr.io/coredns", Tag: "1.7.0"}, "1.7.0"),
+		table.Entry("without tag", "nginx", Reference{Repository: "nginx"}, ""),
+		table.Entry("registry with port", "registry:5000/app",
+			Reference{Repository: "registry:5000/app"}, ""),
+		table.Entry("registry with port and tag", "registry:5000/app:2.1",
+			Reference{Repository: "registry:5000/app", Tag: "2.1"}, "2.1"),
+		table.Entry("digest", "quay.io/org/app@sha256:0123",
+			Reference{Repository: "quay.io/org/app", Digest: "sha256:0123"}, "sha256:0123"),
+		table.Entry("tag and digest", "quay.io/org/app:1.0@sha256:0123",
+			Reference{Repository: "quay.io/org/app", Tag: "1.0", Digest: "sha256:0123"}, "1.0"),
+	)
+})
@@ -0,0 +1,211 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package lint checks InstalledFeature and InstalledFeatureGroup manifests without a cluster. The manifests are
+// resolved by the reconcilers of the operator against an in-memory store, so the linter reports the same missing
+// dependencies the operator would. Additionally it checks for conflicts, dependency cycles and invalid versions.
+package lint
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/controllers"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/catalogue"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/harness"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/versions"
+)
+
+// Severity of a finding.
+type Severity string
+
+const (
+	// SeverityError marks findings that would break the catalogue on a cluster.
+	SeverityError Severity = "error"
+	// SeverityWarning marks findings that are suspicious but do not break the catalogue.
+	SeverityWarning Severity = "warning"
+)
+
+// Finding is a single problem found by the linter.
+type Finding struct {
+	Severity Severity
+	// Kind is the kind of the object with the problem.
+	Kind string
+	// Object is the object with the problem.
+	Object featuresv1alpha1.InstalledFeatureRef
+	// Source is the file the object has been read from.
+	Source  string
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%-7s %s %s: %s (%s)", strings.ToUpper(string(f.Severity)), f.Kind, f.Object, f.Message, f.Source)
+}
+
+// Report is the result of the linter.
+type Report struct {
+	Findings []Finding
+	// Features are the features with the status computed by the reconciler.
+	Features []featuresv1alpha1.InstalledFeature
+	// Groups are the groups with the status computed by the reconciler.
+	Groups []featuresv1alpha1.InstalledFeatureGroup
+}
+
+// Count returns the number of findings with the given severity.
+func (r *Report) Count(severity Severity) int {
+	result := 0
+	for _, f := range r.Findings {
+		if f.Severity == severity {
+			result++
+		}
+	}
+
+	return result
+}
+
+// HasErrors returns true if there is at least one finding with severity error.
+func (r *Report) HasErrors() bool {
+	return r.Count(SeverityError) > 0
+}
+
+// Print writes the findings and a summary.
+func (r *Report) Print(out io.Writer) {
+	for _, f := range r.Findings {
+		fmt.Fprintln(out, f)
+	}
+
+	fmt.Fprintf(out, "%d features, %d groups: %d errors, %d warnings\n",
+		len(r.Features), len(r.Groups), r.Count(SeverityError), r.Count(SeverityWarning))
+}
+
+// Lint resolves the manifests and reports all problems found.
+func Lint(manifests *Manifests, log logr.Logger) (*Report, error) {
+	store := controllers.NewOcpClientMemory()
+	for i := range manifests.Features {
+		store.AddInstalledFeature(&manifests.Features[i])
+	}
+	for i := range manifests.Groups {
+		store.AddInstalledFeatureGroup(&manifests.Groups[i])
+	}
+
+	if err := harness.Reconcile(store, log); err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Findings: append([]Finding{}, manifests.duplicates...),
+		Features: store.InstalledFeatures(),
+		Groups:   store.InstalledFeatureGroups(),
+	}
+	l := &linter{
+		manifests: manifests,
+		report:    report,
+		catalogue: catalogue.New(report.Features, report.Groups),
+	}
+
+	for i := range report.Features {
+		l.checkFeature(&report.Features[i])
+	}
+	l.checkCycles()
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		if report.Findings[i].Source != report.Findings[j].Source {
+			return report.Findings[i].Source < report.Findings[j].Source
+		}
+		return catalogue.Less(report.Findings[i].Object, report.Findings[j].Object)
+	})
+
+	return report, nil
+}
+
+type linter struct {
+	manifests *Manifests
+	report    *Report
+	catalogue *catalogue.Catalogue
+}
+
+func (l *linter) add(severity Severity, feature *featuresv1alpha1.InstalledFeature, format string, args ...interface{}) {
+	l.report.Findings = append(l.report.Findings, Finding{
+		Severity: severity,
+		Kind:     kindFeature,
+		Object:   catalogue.RefOf(feature),
+		Source:   l.manifests.Source(kindFeature, feature.Namespace, feature.Name),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *linter) checkFeature(feature *featuresv1alpha1.InstalledFeature) {
+	if feature.Spec.Version == "" {
+		l.add(SeverityError, feature, "has no version")
+	} else if _, err := versions.Parse(feature.Spec.Version); err != nil {
+		l.add(SeverityWarning, feature, "version '%s' is no semantic version, version ranges can not be checked", feature.Spec.Version)
+	}
+
+	for _, dependency := range feature.Spec.DependsOn {
+		if _, err := versions.ParseRange(dependency.Version); err != nil {
+			l.add(SeverityError, feature, "dependency %s has an invalid version range: %v", dependency, err)
+		}
+	}
+
+	for _, missing := range feature.Status.MissingDependencies {
+		l.checkMissingDependency(feature, missing)
+	}
+
+	if feature.Spec.Group != nil && l.catalogue.Group(*feature.Spec.Group) == nil {
+		l.add(SeverityError, feature, "group %s is not defined", feature.Spec.Group)
+	}
+
+	for _, conflict := range feature.Status.ConflictingFeatures {
+		if conflict.Version != "" {
+			l.add(SeverityError, feature, "conflicts with %s in version '%s', which is defined, too", conflict, conflict.Version)
+		} else {
+			l.add(SeverityError, feature, "conflicts with %s, which is defined, too", conflict)
+		}
+	}
+}
+
+func (l *linter) checkMissingDependency(feature *featuresv1alpha1.InstalledFeature, missing featuresv1alpha1.InstalledFeatureRef) {
+	dependency := l.catalogue.Feature(missing)
+
+	switch {
+	case dependency == nil && missing.Namespace == "":
+		l.add(SeverityError, feature, "depends on %s, which is not defined (references without namespace are not resolved)", missing)
+
+	case dependency == nil:
+		l.add(SeverityError, feature, "depends on %s, which is not defined", missing)
+
+	case missing.Version != "":
+		l.add(SeverityError, feature, "depends on %s in version '%s', but it has version '%s'", missing, missing.Version, dependency.Spec.Version)
+
+	default:
+		l.add(SeverityError, feature, "dependency %s could not be resolved", missing)
+	}
+}
+
+func (l *linter) checkCycles() {
+	for _, cycle := range l.catalogue.Cycles() {
+		members := make([]string, len(cycle))
+		for i, member := range cycle {
+			members[i] = member.String()
+		}
+
+		l.add(SeverityError, l.catalogue.Feature(cycle[0]), "is part of the dependency cycle [%s]", strings.Join(members, ", "))
+	}
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lint_test
+
+import (
+	"bytes"
+	"path/filepath"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/pkg/lint"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func messages(report *Report, severity Severity) []string {
+	result := make([]string, 0)
+	for _, f := range report.Findings {
+		if f.Severity == severity {
+			result = append(result, f.Object.Name+": "+f.Message)
+		}
+	}
+
+	return result
+}
+
+var _ = Describe("Manifest linter", func() {
+	It("should load features and groups and ignore other objects", func() {
+		manifests, err := LoadManifests("default", filepath.Join("testdata", "valid"))
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(manifests.Features).Should(HaveLen(3))
+		Expect(manifests.Groups).Should(HaveLen(1))
+		Expect(manifests.Groups[0].Namespace).Should(Equal("default"))
+		Expect(manifests.Source("InstalledFeature", "default", "my-app")).Should(Equal(filepath.Join("testdata", "valid", "list.json")))
+	})
+
+	It("should resolve valid manifests without findings", func() {
+		manifests, err := LoadManifests("default", filepath.Join("testdata", "valid"))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		report, err := Lint(manifests, logf.NullLogger{})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Findings).Should(BeEmpty())
+		Expect(report.HasErrors()).Should(BeFalse())
+		for _, feature := range report.Features {
+			Expect(feature.Status.Phase).Should(Equal("provisioned"), feature.Name)
+		}
+		Expect(report.Groups[0].Status.Phase).Should(Equal("provisioned"))
+	})
+
+	It("should report all problems of broken manifests", func() {
+		manifests, err := LoadManifests("default", filepath.Join("testdata", "broken"))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		report, err := Lint(manifests, logf.NullLogger{})
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.HasErrors()).Should(BeTrue())
+		Expect(messages(report, SeverityError)).Should(ConsistOf(
+			"cycle-a: is already defined in "+filepath.Join("testdata", "broken", "features.yaml"),
+			"dangling: depends on default/not-there, which is not defined",
+			"needs-new-database: depends on default/old-database in version '>=12', but it has version '9.6.0'",
+			"conflicting: group default/no-such-group is not defined",
+			"conflicting: conflicts with default/old-database, which is defined, too",
+			"cycle-a: is part of the dependency cycle [default/cycle-a, default/cycle-b]",
+		))
+		Expect(messages(report, SeverityWarning)).Should(ConsistOf(
+			"needs-new-database: version 'latest' is no semantic version, version ranges can not be checked",
+		))
+	})
+
+	It("should print the findings with a summary", func() {
+		manifests, err := LoadManifests("default", filepath.Join("testdata", "broken"))
+		Expect(err).ShouldNot(HaveOccurred())
+		report, err := Lint(manifests, logf.NullLogger{})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		out := &bytes.Buffer{}
+		report.Print(out)
+
+		Expect(out.String()).Should(ContainSubstring("ERROR   InstalledFeature default/dangling: depends on default/not-there"))
+		Expect(out.String()).Should(HaveSuffix("6 features, 0 groups: 6 errors, 1 warnings\n"))
+	})
+})
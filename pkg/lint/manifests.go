@@ -0,0 +1,196 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	kindFeature = "InstalledFeature"
+	kindGroup   = "InstalledFeatureGroup"
+)
+
+// Manifests are the features and groups read from manifest files.
+type Manifests struct {
+	Features []featuresv1alpha1.InstalledFeature
+	Groups   []featuresv1alpha1.InstalledFeatureGroup
+
+	// sources contains the file every object has been read from.
+	sources map[string]string
+	// duplicates are objects defined more than once.
+	duplicates []Finding
+}
+
+func objectKey(kind string, namespace string, name string) string {
+	return fmt.Sprintf("%s %s%c%s", kind, namespace, featuresv1alpha1.Separator, name)
+}
+
+// Source returns the file the object has been read from.
+func (m *Manifests) Source(kind string, namespace string, name string) string {
+	return m.sources[objectKey(kind, namespace, name)]
+}
+
+// LoadManifests reads all InstalledFeature and InstalledFeatureGroup manifests from the given files and directories.
+// Directories are walked recursively for files ending in .yaml, .yml or .json. Objects without namespace are put into
+// the default namespace. All other objects are ignored.
+func LoadManifests(defaultNamespace string, paths ...string) (*Manifests, error) {
+	result := &Manifests{
+		sources: make(map[string]string),
+	}
+
+	for _, path := range paths {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				return nil
+			}
+
+			if file != path && !isManifest(file) {
+				return nil
+			}
+
+			return result.load(file, defaultNamespace)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func isManifest(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	default:
+		return false
+	}
+}
+
+func (m *Manifests) load(file string, defaultNamespace string) error {
+	reader, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	decoder := yaml.NewYAMLOrJSONDecoder(reader, 4096)
+	for {
+		document := make(map[string]interface{})
+		if err := decoder.Decode(&document); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("can not read %s: %v", file, err)
+		}
+
+		if err := m.add(file, defaultNamespace, document); err != nil {
+			return err
+		}
+	}
+}
+
+func (m *Manifests) add(file string, defaultNamespace string, document map[string]interface{}) error {
+	apiVersion, _ := document["apiVersion"].(string)
+	kind, _ := document["kind"].(string)
+
+	if kind == "List" || strings.HasSuffix(kind, "List") {
+		items, _ := document["items"].([]interface{})
+		for _, item := range items {
+			if object, ok := item.(map[string]interface{}); ok {
+				if err := m.add(file, defaultNamespace, object); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil || gv.Group != featuresv1alpha1.GroupVersion.Group {
+		return nil
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case kindFeature:
+		feature := featuresv1alpha1.InstalledFeature{}
+		if err := json.Unmarshal(data, &feature); err != nil {
+			return fmt.Errorf("can not read %s: %v", file, err)
+		}
+		if feature.Namespace == "" {
+			feature.Namespace = defaultNamespace
+		}
+
+		if m.register(file, kind, feature.Namespace, feature.Name) {
+			m.Features = append(m.Features, feature)
+		}
+
+	case kindGroup:
+		group := featuresv1alpha1.InstalledFeatureGroup{}
+		if err := json.Unmarshal(data, &group); err != nil {
+			return fmt.Errorf("can not read %s: %v", file, err)
+		}
+		if group.Namespace == "" {
+			group.Namespace = defaultNamespace
+		}
+
+		if m.register(file, kind, group.Namespace, group.Name) {
+			m.Groups = append(m.Groups, group)
+		}
+	}
+
+	return nil
+}
+
+// register remembers the source of the object. It returns false if the object has already been read before.
+func (m *Manifests) register(file string, kind string, namespace string, name string) bool {
+	key := objectKey(kind, namespace, name)
+
+	if source, ok := m.sources[key]; ok {
+		m.duplicates = append(m.duplicates, Finding{
+			Severity: SeverityError,
+			Kind:     kind,
+			Object:   featuresv1alpha1.InstalledFeatureRef{Namespace: namespace, Name: name},
+			Source:   file,
+			Message:  fmt.Sprintf("is already defined in %s", source),
+		})
+
+		return false
+	}
+
+	m.sources[key] = file
+	return true
+}
@@ -0,0 +1,377 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package planner computes a safe order to upgrade features to target versions. Like the resolver, it is free of side
+// effects: it is fed with all features and the targets and returns the plan.
+//
+// Every step of the plan upgrades a single feature and never breaks a dependency or conflict that was satisfied before
+// the step. Dependencies are upgraded before their dependents when both orders are safe. Targets that can not be
+// upgraded without breaking the catalogue are blocked and explained.
+package planner
+
+import (
+	"fmt"
+	"sort"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/versions"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// node is the version and the relations of a feature in a state of the plan.
+type node struct {
+	version   string
+	depends   []featuresv1alpha1.InstalledFeatureRef
+	conflicts []featuresv1alpha1.InstalledFeatureRef
+}
+
+// state contains all features of the cluster at one point of the plan.
+type state map[types.NamespacedName]*node
+
+// violation is a broken dependency or conflict. The id names the relation independent of the versions, fix names the
+// feature whose upgrade may repair it.
+type violation struct {
+	id   string
+	text string
+	fix  *types.NamespacedName
+}
+
+// upgrade is a target of the plan.
+type upgrade struct {
+	key  types.NamespacedName
+	from string
+	to   *node
+}
+
+// Plan computes the steps to upgrade the features to the targets. Targets without namespace are looked up in the given
+// namespace. When a feature is targeted more than once, the last target wins.
+func Plan(features []featuresv1alpha1.InstalledFeature, namespace string, targets []featuresv1alpha1.UpgradeTarget) featuresv1alpha1.UpgradePlanStatus {
+	current := make(state, len(features))
+	for i := range features {
+		if features[i].DeletionTimestamp != nil {
+			continue
+		}
+
+		current[types.NamespacedName{Namespace: features[i].Namespace, Name: features[i].Name}] = &node{
+			version:   features[i].Spec.Version,
+			depends:   features[i].Spec.DependsOn,
+			conflicts: features[i].Spec.Conflicts,
+		}
+	}
+
+	status := featuresv1alpha1.UpgradePlanStatus{}
+	upgrades := make(map[types.NamespacedName]*upgrade, len(targets))
+	blocked := make(map[types.NamespacedName]featuresv1alpha1.BlockedUpgrade)
+	for _, target := range targets {
+		k := types.NamespacedName{Namespace: target.Namespace, Name: target.Name}
+		if k.Namespace == "" {
+			k.Namespace = namespace
+		}
+		delete(upgrades, k)
+		delete(blocked, k)
+
+		installed, ok := current[k]
+		if !ok {
+			blocked[k] = block(k, target.Version, "not installed")
+			continue
+		}
+		if _, err := versions.Parse(target.Version); err != nil {
+			blocked[k] = block(k, target.Version, fmt.Sprintf("invalid version: %v", err))
+			continue
+		}
+		if versions.Equivalent(installed.version, target.Version) {
+			continue
+		}
+
+		upgrades[k] = &upgrade{
+			key:  k,
+			from: installed.version,
+			to: &node{
+				version:   target.Version,
+				depends:   replaceDependencies(installed.depends, target.DependsOn),
+				conflicts: installed.conflicts,
+			},
+		}
+	}
+
+	pending := order(upgrades)
+	for {
+		next := -1
+		for i, u := range pending {
+			if len(current.introduced(u)) == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+
+		u := pending[next]
+		current[u.key] = u.to
+		pending = append(pending[:next], pending[next+1:]...)
+		status.Steps = append(status.Steps, featuresv1alpha1.UpgradeStep{
+			Namespace: u.key.Namespace,
+			Name:      u.key.Name,
+			From:      u.from,
+			To:        u.to.version,
+		})
+	}
+
+	final := current.with(pending...)
+	for _, u := range pending {
+		blocked[u.key] = block(u.key, u.to.version, explain(current, final, u, upgrades)...)
+	}
+
+	for _, b := range blocked {
+		status.Blocked = append(status.Blocked, b)
+	}
+	sort.Slice(status.Blocked, func(i, j int) bool {
+		if status.Blocked[i].Namespace != status.Blocked[j].Namespace {
+			return status.Blocked[i].Namespace < status.Blocked[j].Namespace
+		}
+		return status.Blocked[i].Name < status.Blocked[j].Name
+	})
+
+	if len(status.Blocked) > 0 {
+		status.Phase = featuresv1alpha1.PhaseBlocked
+		status.Message = fmt.Sprintf("%d of %d upgrades blocked", len(status.Blocked), len(status.Blocked)+len(status.Steps))
+	} else {
+		status.Phase = featuresv1alpha1.PhasePlanned
+		status.Message = fmt.Sprintf("%d upgrades planned", len(status.Steps))
+	}
+
+	return status
+}
+
+// explain returns why the upgrade is blocked. Violations remaining after all pending upgrades need other features to
+// be upgraded as well. Otherwise every order breaks a dependency in between.
+func explain(current state, final state, u *upgrade, upgrades map[types.NamespacedName]*upgrade) []string {
+	var result []string
+	for _, v := range final.violations(u.key) {
+		text := v.text
+		if v.fix != nil && upgrades[*v.fix] == nil {
+			text = fmt.Sprintf("%s (upgrade %s, too)", text, *v.fix)
+		}
+		result = append(result, text)
+	}
+	if len(result) > 0 {
+		return result
+	}
+
+	for _, v := range current.introduced(u) {
+		result = append(result, fmt.Sprintf("%s (no order avoids this, upgrade the features together)", v.text))
+	}
+	return result
+}
+
+func block(k types.NamespacedName, version string, reasons ...string) featuresv1alpha1.BlockedUpgrade {
+	return featuresv1alpha1.BlockedUpgrade{Namespace: k.Namespace, Name: k.Name, Version: version, Reasons: reasons}
+}
+
+// replaceDependencies replaces the dependencies on the same features by the dependencies of the target version.
+func replaceDependencies(installed []featuresv1alpha1.InstalledFeatureRef, target []featuresv1alpha1.InstalledFeatureRef) []featuresv1alpha1.InstalledFeatureRef {
+	replaced := make(map[types.NamespacedName]bool, len(target))
+	for _, dependency := range target {
+		replaced[refKey(dependency)] = true
+	}
+
+	result := make([]featuresv1alpha1.InstalledFeatureRef, 0, len(installed)+len(target))
+	for _, dependency := range installed {
+		if !replaced[refKey(dependency)] {
+			result = append(result, dependency)
+		}
+	}
+
+	return append(result, target...)
+}
+
+// order sorts the upgrades so dependencies come before their dependents. Upgrades in cycles are sorted by namespace
+// and name.
+func order(upgrades map[types.NamespacedName]*upgrade) []*upgrade {
+	keys := make([]types.NamespacedName, 0, len(upgrades))
+	for k := range upgrades {
+		keys = append(keys, k)
+	}
+	sortKeys(keys)
+
+	result := make([]*upgrade, 0, len(upgrades))
+	done := make(map[types.NamespacedName]bool, len(upgrades))
+
+	for len(result) < len(keys) {
+		progress := false
+		for _, k := range keys {
+			if done[k] || !dependenciesDone(upgrades[k], upgrades, done) {
+				continue
+			}
+
+			done[k] = true
+			result = append(result, upgrades[k])
+			progress = true
+		}
+
+		if !progress {
+			for _, k := range keys {
+				if !done[k] {
+					done[k] = true
+					result = append(result, upgrades[k])
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+func dependenciesDone(u *upgrade, upgrades map[types.NamespacedName]*upgrade, done map[types.NamespacedName]bool) bool {
+	for _, dependency := range u.to.depends {
+		k := refKey(dependency)
+		if k != u.key && upgrades[k] != nil && !done[k] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// with returns a copy of the state with the upgrades applied.
+func (s state) with(upgrades ...*upgrade) state {
+	result := make(state, len(s))
+	for k, n := range s {
+		result[k] = n
+	}
+	for _, u := range upgrades {
+		result[u.key] = u.to
+	}
+
+	return result
+}
+
+// introduced returns the violations the upgrade adds to the state.
+func (s state) introduced(u *upgrade) []violation {
+	before := make(map[string]bool)
+	for _, v := range s.violations(u.key) {
+		before[v.id] = true
+	}
+
+	var result []violation
+	for _, v := range s.with(u).violations(u.key) {
+		if !before[v.id] {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+// violations returns all broken dependencies and conflicts of the feature, in both directions.
+func (s state) violations(k types.NamespacedName) []violation {
+	n := s[k]
+	var result []violation
+
+	for _, dependency := range n.depends {
+		dk := refKey(dependency)
+		fix := dk
+		other, ok := s[dk]
+		if !ok {
+			result = append(result, violation{
+				id:   fmt.Sprintf("depends %s %s", k, dk),
+				text: fmt.Sprintf("%s@%s depends on %s, but it is not installed", k, n.version, dk),
+			})
+		} else if !inRange(other.version, dependency.Version) {
+			result = append(result, violation{
+				id: fmt.Sprintf("depends %s %s", k, dk),
+				text: fmt.Sprintf("%s@%s depends on %s in version '%s', but it has version '%s'", k, n.version, dk,
+					dependency.Version, other.version),
+				fix: &fix,
+			})
+		}
+	}
+
+	for _, otherKey := range s.keys() {
+		if otherKey == k {
+			continue
+		}
+		fix := otherKey
+		other := s[otherKey]
+
+		for _, dependency := range other.depends {
+			if refKey(dependency) == k && !inRange(n.version, dependency.Version) {
+				result = append(result, violation{
+					id: fmt.Sprintf("depends %s %s", otherKey, k),
+					text: fmt.Sprintf("%s@%s depends on %s in version '%s', but it has version '%s'", otherKey, other.version, k,
+						dependency.Version, n.version),
+					fix: &fix,
+				})
+			}
+		}
+
+		for _, conflict := range n.conflicts {
+			if refKey(conflict) == otherKey && inRange(other.version, conflict.Version) {
+				result = append(result, violation{
+					id:   fmt.Sprintf("conflicts %s %s", k, otherKey),
+					text: fmt.Sprintf("%s@%s conflicts with %s@%s", k, n.version, otherKey, other.version),
+				})
+			}
+		}
+		for _, conflict := range other.conflicts {
+			if refKey(conflict) == k && inRange(n.version, conflict.Version) {
+				result = append(result, violation{
+					id:   fmt.Sprintf("conflicts %s %s", otherKey, k),
+					text: fmt.Sprintf("%s@%s conflicts with %s@%s", otherKey, other.version, k, n.version),
+				})
+			}
+		}
+	}
+
+	return result
+}
+
+// keys returns the features of the state sorted by namespace and name.
+func (s state) keys() []types.NamespacedName {
+	result := make([]types.NamespacedName, 0, len(s))
+	for k := range s {
+		result = append(result, k)
+	}
+
+	return sortKeys(result)
+}
+
+// inRange checks if the version is in the range. Invalid versions and ranges never match.
+func inRange(version string, versionRange string) bool {
+	r, err := versions.ParseRange(versionRange)
+	if err != nil {
+		return false
+	}
+
+	ok, err := r.Contains(version)
+	return err == nil && ok
+}
+
+func refKey(ref featuresv1alpha1.InstalledFeatureRef) types.NamespacedName {
+	return types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
+}
+
+func sortKeys(keys []types.NamespacedName) []types.NamespacedName {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Namespace != keys[j].Namespace {
+			return keys[i].Namespace < keys[j].Namespace
+		}
+		return keys[i].Name < keys[j].Name
+	})
+
+	return keys
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package planner_test
+
+import (
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/pkg/planner"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const namespace = "default"
+
+var _ = Describe("Planning upgrades", func() {
+	It("should plan a single upgrade", func() {
+		features := []InstalledFeature{
+			feature("db", "1.0.0"),
+			feature("app", "1.0.0", dependsOn("db", ">=1.0.0")),
+		}
+
+		status := Plan(features, namespace, []UpgradeTarget{target("db", "2.0.0")})
+
+		Expect(status).Should(Equal(UpgradePlanStatus{
+			Phase:   PhasePlanned,
+			Message: "1 upgrades planned",
+			Steps:   []UpgradeStep{{Namespace: namespace, Name: "db", From: "1.0.0", To: "2.0.0"}},
+		}))
+	})
+
+	It("should upgrade the dependencies first", func() {
+		features := []InstalledFeature{
+			feature("app", "1.0.0", dependsOn("db", ">=1.0.0")),
+			feature("db", "1.0.0"),
+		}
+
+		status := Plan(features, namespace, []UpgradeTarget{
+			target("app", "2.0.0", dependency("db", ">=2.0.0")),
+			target("db", "2.0.0"),
+		})
+
+		Expect(status.Phase).Should(Equal(PhasePlanned))
+		Expect(names(status.Steps)).Should(Equal([]string{"db", "app"}))
+	})
+
+	It("should upgrade a dependent first when the new dependency breaks the old version", func() {
+		features := []InstalledFeature{
+			feature("app", "1.0.0", dependsOn("db", "<2.0.0")),
+			feature("db", "1.0.0"),
+		}
+
+		status := Plan(features, namespace, []UpgradeTarget{
+			target("app", "2.0.0", dependency("db", ">=1.0.0")),
+			target("db", "2.0.0"),
+		})
+
+		Expect(status.Phase).Should(Equal(PhasePlanned))
+		Expect(names(status.Steps)).Should(Equal([]string{"app", "db"}))
+	})
+
+	It("should block an upgrade breaking a dependent that is not upgraded", func() {
+		features := []InstalledFeature{
+			feature("app", "1.0.0", dependsOn("db", "<2.0.0")),
+			feature("db", "1.0.0"),
+		}
+
+		status := Plan(features, namespace, []UpgradeTarget{target("db", "2.0.0")})
+
+		Expect(status.Phase).Should(Equal(PhaseBlocked))
+		Expect(status.Message).Should(Equal("1 of 1 upgrades blocked"))
+		Expect(status.Steps).Should(BeEmpty())
+		Expect(status.Blocked).Should(Equal([]BlockedUpgrade{{
+			Namespace: namespace,
+			Name:      "db",
+			Version:   "2.0.0",
+			Reasons: []string{
+				"default/app@1.0.0 depends on default/db in version '<2.0.0', but it has version '2.0.0' (upgrade default/app, too)",
+			},
+		}}))
+	})
+
+	It("should block an upgrade requiring a dependency that is not upgraded", func() {
+		features := []InstalledFeature{
+			feature("app", "1.0.0", dependsOn("db", ">=1.0.0")),
+			feature("db", "1.5.0"),
+		}
+
+		status := Plan(features, namespace, []UpgradeTarget{target("app", "2.0.0", dependency("db", ">=2.0.0"))})
+
+		Expect(status.Blocked).Should(HaveLen(1))
+		Expect(status.Blocked[0].Reasons).Should(Equal([]string{
+			"default/app@2.0.0 depends on default/db in version '>=2.0.0', but it has version '1.5.0' (upgrade default/db, too)",
+		}))
+	})
+
+	It("should block an upgrade into a conflict", func() {
+		features := []InstalledFeature{
+			feature("ingress", "1.0.0"),
+			feature("legacy", "1.0.0", conflictsWith("ingress", ">=2.0.0")),
+		}
+
+		status := Plan(features, namespace, []UpgradeTarget{target("ingress", "2.0.0")})
+
+		Expect(status.Blocked).Should(HaveLen(1))
+		Expect(status.Blocked[0].Reasons).Should(Equal([]string{"default/legacy@1.0.0 conflicts with default/ingress@2.0.0"}))
+	})
+
+	It("should block upgrades without a safe order", func() {
+		features := []InstalledFeature{
+			feature("app", "1.0.0", dependsOn("db", "<2.0.0")),
+			feature("db", "1.0.0"),
+		}
+
+		status := Plan(features, namespace, []UpgradeTarget{
+			target("app", "2.0.0", dependency("db", ">=2.0.0")),
+			target("db", "2.0.0"),
+		})
+
+		Expect(status.Phase).Should(Equal(PhaseBlocked))
+		Expect(status.Blocked).Should(HaveLen(2))
+		Expect(status.Blocked[0].Name).Should(Equal("app"))
+		Expect(status.Blocked[0].Reasons).Should(Equal([]string{
+			"default/app@2.0.0 depends on default/db in version '>=2.0.0', but it has version '1.0.0' " +
+				"(no order avoids this, upgrade the features together)",
+		}))
+	})
+
+	It("should not block an upgrade on violations that existed before", func() {
+		features := []InstalledFeature{
+			feature("app", "1.0.0", dependsOn("missing", "")),
+		}
+
+		status := Plan(features, namespace, []UpgradeTarget{target("app", "1.1.0")})
+
+		Expect(status.Phase).Should(Equal(PhasePlanned))
+		Expect(status.Steps).Should(HaveLen(1))
+	})
+
+	It("should block invalid targets and skip targets already reached", func() {
+		features := []InstalledFeature{
+			feature("app", "1.0.0"),
+			feature("db", "v2.0.0"),
+		}
+
+		status := Plan(features, namespace, []UpgradeTarget{
+			target("unknown", "1.0.0"),
+			target("app", "latest"),
+			target("db", "2.0.0"),
+		})
+
+		Expect(status.Steps).Should(BeEmpty())
+		Expect(status.Blocked).Should(HaveLen(2))
+		Expect(status.Blocked[0].Name).Should(Equal("app"))
+		Expect(status.Blocked[0].Reasons[0]).Should(HavePrefix("invalid version"))
+		Expect(status.Blocked[1].Reasons).Should(Equal([]string{"not installed"}))
+	})
+
+	It("should ignore deleted features", func() {
+		deleted := feature("app", "1.0.0", dependsOn("db", "<2.0.0"))
+		now := metav1.Now()
+		deleted.DeletionTimestamp = &now
+
+		status := Plan([]InstalledFeature{deleted, feature("db", "1.0.0")}, namespace, []UpgradeTarget{target("db", "2.0.0")})
+
+		Expect(status.Phase).Should(Equal(PhasePlanned))
+	})
+})
+
+type option func(feature *InstalledFeature)
+
+func feature(name string, version string, options ...option) InstalledFeature {
+	result := InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       InstalledFeatureSpec{Kind: name, Version: version},
+	}
+	for _, o := range options {
+		o(&result)
+	}
+
+	return result
+}
+
+func dependsOn(name string, version string) option {
+	return func(feature *InstalledFeature) {
+		feature.Spec.DependsOn = append(feature.Spec.DependsOn, dependency(name, version))
+	}
+}
+
+func dependency(name string, version string) InstalledFeatureRef {
+	return InstalledFeatureRef{Namespace: namespace, Name: name, Version: version}
+}
+
+func conflictsWith(name string, version string) option {
+	return func(feature *InstalledFeature) {
+		feature.Spec.Conflicts = append(feature.Spec.Conflicts, InstalledFeatureRef{Namespace: namespace, Name: name, Version: version})
+	}
+}
+
+func target(name string, version string, dependencies ...InstalledFeatureRef) UpgradeTarget {
+	return UpgradeTarget{Name: name, Version: version, DependsOn: dependencies}
+}
+
+func names(steps []UpgradeStep) []string {
+	result := make([]string, len(steps))
+	for i, step := range steps {
+		result[i] = step.Name
+	}
+
+	return result
+}
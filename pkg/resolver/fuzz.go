@@ -0,0 +1,63 @@
+//go:build gofuzz
+// +build gofuzz
+
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolver
+
+import (
+	"encoding/json"
+	"fmt"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+)
+
+// Fuzz is the entry point for go-fuzz. The input is a JSON object with the lists "features" and "groups". Every
+// feature and group has to get a status and resolving the computed result again has to be stable.
+func Fuzz(data []byte) int {
+	input := struct {
+		Features []featuresv1alpha1.InstalledFeature      `json:"features"`
+		Groups   []featuresv1alpha1.InstalledFeatureGroup `json:"groups"`
+	}{}
+	if err := json.Unmarshal(data, &input); err != nil {
+		return 0
+	}
+
+	result := Resolve(input.Features, input.Groups)
+	for i := range input.Features {
+		if _, ok := result.Features[key(input.Features[i].Namespace, input.Features[i].Name)]; !ok {
+			panic(fmt.Sprintf("no status for feature %s/%s", input.Features[i].Namespace, input.Features[i].Name))
+		}
+	}
+	for i := range input.Groups {
+		if _, ok := result.Groups[key(input.Groups[i].Namespace, input.Groups[i].Name)]; !ok {
+			panic(fmt.Sprintf("no status for group %s/%s", input.Groups[i].Namespace, input.Groups[i].Name))
+		}
+	}
+
+	for i := range input.Features {
+		input.Features[i].Status = result.FeatureStatus(&input.Features[i])
+	}
+	again := Resolve(input.Features, input.Groups)
+	for k, status := range result.Features {
+		if again.Features[k].Phase != status.Phase {
+			panic(fmt.Sprintf("unstable phase for feature %s", k))
+		}
+	}
+
+	return 1
+}
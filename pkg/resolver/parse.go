@@ -0,0 +1,149 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/versions"
+)
+
+// PrefixKind marks requirements of a kind instead of a named feature, e.g. "kind:dns".
+const PrefixKind = "kind:"
+
+// ParseRequires parses a list of requirements given as "[namespace/]name[@range]" or "kind:<kind>[@range]". The
+// requirements are separated by semicolons, newlines or whitespace. Version ranges may contain commas and whitespace
+// after operators, e.g. "postgres-operator@>= 1.5.0, < 2; cert-manager kind:dns".
+func ParseRequires(value string) ([]featuresv1alpha1.RequiredFeature, error) {
+	var result []featuresv1alpha1.RequiredFeature
+
+	for _, entry := range splitRequires(value) {
+		required, err := ParseRequire(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, required)
+	}
+
+	return result, nil
+}
+
+// ParseRequire parses a single requirement given as "[namespace/]name[@range]" or "kind:<kind>[@range]".
+func ParseRequire(value string) (featuresv1alpha1.RequiredFeature, error) {
+	entry := strings.TrimSpace(value)
+
+	required := featuresv1alpha1.RequiredFeature{}
+	if i := strings.IndexRune(entry, '@'); i >= 0 {
+		entry, required.Version = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+
+		if _, err := versions.ParseRange(required.Version); err != nil {
+			return featuresv1alpha1.RequiredFeature{}, err
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(entry, PrefixKind):
+		required.Kind = strings.TrimSpace(strings.TrimPrefix(entry, PrefixKind))
+	case strings.ContainsRune(entry, featuresv1alpha1.Separator):
+		i := strings.IndexRune(entry, featuresv1alpha1.Separator)
+		required.Namespace, required.Name = entry[:i], entry[i+1:]
+	default:
+		required.Name = entry
+	}
+
+	if required.Name == "" && required.Kind == "" {
+		return featuresv1alpha1.RequiredFeature{}, fmt.Errorf("no feature given in '%s'", value)
+	}
+
+	return required, nil
+}
+
+// ParseRefs parses a list of references to features like ParseRequires. References without namespace point to the
+// given namespace, kinds are not allowed.
+func ParseRefs(value string, namespace string) ([]featuresv1alpha1.InstalledFeatureRef, error) {
+	requires, err := ParseRequires(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []featuresv1alpha1.InstalledFeatureRef
+	for _, required := range requires {
+		if required.Name == "" {
+			return nil, fmt.Errorf("only features can be referenced, not kind '%s'", required.Kind)
+		}
+		if required.Namespace == "" {
+			required.Namespace = namespace
+		}
+
+		result = append(result, featuresv1alpha1.InstalledFeatureRef{
+			Namespace: required.Namespace,
+			Name:      required.Name,
+			Version:   required.Version,
+		})
+	}
+
+	return result, nil
+}
+
+// splitRequires splits a list of requirements. Whitespace only separates requirements when it is not part of a
+// version range: a field ending with "@", an operator or a comma is continued by the next one, a field starting with
+// one of them continues the previous one.
+func splitRequires(value string) []string {
+	var result []string
+
+	for _, entry := range strings.FieldsFunc(value, func(c rune) bool { return c == ';' || c == '\n' }) {
+		var current string
+		for _, field := range strings.Fields(entry) {
+			if current != "" && (continued(current) || continuing(field)) {
+				current += " " + field
+				continue
+			}
+
+			if current != "" {
+				result = append(result, current)
+			}
+			current = field
+		}
+
+		if current != "" {
+			result = append(result, current)
+		}
+	}
+
+	return result
+}
+
+// continued checks if the field ends within a version range.
+func continued(field string) bool {
+	last := rune(field[len(field)-1])
+
+	return last == ',' || last == '@' || isRangeOperator(last)
+}
+
+// continuing checks if the field continues a version range.
+func continuing(field string) bool {
+	first := rune(field[0])
+
+	return first == ',' || first == '@' || isRangeOperator(first)
+}
+
+func isRangeOperator(c rune) bool {
+	return strings.ContainsRune("<>=!", c)
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolver_test
+
+import (
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/pkg/resolver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Parsing requirements", func() {
+	It("should parse features, namespaces, kinds and version ranges", func() {
+		requires, err := ParseRequires("platform/postgres-operator@>=1.5.0,<2; cert-manager\n kind:dns @ >=1.7 ;")
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(requires).Should(Equal([]RequiredFeature{
+			{Namespace: "platform", Name: "postgres-operator", Version: ">=1.5.0,<2"},
+			{Name: "cert-manager"},
+			{Kind: "dns", Version: ">=1.7"},
+		}))
+	})
+
+	It("should separate requirements by whitespace outside of version ranges", func() {
+		requires, err := ParseRequires("a@>= 1.5 b@>=1.0, <2 c@>=1.0 <2.0 kind:dns")
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(requires).Should(Equal([]RequiredFeature{
+			{Name: "a", Version: ">= 1.5"},
+			{Name: "b", Version: ">=1.0, <2"},
+			{Name: "c", Version: ">=1.0 <2.0"},
+			{Kind: "dns"},
+		}))
+	})
+
+	It("should reject entries without feature", func() {
+		_, err := ParseRequires("@>=1.0.0")
+
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should reject invalid version ranges", func() {
+		_, err := ParseRequires("a@>=x")
+
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should point references without namespace to the given namespace", func() {
+		refs, err := ParseRefs("a\n b@>= 1.0.0;;other/c", "default")
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(refs).Should(Equal([]InstalledFeatureRef{
+			{Namespace: "default", Name: "a"},
+			{Namespace: "default", Name: "b", Version: ">= 1.0.0"},
+			{Namespace: "other", Name: "c"},
+		}))
+	})
+
+	It("should reject kinds as references", func() {
+		_, err := ParseRefs("kind:dns", "default")
+
+		Expect(err).Should(HaveOccurred())
+	})
+})
@@ -0,0 +1,155 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/versions"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ResolveRequirement computes the status of a feature requirement. The required features are checked with the rules
+// of dependencies: they have to be installed, must not be deleted and have to match the version range.
+func ResolveRequirement(features []featuresv1alpha1.InstalledFeature, requirement *featuresv1alpha1.FeatureRequirement) featuresv1alpha1.FeatureRequirementStatus {
+	status := featuresv1alpha1.FeatureRequirementStatus{
+		Phase:       featuresv1alpha1.PhaseSatisfied,
+		Unsatisfied: UnsatisfiedRequires(features, requirement.Namespace, requirement.Spec.Requires, false),
+	}
+
+	if len(status.Unsatisfied) > 0 {
+		status.Phase = featuresv1alpha1.PhaseUnsatisfied
+		status.Message = MessageMissingRequirements
+	}
+
+	return status
+}
+
+// UnsatisfiedRequires returns the required features not satisfied by the installed ones. Required features without
+// namespace are looked up in the given namespace. With provisioned set, the features have to be provisioned, too.
+func UnsatisfiedRequires(features []featuresv1alpha1.InstalledFeature, namespace string, requires []featuresv1alpha1.RequiredFeature, provisioned bool) []featuresv1alpha1.UnsatisfiedFeature {
+	installed := make(map[types.NamespacedName]*featuresv1alpha1.InstalledFeature, len(features))
+	for i := range features {
+		installed[key(features[i].Namespace, features[i].Name)] = &features[i]
+	}
+
+	var result []featuresv1alpha1.UnsatisfiedFeature
+	for _, required := range requires {
+		var reason string
+		if required.Name != "" {
+			if required.Namespace == "" {
+				required.Namespace = namespace
+			}
+
+			reason = unsatisfied(installed[key(required.Namespace, required.Name)], required.Version, provisioned)
+		} else {
+			reason = unsatisfiedKind(features, required, provisioned)
+		}
+
+		if reason != "" {
+			result = append(result, featuresv1alpha1.UnsatisfiedFeature{RequiredFeature: required, Reason: reason})
+		}
+	}
+
+	return result
+}
+
+// Unsatisfied returns why the feature does not satisfy the version range or the empty string if it does.
+func Unsatisfied(feature *featuresv1alpha1.InstalledFeature, versionRange string) string {
+	if feature == nil {
+		return "not installed"
+	}
+	if feature.DeletionTimestamp != nil {
+		return "being deleted"
+	}
+	if versionRange == "" {
+		return ""
+	}
+
+	r, err := versions.ParseRange(versionRange)
+	if err != nil {
+		return fmt.Sprintf("invalid version range '%s': %v", versionRange, err)
+	}
+
+	matches, err := r.Contains(feature.Spec.Version)
+	if err != nil {
+		return fmt.Sprintf("invalid version '%s': %v", feature.Spec.Version, err)
+	}
+	if !matches {
+		return fmt.Sprintf("version '%s' is not in range '%s'", feature.Spec.Version, versionRange)
+	}
+
+	return ""
+}
+
+// unsatisfied extends Unsatisfied by the check for the provisioned phase.
+func unsatisfied(feature *featuresv1alpha1.InstalledFeature, versionRange string, provisioned bool) string {
+	reason := Unsatisfied(feature, versionRange)
+	if reason == "" && provisioned && feature.Status.Phase != featuresv1alpha1.PhaseProvisioned {
+		reason = fmt.Sprintf("not provisioned, phase is '%s'", feature.Status.Phase)
+	}
+
+	return reason
+}
+
+// unsatisfiedKind returns why no feature of the required kind satisfies the requirement or the empty string if one
+// does.
+func unsatisfiedKind(features []featuresv1alpha1.InstalledFeature, required featuresv1alpha1.RequiredFeature, provisioned bool) string {
+	var reasons []string
+	for i := range features {
+		if features[i].Spec.Kind != required.Kind {
+			continue
+		}
+
+		reason := unsatisfied(&features[i], required.Version, provisioned)
+		if reason == "" {
+			return ""
+		}
+
+		reasons = append(reasons, fmt.Sprintf("%s%c%s %s", features[i].Namespace, featuresv1alpha1.Separator, features[i].Name, reason))
+	}
+
+	if len(reasons) == 0 {
+		return "no feature of this kind installed"
+	}
+
+	return fmt.Sprintf("no feature of this kind satisfies it: %s", strings.Join(reasons, "; "))
+}
+
+// RequiresFeature checks if the requirement refers to the feature by name or kind.
+func RequiresFeature(requirement *featuresv1alpha1.FeatureRequirement, feature *featuresv1alpha1.InstalledFeature) bool {
+	for _, required := range requirement.Spec.Requires {
+		if required.Name == "" {
+			if required.Kind == feature.Spec.Kind {
+				return true
+			}
+			continue
+		}
+
+		namespace := required.Namespace
+		if namespace == "" {
+			namespace = requirement.Namespace
+		}
+		if namespace == feature.Namespace && required.Name == feature.Name {
+			return true
+		}
+	}
+
+	return false
+}
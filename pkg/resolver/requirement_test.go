@@ -0,0 +1,122 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolver_test
+
+import (
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/pkg/resolver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Resolving feature requirements", func() {
+	requirement := func(requires ...RequiredFeature) *FeatureRequirement {
+		return &FeatureRequirement{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "my-app"},
+			Spec:       FeatureRequirementSpec{Requires: requires},
+		}
+	}
+
+	withKind := func(kind string) option {
+		return func(feature *InstalledFeature) {
+			feature.Spec.Kind = kind
+		}
+	}
+
+	It("should be satisfied by installed features in the version range", func() {
+		features := []InstalledFeature{*feature("postgres-operator", "1.6.0"), *feature("cert-manager", "1.0.0")}
+
+		status := ResolveRequirement(features, requirement(
+			RequiredFeature{Name: "postgres-operator", Version: ">=1.5.0"},
+			RequiredFeature{Namespace: namespace, Name: "cert-manager"},
+		))
+
+		Expect(status).Should(Equal(FeatureRequirementStatus{Phase: PhaseSatisfied}))
+	})
+
+	It("should report missing, deleted and mismatching features", func() {
+		features := []InstalledFeature{*feature("postgres-operator", "1.4.2"), *feature("cert-manager", "1.0.0", deleted)}
+
+		status := ResolveRequirement(features, requirement(
+			RequiredFeature{Name: "postgres-operator", Version: ">=1.5.0"},
+			RequiredFeature{Name: "cert-manager"},
+			RequiredFeature{Name: "monitoring"},
+		))
+
+		Expect(status).Should(Equal(FeatureRequirementStatus{
+			Phase:   PhaseUnsatisfied,
+			Message: MessageMissingRequirements,
+			Unsatisfied: []UnsatisfiedFeature{
+				{
+					RequiredFeature: RequiredFeature{Namespace: namespace, Name: "postgres-operator", Version: ">=1.5.0"},
+					Reason:          "version '1.4.2' is not in range '>=1.5.0'",
+				},
+				{RequiredFeature: RequiredFeature{Namespace: namespace, Name: "cert-manager"}, Reason: "being deleted"},
+				{RequiredFeature: RequiredFeature{Namespace: namespace, Name: "monitoring"}, Reason: "not installed"},
+			},
+		}))
+	})
+
+	It("should be satisfied by any feature of a required kind", func() {
+		features := []InstalledFeature{*feature("kube-dns", "1.0.0", withKind("dns")), *feature("coredns", "1.8.0", withKind("dns"))}
+
+		status := ResolveRequirement(features, requirement(RequiredFeature{Kind: "dns", Version: ">=1.7.0"}))
+
+		Expect(status.Phase).Should(Equal(PhaseSatisfied))
+	})
+
+	It("should explain unsatisfied kinds", func() {
+		features := []InstalledFeature{*feature("kube-dns", "1.0.0", withKind("dns"))}
+
+		status := ResolveRequirement(features, requirement(
+			RequiredFeature{Kind: "dns", Version: ">=1.7.0"},
+			RequiredFeature{Kind: "cni"},
+		))
+
+		Expect(status.Phase).Should(Equal(PhaseUnsatisfied))
+		Expect(status.Unsatisfied).Should(Equal([]UnsatisfiedFeature{
+			{
+				RequiredFeature: RequiredFeature{Kind: "dns", Version: ">=1.7.0"},
+				Reason:          "no feature of this kind satisfies it: default/kube-dns version '1.0.0' is not in range '>=1.7.0'",
+			},
+			{RequiredFeature: RequiredFeature{Kind: "cni"}, Reason: "no feature of this kind installed"},
+		}))
+	})
+
+	It("should require provisioned features when asked to", func() {
+		pending := feature("postgres-operator", "1.6.0")
+		pending.Status.Phase = PhasePending
+		provisioned := feature("coredns", "1.8.0", withKind("dns"))
+		provisioned.Status.Phase = PhaseProvisioned
+		features := []InstalledFeature{*pending, *provisioned}
+		requires := []RequiredFeature{{Name: "postgres-operator"}, {Kind: "dns"}}
+
+		Expect(UnsatisfiedRequires(features, namespace, requires, false)).Should(BeEmpty())
+		Expect(UnsatisfiedRequires(features, namespace, requires, true)).Should(Equal([]UnsatisfiedFeature{
+			{RequiredFeature: RequiredFeature{Namespace: namespace, Name: "postgres-operator"}, Reason: "not provisioned, phase is 'pending'"},
+		}))
+	})
+
+	It("should match the required features by name and kind", func() {
+		sut := requirement(RequiredFeature{Name: "postgres-operator"}, RequiredFeature{Kind: "dns"})
+
+		Expect(RequiresFeature(sut, feature("postgres-operator", "1.0.0"))).Should(BeTrue())
+		Expect(RequiresFeature(sut, feature("coredns", "1.0.0", withKind("dns")))).Should(BeTrue())
+		Expect(RequiresFeature(sut, feature("cert-manager", "1.0.0"))).Should(BeFalse())
+	})
+})
@@ -0,0 +1,295 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package resolver computes the status of features and feature groups from their specs. It is free of side effects:
+// it is fed with the complete set of features and groups and returns the status every single one should have. The
+// reconcilers only load the objects, call the resolver and write the differing states back.
+package resolver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	featuresv1alpha1 "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	"github.com/klenkes74/k8s-installed-features-catalogue/pkg/versions"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// MessageMissingDependencies is the status message of features with missing dependencies.
+	MessageMissingDependencies = "dependencies are missing"
+	// MessageConflictingFeatures is the status message of features with installed conflicting features.
+	MessageConflictingFeatures = "conflicting features are installed"
+	// MessageMissingAPIs is the status message of features whose provided APIs are not served.
+	MessageMissingAPIs = "provided APIs are not served"
+	// MessageIncompatibleKubernetes is the status message of features not working with the Kubernetes version.
+	MessageIncompatibleKubernetes = "not compatible with the Kubernetes version"
+	// MessageNoWorkloads is the status message of degraded features without any selected workload.
+	MessageNoWorkloads = "no workloads selected"
+	// MessageMissingRequirements is the status message of unsatisfied feature requirements.
+	MessageMissingRequirements = "required features are missing"
+)
+
+// Result contains the computed status of all features and groups.
+type Result struct {
+	Features map[types.NamespacedName]featuresv1alpha1.InstalledFeatureStatus
+	Groups   map[types.NamespacedName]featuresv1alpha1.InstalledFeatureGroupStatus
+}
+
+// FeatureStatus returns the computed status of the feature.
+func (r *Result) FeatureStatus(feature *featuresv1alpha1.InstalledFeature) featuresv1alpha1.InstalledFeatureStatus {
+	return r.Features[types.NamespacedName{Namespace: feature.Namespace, Name: feature.Name}]
+}
+
+// GroupStatus returns the computed status of the group.
+func (r *Result) GroupStatus(group *featuresv1alpha1.InstalledFeatureGroup) featuresv1alpha1.InstalledFeatureGroupStatus {
+	return r.Groups[types.NamespacedName{Namespace: group.Namespace, Name: group.Name}]
+}
+
+// Resolve computes the status of all given features and groups. Features being deleted are still resolved, but they
+// don't satisfy dependencies, conflict with other features or are listed as dependent features or group members.
+// Conflicts and missing dependencies take precedence over missing provided APIs and an incompatible Kubernetes version,
+// which take precedence over a failed or initializing phase reported by the source of a discovered feature. Features
+// with unavailable workloads are degraded. Provisioned features whose images run in another version than the declared
+// one report the drift in their message.
+// The current status of the objects is ignored, so the result only depends on the specs. The only exceptions are the
+// missing APIs, the workloads and the conditions: they are checked against the cluster by the feature reconciler and
+// taken over from the status. When a feature or group is given more than once, the last one wins.
+func Resolve(features []featuresv1alpha1.InstalledFeature, groups []featuresv1alpha1.InstalledFeatureGroup) *Result {
+	installed := make(map[types.NamespacedName]*featuresv1alpha1.InstalledFeature, len(features))
+	for i := range features {
+		installed[key(features[i].Namespace, features[i].Name)] = &features[i]
+	}
+
+	result := &Result{
+		Features: make(map[types.NamespacedName]featuresv1alpha1.InstalledFeatureStatus, len(installed)),
+		Groups:   make(map[types.NamespacedName]featuresv1alpha1.InstalledFeatureGroupStatus, len(groups)),
+	}
+
+	dependents := make(map[types.NamespacedName][]featuresv1alpha1.InstalledFeatureRef)
+	members := make(map[types.NamespacedName][]featuresv1alpha1.InstalledFeatureGroupListedFeature)
+	for k, feature := range installed {
+		if feature.DeletionTimestamp != nil {
+			continue
+		}
+
+		seen := make(map[types.NamespacedName]bool, len(feature.Spec.DependsOn))
+		for _, dependency := range feature.Spec.DependsOn {
+			dependencyKey := refKey(dependency)
+			if seen[dependencyKey] {
+				continue
+			}
+			seen[dependencyKey] = true
+
+			dependents[dependencyKey] = append(dependents[dependencyKey], featuresv1alpha1.InstalledFeatureRef{
+				Namespace: k.Namespace,
+				Name:      k.Name,
+			})
+		}
+
+		if feature.Spec.Group != nil {
+			groupKey := refKey(*feature.Spec.Group)
+			members[groupKey] = append(members[groupKey], featuresv1alpha1.InstalledFeatureGroupListedFeature{
+				Namespace: k.Namespace,
+				Name:      k.Name,
+			})
+		}
+	}
+
+	for k, feature := range installed {
+		status := featuresv1alpha1.InstalledFeatureStatus{
+			MissingDependencies: missingDependencies(feature, installed),
+			ConflictingFeatures: conflictingFeatures(feature, installed),
+			DependingFeatures:   sortRefs(dependents[k]),
+			MissingAPIs:         feature.Status.MissingAPIs,
+			Conditions:          feature.Status.Conditions,
+		}
+		if feature.Spec.Workloads != nil {
+			status.Workloads = feature.Status.Workloads
+			status.VersionDrift = hasVersionDrift(feature, status.Workloads)
+			status.ObservedVersion = observedVersion(feature, status.Workloads)
+		}
+
+		switch {
+		case len(status.ConflictingFeatures) > 0:
+			status.Phase = featuresv1alpha1.PhaseFailed
+			status.Message = MessageConflictingFeatures
+		case len(status.MissingDependencies) > 0:
+			status.Phase = featuresv1alpha1.PhasePending
+			status.Message = MessageMissingDependencies
+		case len(status.MissingAPIs) > 0:
+			status.Phase = featuresv1alpha1.PhaseFailed
+			status.Message = MessageMissingAPIs
+		case isConditionFalse(feature, featuresv1alpha1.ConditionKubernetesCompatible):
+			status.Phase = featuresv1alpha1.PhaseFailed
+			status.Message = MessageIncompatibleKubernetes
+		case isSourcePhase(feature, featuresv1alpha1.PhaseFailed), isSourcePhase(feature, featuresv1alpha1.PhaseInitializing):
+			status.Phase = feature.Annotations[featuresv1alpha1.AnnotationSourcePhase]
+			status.Message = feature.Annotations[featuresv1alpha1.AnnotationSourceMessage]
+		case status.Workloads != nil && !status.Workloads.Available():
+			status.Phase = featuresv1alpha1.PhaseDegraded
+			status.Message = degradedMessage(status.Workloads)
+		default:
+			status.Phase = featuresv1alpha1.PhaseProvisioned
+			if status.VersionDrift {
+				status.Message = fmt.Sprintf("version drift: declared %s, running %s", feature.Spec.Version,
+					strings.Join(status.Workloads.Versions, ", "))
+			}
+		}
+
+		result.Features[k] = status
+	}
+
+	for i := range groups {
+		k := key(groups[i].Namespace, groups[i].Name)
+		result.Groups[k] = featuresv1alpha1.InstalledFeatureGroupStatus{
+			Phase:    featuresv1alpha1.PhaseProvisioned,
+			Features: sortMembers(members[k]),
+		}
+	}
+
+	return result
+}
+
+// degradedMessage explains why the workloads are not available.
+func degradedMessage(workloads *featuresv1alpha1.WorkloadStatus) string {
+	if workloads.Workloads == 0 {
+		return MessageNoWorkloads
+	}
+
+	return fmt.Sprintf("%d of %d replicas ready", workloads.ReadyReplicas, workloads.DesiredReplicas)
+}
+
+// hasVersionDrift checks if the images of the feature run in another version than the declared one.
+func hasVersionDrift(feature *featuresv1alpha1.InstalledFeature, workloads *featuresv1alpha1.WorkloadStatus) bool {
+	if workloads == nil {
+		return false
+	}
+
+	for _, version := range workloads.Versions {
+		if !versions.Equivalent(version, feature.Spec.Version) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// observedVersion returns the version the images of the feature run in if it is observed. During a rollout with more
+// than one version running, nothing is observed.
+func observedVersion(feature *featuresv1alpha1.InstalledFeature, workloads *featuresv1alpha1.WorkloadStatus) string {
+	if !feature.Spec.Workloads.ObserveVersion || workloads == nil || len(workloads.Versions) != 1 {
+		return ""
+	}
+
+	return workloads.Versions[0]
+}
+
+// isConditionFalse checks if the feature has the condition with status False.
+func isConditionFalse(feature *featuresv1alpha1.InstalledFeature, conditionType string) bool {
+	condition := featuresv1alpha1.FindCondition(feature.Status.Conditions, conditionType)
+	return condition != nil && condition.Status == metav1.ConditionFalse
+}
+
+// isSourcePhase checks if the source of the feature reports the given phase.
+func isSourcePhase(feature *featuresv1alpha1.InstalledFeature, phase string) bool {
+	return feature.Annotations[featuresv1alpha1.AnnotationSourcePhase] == phase
+}
+
+// Satisfies checks if the feature is installed and matches the version range of the reference.
+func Satisfies(feature *featuresv1alpha1.InstalledFeature, ref featuresv1alpha1.InstalledFeatureRef) bool {
+	return Unsatisfied(feature, ref.Version) == ""
+}
+
+func missingDependencies(feature *featuresv1alpha1.InstalledFeature, installed map[types.NamespacedName]*featuresv1alpha1.InstalledFeature) []featuresv1alpha1.InstalledFeatureRef {
+	var result []featuresv1alpha1.InstalledFeatureRef
+
+	for _, dependency := range unique(feature.Spec.DependsOn) {
+		if !Satisfies(installed[refKey(dependency)], dependency) {
+			result = append(result, dependency)
+		}
+	}
+
+	return result
+}
+
+func conflictingFeatures(feature *featuresv1alpha1.InstalledFeature, installed map[types.NamespacedName]*featuresv1alpha1.InstalledFeature) []featuresv1alpha1.InstalledFeatureRef {
+	var result []featuresv1alpha1.InstalledFeatureRef
+
+	for _, conflict := range unique(feature.Spec.Conflicts) {
+		if refKey(conflict) == key(feature.Namespace, feature.Name) {
+			continue // a feature does not conflict with itself
+		}
+
+		if Satisfies(installed[refKey(conflict)], conflict) {
+			result = append(result, conflict)
+		}
+	}
+
+	return result
+}
+
+// unique removes identical references. References to the same feature with different version ranges are kept, since
+// every single range has to be checked.
+func unique(refs []featuresv1alpha1.InstalledFeatureRef) []featuresv1alpha1.InstalledFeatureRef {
+	seen := make(map[featuresv1alpha1.InstalledFeatureRef]bool, len(refs))
+	result := make([]featuresv1alpha1.InstalledFeatureRef, 0, len(refs))
+
+	for _, ref := range refs {
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+
+		result = append(result, ref)
+	}
+
+	return result
+}
+
+func sortRefs(refs []featuresv1alpha1.InstalledFeatureRef) []featuresv1alpha1.InstalledFeatureRef {
+	sort.Slice(refs, func(i, j int) bool {
+		return less(refs[i].Namespace, refs[i].Name, refs[j].Namespace, refs[j].Name)
+	})
+
+	return refs
+}
+
+func sortMembers(members []featuresv1alpha1.InstalledFeatureGroupListedFeature) []featuresv1alpha1.InstalledFeatureGroupListedFeature {
+	sort.Slice(members, func(i, j int) bool {
+		return less(members[i].Namespace, members[i].Name, members[j].Namespace, members[j].Name)
+	})
+
+	return members
+}
+
+func less(namespaceA, nameA, namespaceB, nameB string) bool {
+	if namespaceA != namespaceB {
+		return namespaceA < namespaceB
+	}
+
+	return nameA < nameB
+}
+
+func key(namespace string, name string) types.NamespacedName {
+	return types.NamespacedName{Namespace: namespace, Name: name}
+}
+
+func refKey(ref featuresv1alpha1.InstalledFeatureRef) types.NamespacedName {
+	return key(ref.Namespace, ref.Name)
+}
@@ -0,0 +1,253 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolver_test
+
+import (
+	"math/rand"
+	"sort"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/pkg/resolver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// The fuzz tests resolve randomly generated catalogues and check the invariants of the result. The seed is the ginkgo
+// random seed, so a failing run can be repeated with --seed.
+var _ = Describe("Fuzzing the resolver", func() {
+	const runs = 500
+
+	var random *rand.Rand
+
+	BeforeEach(func() {
+		random = rand.New(rand.NewSource(GinkgoRandomSeed()))
+	})
+
+	It("should compute a consistent status for every feature", func() {
+		for i := 0; i < runs; i++ {
+			features, groups := randomCatalogue(random)
+			result := Resolve(features, groups)
+
+			installed := make(map[types.NamespacedName]*InstalledFeature)
+			for j := range features {
+				installed[types.NamespacedName{Namespace: features[j].Namespace, Name: features[j].Name}] = &features[j]
+			}
+			Expect(result.Features).Should(HaveLen(len(installed)))
+
+			for _, feature := range installed {
+				status := result.FeatureStatus(feature)
+
+				checkMissingDependencies(feature, status, installed)
+				checkConflictingFeatures(feature, status, installed)
+				checkDependingFeatures(feature, status, installed)
+				checkPhase(status)
+			}
+		}
+	})
+
+	It("should list exactly the installed members of every group", func() {
+		for i := 0; i < runs; i++ {
+			features, groups := randomCatalogue(random)
+			result := Resolve(features, groups)
+
+			for j := range groups {
+				status := result.GroupStatus(&groups[j])
+
+				expected := make([]InstalledFeatureGroupListedFeature, 0)
+				for _, feature := range features {
+					if feature.DeletionTimestamp == nil && feature.Spec.Group != nil &&
+						feature.Spec.Group.Namespace == groups[j].Namespace && feature.Spec.Group.Name == groups[j].Name {
+						expected = append(expected, InstalledFeatureGroupListedFeature{Namespace: feature.Namespace, Name: feature.Name})
+					}
+				}
+
+				Expect(status.Phase).Should(Equal(PhaseProvisioned))
+				Expect(status.Features).Should(ConsistOf(expected))
+				Expect(sort.SliceIsSorted(status.Features, func(a, b int) bool {
+					return lessRef(status.Features[a].Namespace, status.Features[a].Name, status.Features[b].Namespace, status.Features[b].Name)
+				})).Should(BeTrue())
+			}
+		}
+	})
+
+	It("should not depend on the order of the input", func() {
+		for i := 0; i < runs; i++ {
+			features, groups := randomCatalogue(random)
+			expected := Resolve(features, groups)
+
+			random.Shuffle(len(features), func(a, b int) { features[a], features[b] = features[b], features[a] })
+			random.Shuffle(len(groups), func(a, b int) { groups[a], groups[b] = groups[b], groups[a] })
+
+			Expect(Resolve(features, groups)).Should(Equal(expected))
+		}
+	})
+
+	It("should be idempotent when the computed status is written back", func() {
+		for i := 0; i < runs; i++ {
+			features, groups := randomCatalogue(random)
+			expected := Resolve(features, groups)
+
+			for j := range features {
+				features[j].Status = expected.FeatureStatus(&features[j])
+			}
+			for j := range groups {
+				groups[j].Status = expected.GroupStatus(&groups[j])
+			}
+
+			Expect(Resolve(features, groups)).Should(Equal(expected))
+		}
+	})
+})
+
+func checkMissingDependencies(feature *InstalledFeature, status InstalledFeatureStatus, installed map[types.NamespacedName]*InstalledFeature) {
+	missing := make(map[InstalledFeatureRef]bool)
+	for _, dependency := range status.MissingDependencies {
+		Expect(missing[dependency]).Should(BeFalse(), "missing dependency %v listed twice", dependency)
+		missing[dependency] = true
+
+		Expect(feature.Spec.DependsOn).Should(ContainElement(dependency))
+	}
+
+	for _, dependency := range feature.Spec.DependsOn {
+		k := types.NamespacedName{Namespace: dependency.Namespace, Name: dependency.Name}
+		Expect(Satisfies(installed[k], dependency)).ShouldNot(Equal(missing[dependency]),
+			"dependency %v of %v is listed wrongly", dependency, feature.Name)
+	}
+}
+
+func checkConflictingFeatures(feature *InstalledFeature, status InstalledFeatureStatus, installed map[types.NamespacedName]*InstalledFeature) {
+	for _, conflict := range status.ConflictingFeatures {
+		k := types.NamespacedName{Namespace: conflict.Namespace, Name: conflict.Name}
+
+		Expect(feature.Spec.Conflicts).Should(ContainElement(conflict))
+		Expect(k).ShouldNot(Equal(types.NamespacedName{Namespace: feature.Namespace, Name: feature.Name}))
+		Expect(Satisfies(installed[k], conflict)).Should(BeTrue())
+	}
+}
+
+func checkDependingFeatures(feature *InstalledFeature, status InstalledFeatureStatus, installed map[types.NamespacedName]*InstalledFeature) {
+	expected := make([]InstalledFeatureRef, 0)
+	for k, other := range installed {
+		if other.DeletionTimestamp != nil {
+			continue
+		}
+
+		for _, dependency := range other.Spec.DependsOn {
+			if dependency.Namespace == feature.Namespace && dependency.Name == feature.Name {
+				expected = append(expected, InstalledFeatureRef{Namespace: k.Namespace, Name: k.Name})
+				break
+			}
+		}
+	}
+
+	Expect(status.DependingFeatures).Should(ConsistOf(expected))
+	Expect(sort.SliceIsSorted(status.DependingFeatures, func(a, b int) bool {
+		return lessRef(status.DependingFeatures[a].Namespace, status.DependingFeatures[a].Name,
+			status.DependingFeatures[b].Namespace, status.DependingFeatures[b].Name)
+	})).Should(BeTrue())
+}
+
+func checkPhase(status InstalledFeatureStatus) {
+	switch {
+	case len(status.ConflictingFeatures) > 0:
+		Expect(status.Phase).Should(Equal(PhaseFailed))
+		Expect(status.Message).Should(Equal(MessageConflictingFeatures))
+	case len(status.MissingDependencies) > 0:
+		Expect(status.Phase).Should(Equal(PhasePending))
+		Expect(status.Message).Should(Equal(MessageMissingDependencies))
+	default:
+		Expect(status.Phase).Should(Equal(PhaseProvisioned))
+		Expect(status.Message).Should(BeEmpty())
+	}
+}
+
+var (
+	fuzzNamespaces = []string{"default", "other"}
+	fuzzNames      = []string{"alpha", "beta", "gamma", "delta", "epsilon", "zeta"}
+	fuzzVersions   = []string{"1.0.0", "1.5.3", "2.0.0", "2.0.0-alpha1", "v1.20", "latest", ""}
+	fuzzRanges     = []string{"", "", ">=1.0.0", "<2.0.0", ">=1.5, <2", "!=1.5.3", "=2.0.0", ">=latest"}
+)
+
+// randomCatalogue creates a small catalogue with a high density of relations, duplicate references, deleted features
+// and invalid versions. Like in the cluster, every feature and group exists only once.
+func randomCatalogue(random *rand.Rand) ([]InstalledFeature, []InstalledFeatureGroup) {
+	features := make([]InstalledFeature, 0)
+	seenFeatures := make(map[types.NamespacedName]bool)
+	for i := random.Intn(12); i > 0; i-- {
+		feature := feature(pick(random, fuzzNames), pick(random, fuzzVersions))
+		feature.Namespace = pick(random, fuzzNamespaces)
+
+		k := types.NamespacedName{Namespace: feature.Namespace, Name: feature.Name}
+		if seenFeatures[k] {
+			continue
+		}
+		seenFeatures[k] = true
+
+		for j := random.Intn(4); j > 0; j-- {
+			feature.Spec.DependsOn = append(feature.Spec.DependsOn, randomRef(random))
+		}
+		for j := random.Intn(3); j > 0; j-- {
+			feature.Spec.Conflicts = append(feature.Spec.Conflicts, randomRef(random))
+		}
+		if random.Intn(2) == 0 {
+			group := randomRef(random)
+			group.Version = ""
+			feature.Spec.Group = &group
+		}
+		if random.Intn(5) == 0 {
+			deleted(feature)
+		}
+
+		features = append(features, *feature)
+	}
+
+	groups := make([]InstalledFeatureGroup, 0)
+	seenGroups := make(map[types.NamespacedName]bool)
+	for i := random.Intn(4); i > 0; i-- {
+		group := group(pick(random, fuzzNames))
+		group.Namespace = pick(random, fuzzNamespaces)
+
+		k := types.NamespacedName{Namespace: group.Namespace, Name: group.Name}
+		if !seenGroups[k] {
+			seenGroups[k] = true
+			groups = append(groups, *group)
+		}
+	}
+
+	return features, groups
+}
+
+func randomRef(random *rand.Rand) InstalledFeatureRef {
+	return InstalledFeatureRef{
+		Namespace: pick(random, fuzzNamespaces),
+		Name:      pick(random, fuzzNames),
+		Version:   pick(random, fuzzRanges),
+	}
+}
+
+func pick(random *rand.Rand, values []string) string {
+	return values[random.Intn(len(values))]
+}
+
+func lessRef(namespaceA, nameA, namespaceB, nameB string) bool {
+	if namespaceA != namespaceB {
+		return namespaceA < namespaceB
+	}
+
+	return nameA < nameB
+}
@@ -0,0 +1,592 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolver_test
+
+import (
+	"time"
+
+	. "github.com/klenkes74/k8s-installed-features-catalogue/api/v1alpha1"
+	. "github.com/klenkes74/k8s-installed-features-catalogue/pkg/resolver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const namespace = "default"
+
+var _ = Describe("Resolving features", func() {
+	Context("without any relations", func() {
+		It("should provision a single feature", func() {
+			basic := feature("basic", "1.0.0")
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic)).Should(Equal(InstalledFeatureStatus{Phase: PhaseProvisioned}))
+		})
+
+		It("should return an empty result for no input", func() {
+			result := Resolve(nil, nil)
+
+			Expect(result.Features).Should(BeEmpty())
+			Expect(result.Groups).Should(BeEmpty())
+		})
+
+		It("should ignore the current status", func() {
+			basic := feature("basic", "1.0.0")
+			basic.Status = InstalledFeatureStatus{
+				Phase:               PhaseFailed,
+				Message:             "stale",
+				MissingDependencies: []InstalledFeatureRef{ref("other")},
+				DependingFeatures:   []InstalledFeatureRef{ref("third")},
+			}
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic)).Should(Equal(InstalledFeatureStatus{Phase: PhaseProvisioned}))
+		})
+
+		It("should let the last feature win when a feature is given twice", func() {
+			first := feature("basic", "1.0.0")
+			second := feature("basic", "1.0.0")
+			second.Spec.DependsOn = []InstalledFeatureRef{ref("other")}
+
+			result := Resolve([]InstalledFeature{*first, *second}, nil)
+
+			Expect(result.Features).Should(HaveLen(1))
+			Expect(result.FeatureStatus(first).Phase).Should(Equal(PhasePending))
+		})
+	})
+
+	Context("with a phase reported by the source", func() {
+		It("should take over a failed source phase with its message", func() {
+			basic := feature("basic", "1.0.0", sourcePhase(PhaseFailed, "install strategy failed"))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic)).Should(Equal(InstalledFeatureStatus{Phase: PhaseFailed, Message: "install strategy failed"}))
+		})
+
+		It("should take over an initializing source phase", func() {
+			basic := feature("basic", "1.0.0", sourcePhase(PhaseInitializing, ""))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhaseInitializing))
+		})
+
+		It("should ignore unknown source phases", func() {
+			basic := feature("basic", "1.0.0", sourcePhase("Replacing", "being replaced"))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic)).Should(Equal(InstalledFeatureStatus{Phase: PhaseProvisioned}))
+		})
+
+		It("should report missing dependencies before the source phase", func() {
+			basic := feature("basic", "1.0.0", dependsOn("other"), sourcePhase(PhaseFailed, "install strategy failed"))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhasePending))
+		})
+	})
+
+	Context("with dependencies", func() {
+		It("should provision the feature and list it at the dependency", func() {
+			basic := feature("basic", "1.0.0", dependsOn("other"))
+			other := feature("other", "1.0.0")
+
+			result := Resolve([]InstalledFeature{*basic, *other}, nil)
+
+			Expect(result.FeatureStatus(basic)).Should(Equal(InstalledFeatureStatus{Phase: PhaseProvisioned}))
+			Expect(result.FeatureStatus(other)).Should(Equal(InstalledFeatureStatus{
+				Phase:             PhaseProvisioned,
+				DependingFeatures: []InstalledFeatureRef{ref("basic")},
+			}))
+		})
+
+		It("should mark missing dependencies in the order of the spec", func() {
+			basic := feature("basic", "1.0.0", dependsOn("zeta"), dependsOn("alpha"))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic)).Should(Equal(InstalledFeatureStatus{
+				Phase:               PhasePending,
+				Message:             MessageMissingDependencies,
+				MissingDependencies: []InstalledFeatureRef{ref("zeta"), ref("alpha")},
+			}))
+		})
+
+		It("should list a missing dependency only once", func() {
+			basic := feature("basic", "1.0.0", dependsOn("other"), dependsOn("other"))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic).MissingDependencies).Should(Equal([]InstalledFeatureRef{ref("other")}))
+		})
+
+		It("should check every version range given for the same dependency", func() {
+			basic := feature("basic", "1.0.0", dependsOnVersion("other", ">=1.0.0"), dependsOnVersion("other", ">=2.0.0"))
+			other := feature("other", "1.5.0")
+
+			result := Resolve([]InstalledFeature{*basic, *other}, nil)
+
+			Expect(result.FeatureStatus(basic).MissingDependencies).Should(Equal(basic.Spec.DependsOn[1:]))
+		})
+
+		It("should list a depending feature only once", func() {
+			basic := feature("basic", "1.0.0", dependsOn("other"), dependsOn("other"))
+			other := feature("other", "1.0.0")
+
+			result := Resolve([]InstalledFeature{*basic, *other}, nil)
+
+			Expect(result.FeatureStatus(other).DependingFeatures).Should(Equal([]InstalledFeatureRef{ref("basic")}))
+		})
+
+		It("should sort the depending features", func() {
+			other := feature("other", "1.0.0")
+			zeta := feature("zeta", "1.0.0", dependsOn("other"))
+			alpha := feature("alpha", "1.0.0", dependsOn("other"))
+			foreign := feature("foreign", "1.0.0", dependsOn("other"))
+			foreign.Namespace = "another"
+
+			result := Resolve([]InstalledFeature{*zeta, *other, *foreign, *alpha}, nil)
+
+			Expect(result.FeatureStatus(other).DependingFeatures).Should(Equal([]InstalledFeatureRef{
+				{Namespace: "another", Name: "foreign"},
+				ref("alpha"),
+				ref("zeta"),
+			}))
+		})
+
+		It("should mark the dependency as missing when it is deleted", func() {
+			basic := feature("basic", "1.0.0", dependsOn("other"))
+			other := feature("other", "1.0.0", deleted)
+
+			result := Resolve([]InstalledFeature{*basic, *other}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhasePending))
+			Expect(result.FeatureStatus(basic).MissingDependencies).Should(Equal([]InstalledFeatureRef{ref("other")}))
+			Expect(result.FeatureStatus(other).DependingFeatures).Should(Equal([]InstalledFeatureRef{ref("basic")}))
+		})
+
+		It("should not list deleted features as depending features", func() {
+			basic := feature("basic", "1.0.0", dependsOn("other"), deleted)
+			other := feature("other", "1.0.0")
+
+			result := Resolve([]InstalledFeature{*basic, *other}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhaseProvisioned))
+			Expect(result.FeatureStatus(other).DependingFeatures).Should(BeEmpty())
+		})
+
+		It("should resolve dependencies in other namespaces", func() {
+			basic := feature("basic", "1.0.0")
+			basic.Spec.DependsOn = []InstalledFeatureRef{{Namespace: "another", Name: "other"}}
+			other := feature("other", "1.0.0")
+
+			result := Resolve([]InstalledFeature{*basic, *other}, nil)
+
+			Expect(result.FeatureStatus(basic).MissingDependencies).Should(Equal(basic.Spec.DependsOn))
+			Expect(result.FeatureStatus(other).DependingFeatures).Should(BeEmpty())
+		})
+
+		It("should resolve cyclic dependencies", func() {
+			basic := feature("basic", "1.0.0", dependsOn("other"))
+			other := feature("other", "1.0.0", dependsOn("basic"))
+
+			result := Resolve([]InstalledFeature{*basic, *other}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhaseProvisioned))
+			Expect(result.FeatureStatus(other).Phase).Should(Equal(PhaseProvisioned))
+			Expect(result.FeatureStatus(basic).DependingFeatures).Should(Equal([]InstalledFeatureRef{ref("other")}))
+			Expect(result.FeatureStatus(other).DependingFeatures).Should(Equal([]InstalledFeatureRef{ref("basic")}))
+		})
+	})
+
+	Context("with versioned dependencies", func() {
+		It("should provision the feature when the version matches", func() {
+			basic := feature("basic", "1.0.0", dependsOnVersion("other", ">=1.5.0, <2.0.0"))
+			other := feature("other", "1.7.3")
+
+			result := Resolve([]InstalledFeature{*basic, *other}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhaseProvisioned))
+		})
+
+		It("should mark the dependency as missing when the version does not match", func() {
+			basic := feature("basic", "1.0.0", dependsOnVersion("other", ">=2.0.0"))
+			other := feature("other", "1.7.3")
+
+			result := Resolve([]InstalledFeature{*basic, *other}, nil)
+
+			Expect(result.FeatureStatus(basic).MissingDependencies).Should(Equal(basic.Spec.DependsOn))
+			Expect(result.FeatureStatus(other).DependingFeatures).Should(Equal([]InstalledFeatureRef{ref("basic")}))
+		})
+
+		It("should mark the dependency as missing when the range is invalid", func() {
+			basic := feature("basic", "1.0.0", dependsOnVersion("other", ">=latest"))
+			other := feature("other", "1.7.3")
+
+			result := Resolve([]InstalledFeature{*basic, *other}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhasePending))
+		})
+
+		It("should mark the dependency as missing when the installed version is invalid", func() {
+			basic := feature("basic", "1.0.0", dependsOnVersion("other", ">=1.0.0"))
+			other := feature("other", "latest")
+
+			result := Resolve([]InstalledFeature{*basic, *other}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhasePending))
+		})
+
+		It("should accept any version when no range is given", func() {
+			basic := feature("basic", "1.0.0", dependsOn("other"))
+			other := feature("other", "latest")
+
+			result := Resolve([]InstalledFeature{*basic, *other}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhaseProvisioned))
+		})
+	})
+
+	Context("with conditions", func() {
+		It("should fail the feature when it is not compatible with the Kubernetes version", func() {
+			basic := feature("basic", "1.0.0", kubernetesCompatible(metav1.ConditionFalse))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhaseFailed))
+			Expect(result.FeatureStatus(basic).Message).Should(Equal(MessageIncompatibleKubernetes))
+			Expect(result.FeatureStatus(basic).Conditions).Should(Equal(basic.Status.Conditions))
+		})
+
+		It("should provision the feature when it is compatible with the Kubernetes version", func() {
+			basic := feature("basic", "1.0.0", kubernetesCompatible(metav1.ConditionTrue))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhaseProvisioned))
+			Expect(result.FeatureStatus(basic).Conditions).Should(HaveLen(1))
+		})
+
+		It("should prefer the missing dependencies to the incompatible Kubernetes version", func() {
+			basic := feature("basic", "1.0.0", kubernetesCompatible(metav1.ConditionFalse), dependsOn("other"))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhasePending))
+		})
+	})
+
+	Context("with workloads", func() {
+		It("should provision the feature when all replicas are ready", func() {
+			basic := feature("basic", "1.0.0", workloads(2, 3, 3))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic)).Should(Equal(InstalledFeatureStatus{
+				Phase:     PhaseProvisioned,
+				Workloads: &WorkloadStatus{Workloads: 2, ReadyReplicas: 3, DesiredReplicas: 3},
+			}))
+		})
+
+		It("should degrade the feature when replicas are not ready", func() {
+			basic := feature("basic", "1.0.0", workloads(2, 1, 3))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic)).Should(Equal(InstalledFeatureStatus{
+				Phase:     PhaseDegraded,
+				Message:   "1 of 3 replicas ready",
+				Workloads: &WorkloadStatus{Workloads: 2, ReadyReplicas: 1, DesiredReplicas: 3},
+			}))
+		})
+
+		It("should degrade the feature when no workload is selected", func() {
+			basic := feature("basic", "1.0.0", workloads(0, 0, 0))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhaseDegraded))
+			Expect(result.FeatureStatus(basic).Message).Should(Equal(MessageNoWorkloads))
+		})
+
+		It("should prefer the missing dependencies to the degraded workloads", func() {
+			basic := feature("basic", "1.0.0", workloads(1, 0, 1), dependsOn("other"))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhasePending))
+		})
+
+		It("should drop the workloads of features without selector", func() {
+			basic := feature("basic", "1.0.0", workloads(1, 0, 1))
+			basic.Spec.Workloads = nil
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic)).Should(Equal(InstalledFeatureStatus{Phase: PhaseProvisioned}))
+		})
+	})
+
+	Context("with running versions", func() {
+		It("should not report a drift when the images run the declared version", func() {
+			basic := feature("basic", "1.5.3", workloads(1, 1, 1), running("v1.5.3"))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic).VersionDrift).Should(BeFalse())
+			Expect(result.FeatureStatus(basic).Message).Should(BeEmpty())
+		})
+
+		It("should report a drift when the images run another version", func() {
+			basic := feature("basic", "1.5.3", workloads(1, 1, 1), running("v1.5.3", "v1.6.0"))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhaseProvisioned))
+			Expect(result.FeatureStatus(basic).VersionDrift).Should(BeTrue())
+			Expect(result.FeatureStatus(basic).Message).Should(Equal("version drift: declared 1.5.3, running v1.5.3, v1.6.0"))
+			Expect(result.FeatureStatus(basic).ObservedVersion).Should(BeEmpty())
+		})
+
+		It("should keep the message of degraded features with a drift", func() {
+			basic := feature("basic", "1.5.3", workloads(1, 0, 1), running("v1.6.0"))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic).VersionDrift).Should(BeTrue())
+			Expect(result.FeatureStatus(basic).Message).Should(Equal("0 of 1 replicas ready"))
+		})
+
+		It("should observe the version when enabled", func() {
+			basic := feature("basic", "1.5.3", workloads(1, 1, 1), running("v1.6.0"), observed)
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic).ObservedVersion).Should(Equal("v1.6.0"))
+		})
+
+		It("should not observe a version during a rollout", func() {
+			basic := feature("basic", "1.5.3", workloads(2, 2, 2), running("v1.5.3", "v1.6.0"), observed)
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic).ObservedVersion).Should(BeEmpty())
+		})
+	})
+
+	Context("with conflicts", func() {
+		It("should fail the feature when a conflicting feature is installed", func() {
+			basic := feature("basic", "1.0.0", conflictsWith("other", ""))
+			other := feature("other", "1.0.0")
+
+			result := Resolve([]InstalledFeature{*basic, *other}, nil)
+
+			Expect(result.FeatureStatus(basic)).Should(Equal(InstalledFeatureStatus{
+				Phase:               PhaseFailed,
+				Message:             MessageConflictingFeatures,
+				ConflictingFeatures: []InstalledFeatureRef{ref("other")},
+			}))
+			Expect(result.FeatureStatus(other)).Should(Equal(InstalledFeatureStatus{Phase: PhaseProvisioned}))
+		})
+
+		It("should provision the feature when the conflicting feature is not installed", func() {
+			basic := feature("basic", "1.0.0", conflictsWith("other", ""))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhaseProvisioned))
+		})
+
+		It("should provision the feature when the conflicting feature is deleted", func() {
+			basic := feature("basic", "1.0.0", conflictsWith("other", ""))
+			other := feature("other", "1.0.0", deleted)
+
+			result := Resolve([]InstalledFeature{*basic, *other}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhaseProvisioned))
+		})
+
+		It("should only conflict with the versions in the range", func() {
+			basic := feature("basic", "1.0.0", conflictsWith("other", "<2.0.0"))
+			other := feature("other", "2.1.0")
+
+			result := Resolve([]InstalledFeature{*basic, *other}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhaseProvisioned))
+		})
+
+		It("should not conflict with itself", func() {
+			basic := feature("basic", "1.0.0", conflictsWith("basic", ""))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhaseProvisioned))
+		})
+
+		It("should prefer the conflicts to the missing dependencies", func() {
+			basic := feature("basic", "1.0.0", dependsOn("third"), conflictsWith("other", ""))
+			other := feature("other", "1.0.0")
+
+			result := Resolve([]InstalledFeature{*basic, *other}, nil)
+
+			Expect(result.FeatureStatus(basic)).Should(Equal(InstalledFeatureStatus{
+				Phase:               PhaseFailed,
+				Message:             MessageConflictingFeatures,
+				MissingDependencies: []InstalledFeatureRef{ref("third")},
+				ConflictingFeatures: []InstalledFeatureRef{ref("other")},
+			}))
+		})
+	})
+
+	Context("with groups", func() {
+		It("should provision an empty group", func() {
+			library := group("library")
+
+			result := Resolve(nil, []InstalledFeatureGroup{*library})
+
+			Expect(result.GroupStatus(library)).Should(Equal(InstalledFeatureGroupStatus{Phase: PhaseProvisioned}))
+		})
+
+		It("should list the sorted members of the group", func() {
+			library := group("library")
+			zeta := feature("zeta", "1.0.0", memberOf("library"))
+			alpha := feature("alpha", "1.0.0", memberOf("library"))
+			foreign := feature("foreign", "1.0.0", memberOf("other-library"))
+			removed := feature("removed", "1.0.0", memberOf("library"), deleted)
+
+			result := Resolve([]InstalledFeature{*zeta, *alpha, *foreign, *removed}, []InstalledFeatureGroup{*library})
+
+			Expect(result.GroupStatus(library)).Should(Equal(InstalledFeatureGroupStatus{
+				Phase: PhaseProvisioned,
+				Features: []InstalledFeatureGroupListedFeature{
+					{Namespace: namespace, Name: "alpha"},
+					{Namespace: namespace, Name: "zeta"},
+				},
+			}))
+		})
+
+		It("should not create the status of undefined groups", func() {
+			basic := feature("basic", "1.0.0", memberOf("library"))
+
+			result := Resolve([]InstalledFeature{*basic}, nil)
+
+			Expect(result.Groups).Should(BeEmpty())
+			Expect(result.FeatureStatus(basic).Phase).Should(Equal(PhaseProvisioned))
+		})
+	})
+})
+
+type option func(*InstalledFeature)
+
+func feature(name string, version string, options ...option) *InstalledFeature {
+	result := &InstalledFeature{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+		Spec: InstalledFeatureSpec{
+			Kind:    name,
+			Version: version,
+		},
+	}
+
+	for _, o := range options {
+		o(result)
+	}
+
+	return result
+}
+
+func dependsOn(name string) option {
+	return dependsOnVersion(name, "")
+}
+
+func dependsOnVersion(name string, version string) option {
+	return func(feature *InstalledFeature) {
+		dependency := ref(name)
+		dependency.Version = version
+
+		feature.Spec.DependsOn = append(feature.Spec.DependsOn, dependency)
+	}
+}
+
+func conflictsWith(name string, version string) option {
+	return func(feature *InstalledFeature) {
+		conflict := ref(name)
+		conflict.Version = version
+
+		feature.Spec.Conflicts = append(feature.Spec.Conflicts, conflict)
+	}
+}
+
+func memberOf(name string) option {
+	return func(feature *InstalledFeature) {
+		group := ref(name)
+		feature.Spec.Group = &group
+	}
+}
+
+func sourcePhase(phase string, message string) option {
+	return func(feature *InstalledFeature) {
+		feature.Annotations = map[string]string{AnnotationSourcePhase: phase, AnnotationSourceMessage: message}
+	}
+}
+
+func workloads(count int32, ready int32, desired int32) option {
+	return func(feature *InstalledFeature) {
+		feature.Spec.Workloads = &WorkloadSelector{Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": feature.Name}}}
+		feature.Status.Workloads = &WorkloadStatus{Workloads: count, ReadyReplicas: ready, DesiredReplicas: desired}
+	}
+}
+
+func running(versions ...string) option {
+	return func(feature *InstalledFeature) {
+		feature.Status.Workloads.Versions = versions
+	}
+}
+
+func observed(feature *InstalledFeature) {
+	feature.Spec.Workloads.ObserveVersion = true
+}
+
+func kubernetesCompatible(status metav1.ConditionStatus) option {
+	return func(feature *InstalledFeature) {
+		feature.Status.Conditions = []FeatureCondition{{Type: ConditionKubernetesCompatible, Status: status}}
+	}
+}
+
+func deleted(feature *InstalledFeature) {
+	feature.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+}
+
+func group(name string) *InstalledFeatureGroup {
+	return &InstalledFeatureGroup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+	}
+}
+
+func ref(name string) InstalledFeatureRef {
+	return InstalledFeatureRef{Namespace: namespace, Name: name}
+}
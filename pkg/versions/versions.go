@@ -0,0 +1,212 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package versions contains the version handling of the feature catalogue. Versions are parsed as semantic versions
+// when possible and as generic dotted versions (e.g. "1.20") otherwise.
+package versions
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// Parse parses a version of a feature. It accepts semantic versions (with or without leading "v") and generic
+// versions with only major and minor (or only major) component.
+func Parse(v string) (*version.Version, error) {
+	v = strings.TrimSpace(v)
+	if result, err := version.ParseSemantic(v); err == nil {
+		return result, nil
+	}
+
+	if !strings.Contains(v, ".") {
+		v = v + ".0"
+	}
+
+	return version.ParseGeneric(v)
+}
+
+// Equivalent checks if both versions are the same, e.g. "v1.5" and "1.5.0". Versions that can not be parsed (e.g. digests)
+// have to be identical.
+func Equivalent(a string, b string) bool {
+	parsedA, errA := Parse(a)
+	parsedB, errB := Parse(b)
+	if errA != nil || errB != nil {
+		return strings.TrimSpace(a) == strings.TrimSpace(b)
+	}
+
+	return !parsedA.LessThan(parsedB) && !parsedB.LessThan(parsedA)
+}
+
+// operator is the comparison used by a single constraint of a version range.
+type operator string
+
+const (
+	opEqual          operator = "="
+	opNotEqual       operator = "!="
+	opGreater        operator = ">"
+	opGreaterOrEqual operator = ">="
+	opLess           operator = "<"
+	opLessOrEqual    operator = "<="
+)
+
+// operators are ordered so the two character operators are checked before their single character prefixes.
+var operators = []operator{opGreaterOrEqual, opLessOrEqual, opNotEqual, opGreater, opLess, opEqual}
+
+type constraint struct {
+	op      operator
+	version *version.Version
+}
+
+func (c constraint) matches(v *version.Version) bool {
+	cmp := 0
+	if v.LessThan(c.version) {
+		cmp = -1
+	} else if c.version.LessThan(v) {
+		cmp = 1
+	}
+
+	switch c.op {
+	case opNotEqual:
+		return cmp != 0
+	case opGreater:
+		return cmp > 0
+	case opGreaterOrEqual:
+		return cmp >= 0
+	case opLess:
+		return cmp < 0
+	case opLessOrEqual:
+		return cmp <= 0
+	default:
+		return cmp == 0
+	}
+}
+
+func (c constraint) String() string {
+	return fmt.Sprintf("%s%s", c.op, c.version)
+}
+
+// Range is a set of constraints a version has to satisfy. The constraints are separated by comma or whitespace,
+// e.g. ">=1.5.0, <2.0.0". An operator may be separated from its version by whitespace, e.g. ">= 1.5". A version
+// without operator has to match exactly. The empty range matches every version.
+type Range struct {
+	constraints []constraint
+}
+
+// ParseRange parses the textual representation of a version range.
+func ParseRange(r string) (Range, error) {
+	result := Range{}
+
+	for _, field := range fields(r) {
+		op := opEqual
+		for _, o := range operators {
+			if strings.HasPrefix(field, string(o)) {
+				op = o
+				field = field[len(o):]
+				break
+			}
+		}
+		if op == opEqual {
+			field = strings.TrimPrefix(field, "=")
+		}
+
+		v, err := Parse(field)
+		if err != nil {
+			return Range{}, fmt.Errorf("invalid version range '%s': %v", r, err)
+		}
+
+		result.constraints = append(result.constraints, constraint{op: op, version: v})
+	}
+
+	return result, nil
+}
+
+// fields splits the range into its constraints. A bare operator is joined with the version following it.
+func fields(r string) []string {
+	var result []string
+	pending := ""
+
+	for _, field := range strings.FieldsFunc(r, func(c rune) bool { return c == ',' || c == ' ' }) {
+		if isOperator(field) {
+			pending += field
+			continue
+		}
+
+		result = append(result, pending+field)
+		pending = ""
+	}
+	if pending != "" {
+		result = append(result, pending)
+	}
+
+	return result
+}
+
+// isOperator checks if the field consists of an operator only.
+func isOperator(field string) bool {
+	for _, o := range operators {
+		if field == string(o) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MustParseRange parses the range and panics if it is invalid.
+func MustParseRange(r string) Range {
+	result, err := ParseRange(r)
+	if err != nil {
+		panic(err)
+	}
+
+	return result
+}
+
+// IsAny returns true if the range accepts every version.
+func (r Range) IsAny() bool {
+	return len(r.constraints) == 0
+}
+
+// Contains checks if the given version satisfies all constraints of the range.
+func (r Range) Contains(v string) (bool, error) {
+	if r.IsAny() {
+		return true, nil
+	}
+
+	parsed, err := Parse(v)
+	if err != nil {
+		return false, err
+	}
+
+	for _, c := range r.constraints {
+		if !c.matches(parsed) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (r Range) String() string {
+	result := make([]string, len(r.constraints))
+	for i, c := range r.constraints {
+		result[i] = c.String()
+	}
+
+	return strings.Join(result, ",")
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2020 Kaiserpfalz EDV-Service, Roland T. Lichti.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package versions_test
+
+import (
+	. "github.com/klenkes74/k8s-installed-features-catalogue/pkg/versions"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Comparing versions", func() {
+	table.DescribeTable("checking versions for equality",
+		func(a string, b string, expected bool) {
+			Expect(Equivalent(a, b)).Should(Equal(expected))
+		},
+		table.Entry("identical versions", "1.5.3", "1.5.3", true),
+		table.Entry("version with leading v", "v1.5.3", "1.5.3", true),
+		table.Entry("generic version", "1.5", "1.5.0", true),
+		table.Entry("different versions", "1.5.3", "1.6.0", false),
+		table.Entry("pre-release", "1.5.3-rc1", "1.5.3", false),
+		table.Entry("identical digests", "sha256:0123", "sha256:0123", true),
+		table.Entry("digest and version", "sha256:0123", "1.5.3", false),
+	)
+})
+
+var _ = Describe("Version ranges", func() {
+	table.DescribeTable("checking versions against ranges",
+		func(r string, version string, expected bool) {
+			parsed, err := ParseRange(r)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(parsed.Contains(version)).Should(Equal(expected))
+		},
+		table.Entry("empty range matches everything", "", "1.0.0", true),
+		table.Entry("exact version", "1.5.0", "1.5.0", true),
+		table.Entry("exact version with operator", "=1.5.0", "1.5.1", false),
+		table.Entry("lower bound", ">=1.5", "1.5.0", true),
+		table.Entry("lower bound not reached", ">=1.5", "1.4.9", false),
+		table.Entry("upper bound", "<2.0.0", "1.99.0", true),
+		table.Entry("upper bound reached", "<2", "2.0.0", false),
+		table.Entry("closed interval", ">=1.5.0, <2.0.0", "1.7.3", true),
+		table.Entry("closed interval without comma", ">=1.5.0 <2.0.0", "2.1.0", false),
+		table.Entry("operator separated by space", ">= 1.5", "1.5.2", true),
+		table.Entry("operators separated by space", ">= 1.5.0, < 2.0.0", "2.0.0", false),
+		table.Entry("excluded version", "!=1.6.0", "1.6.0", false),
+		table.Entry("pre-release is lower than release", ">=1.0.0", "1.0.0-alpha1", false),
+		table.Entry("version with leading v", ">1.19", "v1.20.4", true),
+		table.Entry("generic version", "<=1.20", "1.20", true),
+	)
+
+	It("should reject invalid ranges", func() {
+		_, err := ParseRange(">=one")
+		Expect(err).Should(HaveOccurred())
+
+		_, err = ParseRange("1.0.0 >=")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should reject invalid versions", func() {
+		_, err := MustParseRange(">=1.0").Contains("latest")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should print the normalized range", func() {
+		Expect(MustParseRange(">=1.5, <2.0.0").String()).Should(Equal(">=1.5,<2.0.0"))
+	})
+})